@@ -0,0 +1,159 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"project/internal/catalog"
+	"project/internal/config"
+	"project/internal/storage"
+)
+
+// columnFlags повторяемый флаг -column поле:Заголовок
+type columnFlags []string
+
+func (c *columnFlags) String() string {
+	return strings.Join(*c, ", ")
+}
+
+func (c *columnFlags) Set(value string) error {
+	*c = append(*c, value)
+	return nil
+}
+
+// runImport выполняет подкоманду import: загружает прайс-лист CSV или XLSX в базу данных.
+// Изменения записываются напрямую в базу, минуя API: вебхуки и репликация получат их из ленты изменений
+// работающего сервера, если он отслеживает эту базу
+func runImport(args []string) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Использование: server import -db ИМЯ [параметры] ФАЙЛ")
+		flags.PrintDefaults()
+	}
+	dbName := flags.String("db", "", "база данных, в которую загружается прайс-лист")
+	format := flags.String("format", "", "формат файла: csv или xlsx (по умолчанию по расширению)")
+	sheetName := flags.String("sheet", "", "лист книги XLSX (по умолчанию первый)")
+	supplier := flags.String("supplier", "", "поставщик для строк, в которых он не указан")
+	mode := flags.String("mode", catalog.ModeUpsert, "режим: upsert - создать и обновить, create - только создать")
+	dryRun := flags.Bool("dry-run", false, "показать план без записи в базу")
+	atomic := flags.Bool("atomic", false, "загрузить все строки или ни одной")
+	var columnSpecs columnFlags
+	flags.Var(&columnSpecs, "column", "сопоставление столбца поле:Заголовок, например price:Цена опт (можно повторять)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *dbName == "" || flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	path := flags.Arg(0)
+
+	columns, err := catalog.ParseColumns(columnSpecs)
+	if err == nil {
+		err = catalog.CheckMode(*mode)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	cfg, err := config.FromEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка загрузки конфигурации: %v\n", err)
+		return 1
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer file.Close()
+
+	if *format == "" {
+		*format = catalog.DetectFormat("", path)
+	}
+	sheet, err := catalog.Parse(file, catalog.Options{
+		Format:   *format,
+		Columns:  catalog.MergeColumns(columns, cfg.Import.Columns),
+		Sheet:    *sheetName,
+		Supplier: *supplier,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка разбора прайс-листа: %v\n", err)
+		return 1
+	}
+
+	dbManager, err := storage.NewDBManager(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка при инициализации менеджера баз данных: %v\n", err)
+		return 1
+	}
+	defer dbManager.Close()
+
+	store, err := dbManager.Store(*dbName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "База данных %s: %v\n", *dbName, err)
+		return 1
+	}
+
+	report, err := catalog.Plan(store, sheet, *mode)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка чтения товаров: %v\n", err)
+		return 1
+	}
+	report.DryRun = *dryRun
+	if !*dryRun {
+		if err := report.Apply(store.BulkWrite, *atomic); err != nil {
+			fmt.Fprintf(os.Stderr, "Ошибка записи товаров: %v\n", err)
+			return 1
+		}
+	}
+
+	printReport(report)
+	if report.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// printReport выводит план или результат импорта построчно и итог
+func printReport(report *catalog.Report) {
+	for _, field := range catalog.Fields {
+		if header, ok := report.Columns[field]; ok {
+			fmt.Printf("Столбец %q -> %s\n", header, field)
+		}
+	}
+	if len(report.Ignored) > 0 {
+		fmt.Printf("Пропущенные столбцы: %s\n", strings.Join(report.Ignored, ", "))
+	}
+
+	for _, item := range report.Items {
+		line := fmt.Sprintf("строка %d: %s", item.Line, item.Action)
+		if item.ID != 0 {
+			line += fmt.Sprintf(" ID %d", item.ID)
+		}
+		if item.Name != "" {
+			line += fmt.Sprintf(" %q", item.Name)
+		}
+		if len(item.Changes) > 0 {
+			line += " (" + strings.Join(item.Changes, ", ") + ")"
+		}
+		if item.Status != 0 {
+			line += fmt.Sprintf(" -> %d", item.Status)
+		}
+		if item.Error != "" {
+			line += ": " + item.Error
+		}
+		fmt.Println(line)
+	}
+
+	prefix := "Итог"
+	if report.DryRun {
+		prefix = "План (без записи в базу)"
+	}
+	fmt.Printf("%s: создано %d, обновлено %d, без изменений %d, ошибок %d\n",
+		prefix, report.Created, report.Updated, report.Unchanged, report.Failed)
+}
@@ -1,70 +1,122 @@
-package main
-
-import (
-	"context"
-	"log"
-	"net/http"
-	"os"
-	"os/signal"
-	"syscall"
-	"time"
-
-	"project/internal/api"
-	"project/internal/storage"
-)
-
-func main() {
-	// Инициализируем менеджер баз данных
-	dbManager, err := storage.NewDBManager()
-	if err != nil {
-		log.Fatalf("Ошибка при инициализации менеджера баз данных: %v", err)
-	}
-
-	// Отложенное закрытие соединений с базами данных
-	defer dbManager.Close()
-
-	// Инициализация тестовых данных
-	if err := dbManager.InitializeTestData(); err != nil {
-		log.Printf("Предупреждение: не удалось инициализировать тестовые данные: %v", err)
-	}
-
-	// Настраиваем обработку статических файлов
-	api.SetupStaticFiles()
-
-	// Настраиваем маршруты API
-	api.SetupRoutes(dbManager)
-
-	port := ":8080"
-	server := &http.Server{
-		Addr:    port,
-		Handler: nil, // Использует DefaultServeMux
-	}
-
-	// Канал для сигналов завершения работы
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
-
-	// Запускаем сервер в отдельной горутине
-	go func() {
-		log.Printf("Сервер запущен на порту %s", port)
-		log.Printf("Веб-интерфейс доступен по адресу http://localhost%s", port)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Ошибка при запуске сервера: %v", err)
-		}
-	}()
-
-	// Ожидаем сигнал завершения
-	<-stop
-	log.Println("Завершение работы сервера...")
-
-	// Создаем контекст с таймаутом для корректного завершения
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	// Корректно останавливаем сервер
-	if err := server.Shutdown(ctx); err != nil {
-		log.Fatalf("Ошибка при остановке сервера: %v", err)
-	}
-
-	log.Println("Сервер успешно остановлен")
-}
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"project/internal/api"
+	"project/internal/changefeed"
+	"project/internal/config"
+	"project/internal/replication"
+	"project/internal/storage"
+	"project/internal/webhooks"
+)
+
+func main() {
+	// Подкоманды: server import ..., server migrate ...
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "import":
+			os.Exit(runImport(os.Args[2:]))
+		case "migrate":
+			os.Exit(runMigrate(os.Args[2:]))
+		default:
+			log.Fatalf("Неизвестная подкоманда %s; доступные подкоманды: import, migrate", os.Args[1])
+		}
+	}
+
+	// Загружаем конфигурацию баз данных
+	cfg, err := config.FromEnv()
+	if err != nil {
+		log.Fatalf("Ошибка загрузки конфигурации: %v", err)
+	}
+
+	// Инициализируем менеджер баз данных
+	dbManager, err := storage.NewDBManager(cfg)
+	if err != nil {
+		log.Fatalf("Ошибка при инициализации менеджера баз данных: %v", err)
+	}
+
+	// Отложенное закрытие соединений с базами данных
+	defer dbManager.Close()
+
+	// Инициализация тестовых данных
+	if err := dbManager.InitializeTestData(); err != nil {
+		log.Printf("Предупреждение: не удалось инициализировать тестовые данные: %v", err)
+	}
+
+	// Фоновая сверка товаров между базами данных
+	interval, _ := cfg.Reconciliation.IntervalDuration()
+	if interval > 0 {
+		dbManager.StartReconciliation(interval, storage.ReconcileOptions{Key: cfg.Reconciliation.Key})
+		log.Printf("Сверка баз данных запускается каждые %v", interval)
+	}
+
+	// Лента изменений товаров во всех базах, включая изменения в обход API
+	feed := changefeed.New(dbManager)
+	defer feed.Close()
+
+	// Односторонняя репликация из ведущей базы в ведомые
+	replicator, err := replication.New(cfg.Replication, dbManager)
+	if err != nil {
+		log.Fatalf("Ошибка при инициализации репликации: %v", err)
+	}
+	defer replicator.Close()
+	if replicator != nil {
+		log.Printf("Репликация из базы %s в базы %v", cfg.Replication.Source, cfg.Replication.Followers)
+	}
+
+	// Доставка вебхуков об изменениях товаров
+	dispatcher, err := webhooks.New(cfg.Webhooks)
+	if err != nil {
+		log.Fatalf("Ошибка при инициализации вебхуков: %v", err)
+	}
+	defer dispatcher.Close()
+
+	// Настраиваем обработку статических файлов
+	api.SetupStaticFiles()
+
+	// Настраиваем маршруты API
+	apiHandler := api.SetupRoutes(dbManager, feed, replicator, dispatcher, cfg.Import)
+
+	port := ":8080"
+	server := &http.Server{
+		Addr:    port,
+		Handler: nil, // Использует DefaultServeMux
+	}
+	// Потоки событий не завершаются сами, поэтому при остановке сервера закрываем их явно
+	server.RegisterOnShutdown(apiHandler.Close)
+
+	// Канал для сигналов завершения работы
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	// Запускаем сервер в отдельной горутине
+	go func() {
+		log.Printf("Сервер запущен на порту %s", port)
+		log.Printf("Веб-интерфейс доступен по адресу http://localhost%s", port)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Ошибка при запуске сервера: %v", err)
+		}
+	}()
+
+	// Ожидаем сигнал завершения
+	<-stop
+	log.Println("Завершение работы сервера...")
+
+	// Создаем контекст с таймаутом для корректного завершения
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Корректно останавливаем сервер
+	if err := server.Shutdown(ctx); err != nil {
+		log.Fatalf("Ошибка при остановке сервера: %v", err)
+	}
+
+	log.Println("Сервер успешно остановлен")
+}
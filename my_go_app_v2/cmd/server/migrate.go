@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"project/internal/config"
+	"project/internal/storage"
+)
+
+// runMigrate выполняет подкоманду migrate: up применяет миграции схемы, down отменяет последние
+// примененные, status показывает состояние, validate перечисляет документы MongoDB, которые
+// не соответствуют схеме товара. Без -db команды выполняются для всех баз, которые их поддерживают
+func runMigrate(args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Использование: server migrate up|down|status|validate [-db ИМЯ] [-steps N] [-limit N]")
+		flags.PrintDefaults()
+	}
+	dbName := flags.String("db", "", "база данных (для down обязательна; по умолчанию все базы)")
+	steps := flags.Int("steps", 1, "количество отменяемых миграций для down")
+	limit := flags.Int("limit", 100, "наибольшее количество документов в отчете validate (0 - все)")
+
+	if len(args) == 0 {
+		flags.Usage()
+		return 2
+	}
+	command := args[0]
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	switch {
+	case command != "up" && command != "down" && command != "status" && command != "validate", flags.NArg() != 0:
+		flags.Usage()
+		return 2
+	case command == "down" && *dbName == "":
+		fmt.Fprintln(os.Stderr, "Для migrate down нужно указать базу флагом -db")
+		return 2
+	}
+
+	cfg, err := config.FromEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка загрузки конфигурации: %v\n", err)
+		return 1
+	}
+
+	var targets []config.DatabaseConfig
+	for _, dbCfg := range cfg.Databases {
+		if *dbName == "" || dbCfg.Name == *dbName {
+			targets = append(targets, dbCfg)
+		}
+	}
+	if len(targets) == 0 {
+		fmt.Fprintf(os.Stderr, "База данных %s не найдена в конфигурации\n", *dbName)
+		return 1
+	}
+
+	code := 0
+	for _, dbCfg := range targets {
+		if err := migrateDatabase(dbCfg, command, *steps, *limit); err != nil {
+			if errors.Is(err, storage.ErrMigrationsNotSupported) && *dbName == "" {
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "База %s: %s\n", dbCfg.Name, dbCfg.Redact(err.Error()))
+			code = 1
+		}
+	}
+	return code
+}
+
+// migrateDatabase выполняет команду migrate для одной базы и выводит результат
+func migrateDatabase(dbCfg config.DatabaseConfig, command string, steps, limit int) error {
+	migrator, err := storage.OpenMigrator(dbCfg)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	checker, canCheck := migrator.(storage.SchemaChecker)
+	if command == "validate" && !canCheck {
+		return fmt.Errorf("проверка документов по схеме товара поддерживается только для MongoDB: %w", storage.ErrMigrationsNotSupported)
+	}
+
+	ctx := context.Background()
+	fmt.Printf("%s (%s):\n", dbCfg.Name, dbCfg.Driver)
+
+	switch command {
+	case "status":
+		migrations, err := migrator.Migrations(ctx)
+		if err != nil {
+			return err
+		}
+		for _, migration := range migrations {
+			state := "не применена"
+			switch {
+			case migration.Unknown:
+				state = "применена " + migration.AppliedAt.Format("2006-01-02 15:04:05") + " (неизвестна этой версии сервера)"
+			case migration.Applied():
+				state = "применена " + migration.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("  %3d %-20s %s\n", migration.Version, migration.Name, state)
+		}
+
+	case "up":
+		applied, err := migrator.MigrateUp(ctx)
+		for _, migration := range applied {
+			fmt.Printf("  применена %d %s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("  схема актуальна")
+		}
+
+	case "down":
+		reverted, err := migrator.MigrateDown(ctx, steps)
+		for _, migration := range reverted {
+			fmt.Printf("  отменена %d %s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(reverted) == 0 {
+			fmt.Println("  нет примененных миграций")
+		}
+
+	case "validate":
+		violations, total, err := checker.SchemaViolations(ctx, limit)
+		if err != nil {
+			return err
+		}
+		if total == 0 {
+			fmt.Println("  все документы соответствуют схеме товара")
+			return nil
+		}
+		for _, violation := range violations {
+			fmt.Printf("  %s\n", violation)
+		}
+		if int64(len(violations)) < total {
+			fmt.Printf("  ... и еще %d\n", total-int64(len(violations)))
+		}
+		return fmt.Errorf("документов, не соответствующих схеме товара: %d", total)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+// Команда webhook-receiver - локальный получатель вебхуков для проверки доставки:
+// проверяет подпись уведомлений и выводит их в журнал.
+//
+// Переменные окружения:
+//
+//	WEBHOOK_ADDR   - адрес прослушивания (по умолчанию :9090)
+//	WEBHOOK_SECRET - ключ подписи подписки; если не задан, подпись не проверяется
+//	WEBHOOK_FAIL   - код ответа, которым получатель отвечает на все уведомления (для проверки повторных попыток)
+package main
+
+import (
+	"crypto/hmac"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"project/internal/webhooks"
+)
+
+// maxTimestampSkew допустимое расхождение метки времени уведомления с текущим временем
+const maxTimestampSkew = 5 * time.Minute
+
+func main() {
+	addr := os.Getenv("WEBHOOK_ADDR")
+	if addr == "" {
+		addr = ":9090"
+	}
+	secret := os.Getenv("WEBHOOK_SECRET")
+
+	failStatus := 0
+	if value := os.Getenv("WEBHOOK_FAIL"); value != "" {
+		status, err := strconv.Atoi(value)
+		if err != nil || status < 100 || status > 599 {
+			log.Fatalf("WEBHOOK_FAIL должен быть кодом ответа HTTP")
+		}
+		failStatus = status
+	}
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			http.Error(w, "Ошибка чтения тела запроса", http.StatusBadRequest)
+			return
+		}
+
+		delivery := r.Header.Get(webhooks.HeaderDelivery)
+		event := r.Header.Get(webhooks.HeaderEvent)
+
+		if secret != "" {
+			timestamp := r.Header.Get(webhooks.HeaderTimestamp)
+			seconds, err := strconv.ParseInt(timestamp, 10, 64)
+			if err != nil || time.Since(time.Unix(seconds, 0)).Abs() > maxTimestampSkew {
+				log.Printf("Уведомление %s отклонено: неверная метка времени %q", delivery, timestamp)
+				http.Error(w, "Неверная метка времени", http.StatusUnauthorized)
+				return
+			}
+			expected := webhooks.Sign(secret, timestamp, body)
+			if !hmac.Equal([]byte(expected), []byte(r.Header.Get(webhooks.HeaderSignature))) {
+				log.Printf("Уведомление %s отклонено: неверная подпись", delivery)
+				http.Error(w, "Неверная подпись", http.StatusUnauthorized)
+				return
+			}
+		}
+
+		if failStatus != 0 {
+			log.Printf("Уведомление %s (%s): отвечаем %d", delivery, event, failStatus)
+			w.WriteHeader(failStatus)
+			return
+		}
+
+		log.Printf("Уведомление %s (%s): %s", delivery, event, body)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	log.Printf("Получатель вебхуков слушает %s", addr)
+	log.Fatal(http.ListenAndServe(addr, nil))
+}
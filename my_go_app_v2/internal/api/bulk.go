@@ -0,0 +1,146 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"project/internal/models"
+	"project/internal/storage"
+)
+
+// handleBulk обрабатывает POST /{db}/products/_bulk: тело - массив операций create, upsert, patch и delete.
+// ?atomic=true - режим "все или ничего": при ошибке любой операции пакет не применяется.
+// Ответ содержит результат каждой операции в порядке пакета
+func (h *APIHandler) handleBulk(w http.ResponseWriter, r *http.Request, store storage.ProductStore, dbName string) {
+	var operations []storage.BulkOperation
+	if err := json.NewDecoder(r.Body).Decode(&operations); err != nil {
+		http.Error(w, "Ошибка чтения данных: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(operations) == 0 {
+		http.Error(w, "Пакет не содержит операций", http.StatusBadRequest)
+		return
+	}
+	if len(operations) > storage.MaxBulkOperations {
+		http.Error(w, fmt.Sprintf("Пакет содержит больше %d операций", storage.MaxBulkOperations), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	atomic := r.URL.Query().Get("atomic") == "true"
+	results, err := h.bulkWrite(store, dbName, operations, atomic)
+	if errors.Is(err, storage.ErrAtomicNotSupported) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Ошибка выполнения пакета: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	applied := 0
+	for _, result := range results {
+		if result.Succeeded() {
+			applied++
+		}
+	}
+	failed := len(results) - applied
+
+	status := "success"
+	code := http.StatusOK
+	switch {
+	case failed > 0 && atomic:
+		// Пакет откатан целиком
+		status = "error"
+		code = http.StatusUnprocessableEntity
+	case failed > 0 && applied == 0:
+		status = "error"
+	case failed > 0:
+		status = "partial"
+	}
+
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]any{
+		"status":  status,
+		"atomic":  atomic,
+		"applied": applied,
+		"failed":  failed,
+		"results": results,
+	})
+}
+
+// bulkWrite применяет пакет операций и сообщает о каждом примененном изменении
+func (h *APIHandler) bulkWrite(store storage.ProductStore, dbName string, operations []storage.BulkOperation, atomic bool) ([]storage.BulkResult, error) {
+	deleted := h.deletedProducts(store, dbName, operations)
+	results, err := store.BulkWrite(operations, atomic)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, result := range results {
+		if !result.Succeeded() {
+			continue
+		}
+		switch operations[i].Op {
+		case storage.BulkCreate, storage.BulkUpsert:
+			product := *operations[i].Product
+			product.ID = result.ID
+			product.Version = result.Version
+			operation := storage.ChangeUpdated
+			if result.Status == http.StatusCreated {
+				operation = storage.ChangeCreated
+			}
+			h.productChanged(dbName, operation, product)
+		case storage.BulkPatch:
+			// Операция содержит только изменяемые поля, поэтому событие получает товар из базы
+			if h.feed.Watching(dbName) {
+				continue
+			}
+			product, exists, err := store.GetProduct(result.ID)
+			if err != nil {
+				log.Printf("Пакет операций: чтение товара %d для события: %v", result.ID, err)
+				continue
+			}
+			if exists {
+				h.productChanged(dbName, storage.ChangeUpdated, product)
+			}
+		case storage.BulkDelete:
+			product, ok := deleted[result.ID]
+			if !ok {
+				product = models.Product{ID: result.ID}
+			}
+			h.productChanged(dbName, storage.ChangeDeleted, product)
+		}
+	}
+	return results, nil
+}
+
+// deletedProducts читает товары, которые удаляет пакет, чтобы передать их целиком в событиях удаления.
+// Удаленные товары отслеживаемых баз лента получит из самой базы, для них ничего не читается
+func (h *APIHandler) deletedProducts(store storage.ProductStore, dbName string, operations []storage.BulkOperation) map[int]models.Product {
+	if h.feed.Watching(dbName) {
+		return nil
+	}
+	var ids []int
+	for _, operation := range operations {
+		if operation.Op == storage.BulkDelete && operation.ID != 0 {
+			ids = append(ids, operation.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	page, err := store.ListProducts(storage.ProductQuery{IDs: ids})
+	if err != nil {
+		log.Printf("Пакет операций: чтение удаляемых товаров для событий: %v", err)
+		return nil
+	}
+	products := make(map[int]models.Product, len(page.Products))
+	for _, product := range page.Products {
+		products[product.ID] = product
+	}
+	return products
+}
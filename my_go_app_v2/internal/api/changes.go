@@ -0,0 +1,116 @@
+package api
+
+import (
+	"log"
+	"time"
+
+	"project/internal/changefeed"
+	"project/internal/models"
+	"project/internal/replication"
+	"project/internal/storage"
+)
+
+// replicationOperations операции репликации, соответствующие изменениям товара
+var replicationOperations = map[string]string{
+	storage.ChangeCreated: replication.OperationCreate,
+	storage.ChangeUpdated: replication.OperationUpdate,
+	storage.ChangeDeleted: replication.OperationDelete,
+}
+
+// changeBufferSize размер буфера подписки обработчика на ленту изменений
+const changeBufferSize = 1024
+
+// productChanged вызывается после успешного изменения товара через API. Изменения отслеживаемых баз
+// лента получит из самой базы; для остальных баз изменение публикуется в ленту отсюда
+func (h *APIHandler) productChanged(dbName, operation string, product models.Product) {
+	if h.feed.Watching(dbName) {
+		return
+	}
+	h.feed.Publish(changefeed.ProductChanged{
+		Database:  dbName,
+		Operation: operation,
+		ProductID: product.ID,
+		Product:   &product,
+		Time:      time.Now().UTC(),
+	})
+}
+
+// consumeChanges передает изменения из ленты, в том числе сделанные в обход API (подкомандой import,
+// другим экземпляром сервера, напрямую в СУБД): рассылает событие подписчикам SSE и WebSocket,
+// ставит изменение в очередь репликации, если база является ведущей, и в очередь вебхуков.
+// Изменение в базе уже выполнено, поэтому ошибка очереди записывается в журнал
+func (h *APIHandler) consumeChanges(changes <-chan changefeed.ProductChanged) {
+	for change := range changes {
+		product := models.Product{ID: change.ProductID}
+		if change.Product != nil {
+			product = *change.Product
+		}
+
+		h.events.publish(change.Database, change.Operation, product)
+
+		if err := h.replicator.Enqueue(change.Database, replicationOperations[change.Operation], product); err != nil {
+			log.Printf("Репликация: %v", err)
+		}
+
+		if h.webhooks != nil {
+			if err := h.webhooks.Enqueue(change.Database, change.Operation, product); err != nil {
+				log.Printf("Вебхуки: %v", err)
+			}
+		}
+	}
+}
+
+// createProduct добавляет товар в базу и сообщает об изменении. Товар без ID получает ID, назначенный базой.
+// Возвращает сохраненный товар с ID и версией
+func (h *APIHandler) createProduct(store storage.ProductStore, dbName string, product models.Product) (models.Product, error) {
+	id, err := store.AddProduct(product)
+	if err != nil {
+		return models.Product{}, err
+	}
+	product.ID = id
+	product.Version = 1
+	h.productChanged(dbName, storage.ChangeCreated, product)
+	return product, nil
+}
+
+// updateProduct обновляет товар в базе, если его версия равна version (0 - без проверки),
+// и сообщает об изменении. Возвращает сохраненный товар с новой версией
+func (h *APIHandler) updateProduct(store storage.ProductStore, dbName string, product models.Product, version int64) (models.Product, error) {
+	newVersion, err := store.UpdateProduct(product, version)
+	if err != nil {
+		return models.Product{}, err
+	}
+	product.Version = newVersion
+	h.productChanged(dbName, storage.ChangeUpdated, product)
+	return product, nil
+}
+
+// patchProduct изменяет поля fields товара, если его версия равна version, и сообщает об изменении.
+// product - товар целиком после изменения; возвращается с новой версией
+func (h *APIHandler) patchProduct(store storage.ProductStore, dbName string, product models.Product, fields []string, version int64) (models.Product, error) {
+	newVersion, err := store.PatchProduct(product, fields, version)
+	if err != nil {
+		return models.Product{}, err
+	}
+	product.Version = newVersion
+	h.productChanged(dbName, storage.ChangeUpdated, product)
+	return product, nil
+}
+
+// deleteProduct удаляет товар из базы, если его версия равна version (0 - без проверки), и сообщает об изменении.
+// Удаленный товар отслеживаемых баз лента получит из самой базы; для остальных баз товар читается
+// перед удалением, чтобы передать его целиком в событии удаления
+func (h *APIHandler) deleteProduct(store storage.ProductStore, dbName string, id int, version int64) error {
+	product := models.Product{ID: id}
+	if !h.feed.Watching(dbName) {
+		if current, exists, err := store.GetProduct(id); err == nil && exists {
+			product = current
+		}
+	}
+
+	if err := store.DeleteProduct(id, version); err != nil {
+		return err
+	}
+	h.productChanged(dbName, storage.ChangeDeleted, product)
+	return nil
+}
@@ -0,0 +1,244 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"project/internal/models"
+)
+
+// Параметры потока событий SSE
+const (
+	// eventBufferSize количество последних событий каждой базы, доступных для повторной отправки по Last-Event-ID
+	eventBufferSize = 1000
+	// eventHeartbeat период отправки комментария, чтобы прокси и браузер не закрывали неактивное соединение
+	eventHeartbeat = 15 * time.Second
+	// eventRetry задержка переподключения клиента в миллисекундах
+	eventRetry = 3000
+)
+
+// productEvent событие изменения товара, выполненного через API
+type productEvent struct {
+	ID        string         `json:"id"`
+	Database  string         `json:"database"`
+	Operation string         `json:"operation"`
+	Product   models.Product `json:"product"`
+	Time      time.Time      `json:"time"`
+
+	seq int64
+}
+
+// eventStream события одной базы данных: кольцевой буфер последних событий и подписчики
+type eventStream struct {
+	buffer      []productEvent
+	seq         int64
+	subscribers map[chan productEvent]struct{}
+}
+
+// eventBroker рассылает события изменения товаров подписчикам SSE.
+// Идентификатор события имеет вид "<запуск>-<номер>": после перезапуска сервера номера
+// начинаются заново, и по метке запуска старый Last-Event-ID отличается от нового
+type eventBroker struct {
+	epoch string
+
+	mu      sync.Mutex
+	streams map[string]*eventStream
+	// all подписчики на события всех баз (WebSocket)
+	all    map[chan productEvent]struct{}
+	closed chan struct{}
+	once   sync.Once
+}
+
+// newEventBroker создает брокер событий
+func newEventBroker() *eventBroker {
+	return &eventBroker{
+		epoch:   strconv.FormatInt(time.Now().Unix(), 36),
+		streams: make(map[string]*eventStream),
+		all:     make(map[chan productEvent]struct{}),
+		closed:  make(chan struct{}),
+	}
+}
+
+// stream возвращает события базы данных (вызывается под мьютексом)
+func (b *eventBroker) stream(dbName string) *eventStream {
+	s, ok := b.streams[dbName]
+	if !ok {
+		s = &eventStream{subscribers: make(map[chan productEvent]struct{})}
+		b.streams[dbName] = s
+	}
+	return s
+}
+
+// publish сохраняет событие в буфере и рассылает подписчикам базы.
+// Подписчик, буфер которого переполнен, отключается и переподключится с Last-Event-ID
+func (b *eventBroker) publish(dbName, operation string, product models.Product) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := b.stream(dbName)
+	s.seq++
+	event := productEvent{
+		ID:        b.epoch + "-" + strconv.FormatInt(s.seq, 10),
+		Database:  dbName,
+		Operation: operation,
+		Product:   product,
+		Time:      time.Now().UTC(),
+		seq:       s.seq,
+	}
+
+	s.buffer = append(s.buffer, event)
+	if len(s.buffer) > eventBufferSize {
+		s.buffer = s.buffer[len(s.buffer)-eventBufferSize:]
+	}
+
+	for _, subscribers := range []map[chan productEvent]struct{}{s.subscribers, b.all} {
+		for ch := range subscribers {
+			select {
+			case ch <- event:
+			default:
+				delete(subscribers, ch)
+				close(ch)
+			}
+		}
+	}
+}
+
+// subscribe подписывает на события базы и возвращает события из буфера, пропущенные после lastEventID.
+// Если lastEventID относится к другому запуску сервера или уже вытеснен из буфера, возвращается весь буфер
+func (b *eventBroker) subscribe(dbName, lastEventID string) ([]productEvent, chan productEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := b.stream(dbName)
+	ch := make(chan productEvent, 64)
+	s.subscribers[ch] = struct{}{}
+
+	if lastEventID == "" {
+		return nil, ch
+	}
+
+	var after int64
+	if epoch, seq, ok := strings.Cut(lastEventID, "-"); ok && epoch == b.epoch {
+		after, _ = strconv.ParseInt(seq, 10, 64)
+	}
+
+	var replay []productEvent
+	for _, event := range s.buffer {
+		if event.seq > after {
+			replay = append(replay, event)
+		}
+	}
+	return replay, ch
+}
+
+// unsubscribe отменяет подписку, если она еще не отменена брокером
+func (b *eventBroker) unsubscribe(dbName string, ch chan productEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := b.stream(dbName)
+	if _, ok := s.subscribers[ch]; ok {
+		delete(s.subscribers, ch)
+		close(ch)
+	}
+}
+
+// subscribeAll подписывает на события всех баз данных (без повторной отправки из буфера)
+func (b *eventBroker) subscribeAll() chan productEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan productEvent, 64)
+	b.all[ch] = struct{}{}
+	return ch
+}
+
+// unsubscribeAll отменяет подписку на события всех баз, если она еще не отменена брокером
+func (b *eventBroker) unsubscribeAll(ch chan productEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.all[ch]; ok {
+		delete(b.all, ch)
+		close(ch)
+	}
+}
+
+// close завершает все потоки событий (при остановке сервера)
+func (b *eventBroker) close() {
+	b.once.Do(func() { close(b.closed) })
+}
+
+// writeEvent отправляет событие в формате SSE
+func writeEvent(w http.ResponseWriter, event productEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Operation, data)
+	return err
+}
+
+// handleEvents отдает поток изменений товаров базы в формате Server-Sent Events:
+// GET /{db}/products/events. События created, updated и deleted содержат товар целиком.
+// Клиент, переподключившийся с заголовком Last-Event-ID, получает пропущенные события из буфера
+func (h *APIHandler) handleEvents(w http.ResponseWriter, r *http.Request, dbName string) {
+	controller := http.NewResponseController(w)
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		// EventSource не позволяет задать заголовок при первом подключении
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	replay, events := h.events.subscribe(dbName, lastEventID)
+	defer h.events.unsubscribe(dbName, events)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Отключаем буферизацию ответа в nginx
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", eventRetry)
+	for _, event := range replay {
+		if err := writeEvent(w, event); err != nil {
+			return
+		}
+	}
+	if err := controller.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-h.events.closed:
+			return
+		case event, ok := <-events:
+			if !ok {
+				// Клиент не успевал получать события; он переподключится и получит пропущенные из буфера
+				return
+			}
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"project/internal/catalog"
+	"project/internal/models"
+	"project/internal/storage"
+)
+
+// exportFormats форматы выгрузки по MIME-типам заголовка Accept ("" - обычный ответ JSON)
+var exportFormats = map[string]string{
+	catalog.MIMECSV:      catalog.FormatCSV,
+	catalog.MIMENDJSON:   catalog.FormatNDJSON,
+	"application/ndjson": catalog.FormatNDJSON,
+	catalog.MIMEXLSX:     catalog.FormatXLSX,
+	"application/json":   "",
+	"application/*":      "",
+	"*/*":                "",
+}
+
+// exportFormat формат выгрузки списка товаров: параметр format (json, csv, ndjson, xlsx)
+// или поддерживаемый тип из заголовка Accept с наибольшим весом q.
+// Пустое значение - обычный ответ JSON со страницей товаров
+func exportFormat(r *http.Request) (string, error) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		switch format {
+		case "json":
+			return "", nil
+		case catalog.FormatCSV, catalog.FormatNDJSON, catalog.FormatXLSX:
+			return format, nil
+		}
+		return "", fmt.Errorf("неизвестный формат %q: ожидается json, csv, ndjson или xlsx", format)
+	}
+
+	best, bestQ := "", 0.0
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+		format, ok := exportFormats[mediaType]
+		if !ok {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		if q > bestQ {
+			best, bestQ = format, q
+		}
+	}
+	return best, nil
+}
+
+// handleExport выгружает все товары, удовлетворяющие фильтрам и сортировке запроса, в формате format.
+// Товары читаются из базы потоком и сразу записываются в ответ, поэтому выгрузка не собирается в памяти
+func (h *APIHandler) handleExport(w http.ResponseWriter, r *http.Request, store storage.ProductStore, dbName string, query storage.ProductQuery, format string) {
+	// Выгрузка без limit включает все товары
+	if r.URL.Query().Get("limit") == "" {
+		query.Limit = 0
+	}
+
+	// Ответ начинается с первого товара: если запрос к базе не удался сразу, клиент получает код ошибки
+	var writer catalog.Writer
+	start := func() error {
+		w.Header().Set("Content-Type", catalog.ContentType(format))
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-products.%s"`, dbName, format))
+		var err error
+		writer, err = catalog.NewWriter(w, format)
+		return err
+	}
+
+	err := store.StreamProducts(r.Context(), query, func(product models.Product) error {
+		if writer == nil {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		return writer.Write(product)
+	})
+	if err == nil && writer == nil {
+		err = start()
+	}
+	if err == nil {
+		err = writer.Close()
+	} else if writer != nil {
+		writer.Discard()
+	}
+	if err != nil {
+		if writer == nil || format == catalog.FormatXLSX {
+			// Книга XLSX передается только при закрытии, поэтому ответ еще не начат
+			w.Header().Del("Content-Disposition")
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			http.Error(w, "Ошибка выгрузки товаров: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// Часть выгрузки уже передана: прерываем ответ, чтобы клиент не принял ее за полную
+		log.Printf("Выгрузка товаров из базы %s прервана: %v", dbName, err)
+		panic(http.ErrAbortHandler)
+	}
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"project/internal/storage"
+)
+
+// federatedTimeout максимальное время ожидания ответа одной базы данных в /all/products
+const federatedTimeout = 5 * time.Second
+
+// maxFederatedWindow наибольшее значение offset + limit в /all/products: каждая база возвращает
+// первые offset + limit товаров (при сортировке по строковому полю - все подходящие), и все они собираются в памяти
+const maxFederatedWindow = 10000
+
+// handleAllProducts обрабатывает GET /all/products: параллельный запрос ко всем базам данных
+// с теми же фильтрами, сортировкой и страницами, что и /{db}/products.
+// Каждый товар помечается базой-источником; ошибки отдельных баз не прерывают запрос,
+// а перечисляются в поле errors (статус partial)
+func (h *APIHandler) handleAllProducts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query, err := parseProductQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkFederatedQuery(query); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page := h.dbManager.ListAllProducts(r.Context(), query, federatedTimeout)
+
+	status := "ok"
+	code := http.StatusOK
+	switch {
+	case len(page.Errors) == len(h.dbManager.Names()):
+		status = "unavailable"
+		code = http.StatusServiceUnavailable
+	case len(page.Errors) > 0:
+		status = "partial"
+	}
+
+	setPageHeaders(w, r, query, page.Total, len(page.Products))
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":   status,
+		"products": page.Products,
+		"errors":   page.Errors,
+	})
+}
+
+// checkFederatedQuery проверяет страницу запроса ко всем базам
+func checkFederatedQuery(query storage.ProductQuery) error {
+	if query.Offset+query.Limit > maxFederatedWindow {
+		return fmt.Errorf("для /all/products сумма offset и limit не должна превышать %d", maxFederatedWindow)
+	}
+	return nil
+}
@@ -1,244 +1,332 @@
-package api
-
-import (
-	"encoding/json"
-	"fmt"
-	"net/http"
-	"strconv"
-	"strings"
-
-	"project/internal/models"
-	"project/internal/storage"
-)
-
-// APIHandler обрабатывает API запросы
-type APIHandler struct {
-	dbManager *storage.DBManager
-}
-
-// NewAPIHandler создает новый обработчик API
-func NewAPIHandler(dbManager *storage.DBManager) *APIHandler {
-	return &APIHandler{dbManager: dbManager}
-}
-
-// ServeHTTP обрабатывает HTTP запросы
-func (h *APIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	// Парсим путь запроса
-	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
-	if len(pathParts) < 2 {
-		http.Error(w, "Неверный путь запроса", http.StatusBadRequest)
-		return
-	}
-
-	dbName := pathParts[0]
-	resource := pathParts[1]
-
-	// Проверяем, какая база данных запрошена
-	if !h.validateDBName(dbName) {
-		http.Error(w, "База данных не найдена", http.StatusNotFound)
-		return
-	}
-
-	// Обрабатываем запрос в зависимости от метода HTTP
-	switch r.Method {
-	case http.MethodGet:
-		h.handleGet(w, r, dbName, resource, pathParts)
-	case http.MethodPost:
-		h.handlePost(w, r, dbName, resource)
-	case http.MethodPut:
-		h.handlePut(w, r, dbName, resource, pathParts)
-	case http.MethodDelete:
-		h.handleDelete(w, r, dbName, resource, pathParts)
-	default:
-		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
-	}
-}
-
-// validateDBName проверяет валидность имени базы данных
-func (h *APIHandler) validateDBName(dbName string) bool {
-	return dbName == "products_db" || dbName == "suppliers_db" || dbName == "inventory_db"
-}
-
-// handleGet обрабатывает GET запросы
-func (h *APIHandler) handleGet(w http.ResponseWriter, r *http.Request, dbName, resource string, pathParts []string) {
-	if resource != "products" {
-		http.Error(w, "Ресурс не найден", http.StatusNotFound)
-		return
-	}
-
-	// Если в пути есть идентификатор - возвращаем один товар
-	if len(pathParts) > 2 {
-		id, err := strconv.Atoi(pathParts[2])
-		if err != nil {
-			http.Error(w, "Неверный формат идентификатора", http.StatusBadRequest)
-			return
-		}
-
-		var product models.Product
-		var exists bool
-		var getErr error
-
-		// Выбираем соответствующую БД
-		switch dbName {
-		case "products_db":
-			product, exists, getErr = h.dbManager.MongoDB.GetProduct(id)
-		case "suppliers_db":
-			product, exists, getErr = h.dbManager.PostgresDB.GetProduct(id)
-		case "inventory_db":
-			product, exists, getErr = h.dbManager.MySQLDB.GetProduct(id)
-		}
-
-		if getErr != nil {
-			http.Error(w, "Ошибка при получении товара: "+getErr.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		if !exists {
-			http.Error(w, "Товар не найден", http.StatusNotFound)
-			return
-		}
-
-		json.NewEncoder(w).Encode(product)
-		return
-	}
-
-	// Возвращаем все товары
-	var products []models.Product
-	var err error
-
-	// Выбираем соответствующую БД
-	switch dbName {
-	case "products_db":
-		products, err = h.dbManager.MongoDB.GetAllProducts()
-	case "suppliers_db":
-		products, err = h.dbManager.PostgresDB.GetAllProducts()
-	case "inventory_db":
-		products, err = h.dbManager.MySQLDB.GetAllProducts()
-	}
-
-	if err != nil {
-		http.Error(w, "Ошибка при получении товаров: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	json.NewEncoder(w).Encode(products)
-}
-
-// handlePost обрабатывает POST запросы (создание нового товара)
-func (h *APIHandler) handlePost(w http.ResponseWriter, r *http.Request, dbName, resource string) {
-	if resource != "products" {
-		http.Error(w, "Ресурс не найден", http.StatusNotFound)
-		return
-	}
-
-	var product models.Product
-	if err := json.NewDecoder(r.Body).Decode(&product); err != nil {
-		http.Error(w, "Ошибка чтения данных: "+err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	var err error
-
-	// Добавляем товар в соответствующую БД
-	switch dbName {
-	case "products_db":
-		err = h.dbManager.MongoDB.AddProduct(product)
-	case "suppliers_db":
-		err = h.dbManager.PostgresDB.AddProduct(product)
-	case "inventory_db":
-		err = h.dbManager.MySQLDB.AddProduct(product)
-	}
-
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusConflict)
-		return
-	}
-
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{
-		"status":  "success",
-		"message": fmt.Sprintf("Товар с ID %d успешно создан в базе %s", product.ID, dbName),
-	})
-}
-
-// handlePut обрабатывает PUT запросы (обновление товара)
-func (h *APIHandler) handlePut(w http.ResponseWriter, r *http.Request, dbName, resource string, pathParts []string) {
-	if resource != "products" || len(pathParts) <= 2 {
-		http.Error(w, "Неверный путь запроса", http.StatusBadRequest)
-		return
-	}
-
-	id, err := strconv.Atoi(pathParts[2])
-	if err != nil {
-		http.Error(w, "Неверный формат идентификатора", http.StatusBadRequest)
-		return
-	}
-
-	var product models.Product
-	if err := json.NewDecoder(r.Body).Decode(&product); err != nil {
-		http.Error(w, "Ошибка чтения данных: "+err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	// Удостоверимся, что ID в пути и в теле запроса совпадают
-	if product.ID != id {
-		http.Error(w, "ID в пути и в теле запроса не совпадают", http.StatusBadRequest)
-		return
-	}
-
-	// Обновляем товар в соответствующей БД
-	switch dbName {
-	case "products_db":
-		err = h.dbManager.MongoDB.UpdateProduct(product)
-	case "suppliers_db":
-		err = h.dbManager.PostgresDB.UpdateProduct(product)
-	case "inventory_db":
-		err = h.dbManager.MySQLDB.UpdateProduct(product)
-	}
-
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
-		return
-	}
-
-	json.NewEncoder(w).Encode(map[string]string{
-		"status":  "success",
-		"message": fmt.Sprintf("Товар с ID %d успешно обновлен в базе %s", id, dbName),
-	})
-}
-
-// handleDelete обрабатывает DELETE запросы (удаление товара)
-func (h *APIHandler) handleDelete(w http.ResponseWriter, r *http.Request, dbName, resource string, pathParts []string) {
-	if resource != "products" || len(pathParts) <= 2 {
-		http.Error(w, "Неверный путь запроса", http.StatusBadRequest)
-		return
-	}
-
-	id, err := strconv.Atoi(pathParts[2])
-	if err != nil {
-		http.Error(w, "Неверный формат идентификатора", http.StatusBadRequest)
-		return
-	}
-
-	// Удаляем товар из соответствующей БД
-	switch dbName {
-	case "products_db":
-		err = h.dbManager.MongoDB.DeleteProduct(id)
-	case "suppliers_db":
-		err = h.dbManager.PostgresDB.DeleteProduct(id)
-	case "inventory_db":
-		err = h.dbManager.MySQLDB.DeleteProduct(id)
-	}
-
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
-		return
-	}
-
-	json.NewEncoder(w).Encode(map[string]string{
-		"status":  "success",
-		"message": fmt.Sprintf("Товар с ID %d успешно удален из базы %s", id, dbName),
-	})
-}
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"project/internal/changefeed"
+	"project/internal/config"
+	"project/internal/models"
+	"project/internal/replication"
+	"project/internal/storage"
+	"project/internal/webhooks"
+)
+
+// APIHandler обрабатывает API запросы
+type APIHandler struct {
+	dbManager *storage.DBManager
+	// replicator переносит изменения ведущей базы в ведомые (nil, если репликация не настроена)
+	replicator *replication.Replicator
+	// feed лента изменений товаров всех баз: из нее изменения получают SSE, WebSocket, репликация и вебхуки
+	feed *changefeed.Feed
+	// unsubscribe отменяет подписку обработчика на ленту изменений
+	unsubscribe func()
+	// events рассылает изменения товаров подписчикам SSE
+	events *eventBroker
+	// webhooks доставляет уведомления об изменениях товаров подписчикам (nil, если вебхуки отключены)
+	webhooks *webhooks.Dispatcher
+	// imports параметры импорта прайс-листов
+	imports config.ImportConfig
+}
+
+// NewAPIHandler создает новый обработчик API и подписывает его на ленту изменений
+func NewAPIHandler(dbManager *storage.DBManager, feed *changefeed.Feed, replicator *replication.Replicator, dispatcher *webhooks.Dispatcher, imports config.ImportConfig) *APIHandler {
+	h := &APIHandler{dbManager: dbManager, feed: feed, replicator: replicator, events: newEventBroker(), webhooks: dispatcher, imports: imports}
+
+	changes, unsubscribe := feed.Subscribe(changeBufferSize)
+	h.unsubscribe = unsubscribe
+	go h.consumeChanges(changes)
+	return h
+}
+
+// Close отменяет подписку на ленту изменений и завершает открытые потоки событий,
+// чтобы сервер мог остановиться, не дожидаясь клиентов
+func (h *APIHandler) Close() {
+	h.unsubscribe()
+	h.events.close()
+}
+
+// ServeHTTP обрабатывает HTTP запросы
+func (h *APIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Парсим путь запроса
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) < 2 {
+		http.Error(w, "Неверный путь запроса", http.StatusBadRequest)
+		return
+	}
+
+	dbName := pathParts[0]
+	resource := pathParts[1]
+
+	// Находим хранилище запрошенной базы данных в реестре
+	store, err := h.dbManager.Store(dbName)
+
+	// Подписки на вебхуки хранятся отдельно от товаров и не требуют подключения к базе,
+	// но база должна быть описана в конфигурации
+	if resource == "webhooks" && !errors.Is(err, storage.ErrUnknownDatabase) {
+		h.handleWebhooks(w, r, dbName, pathParts)
+		return
+	}
+
+	if err != nil {
+		var unavailable *storage.UnavailableError
+		if errors.As(err, &unavailable) {
+			writeUnavailable(w, unavailable)
+			return
+		}
+		http.Error(w, "База данных не найдена", http.StatusNotFound)
+		return
+	}
+
+	// Обрабатываем запрос в зависимости от метода HTTP
+	switch r.Method {
+	case http.MethodGet:
+		h.handleGet(w, r, store, dbName, resource, pathParts)
+	case http.MethodPost:
+		h.handlePost(w, r, store, dbName, resource, pathParts)
+	case http.MethodPut:
+		h.handlePut(w, r, store, dbName, resource, pathParts)
+	case http.MethodPatch:
+		h.handlePatch(w, r, store, dbName, resource, pathParts)
+	case http.MethodDelete:
+		h.handleDelete(w, r, store, dbName, resource, pathParts)
+	default:
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+	}
+}
+
+// writeUnavailable отвечает 503, если база данных описана в конфигурации, но к ней еще не удалось подключиться
+func writeUnavailable(w http.ResponseWriter, err *storage.UnavailableError) {
+	// Подсказываем клиенту, когда будет следующая попытка подключения
+	if retryAfter := time.Until(err.RetryAt); retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	}
+
+	w.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":   "error",
+		"database": err.Name,
+		"message":  fmt.Sprintf("База данных %s временно недоступна, выполняется повторное подключение", err.Name),
+		"error":    fmt.Sprint(err.Err),
+	})
+}
+
+// handleDatabases возвращает список зарегистрированных баз данных
+func (h *APIHandler) handleDatabases(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.dbManager.Names())
+}
+
+// handleGet обрабатывает GET запросы
+func (h *APIHandler) handleGet(w http.ResponseWriter, r *http.Request, store storage.ProductStore, dbName, resource string, pathParts []string) {
+	if resource != "products" {
+		http.Error(w, "Ресурс не найден", http.StatusNotFound)
+		return
+	}
+
+	// Полнотекстовый поиск товаров
+	if len(pathParts) > 2 && pathParts[2] == "search" {
+		h.handleSearch(w, r, store)
+		return
+	}
+
+	// Поток событий изменения товаров
+	if len(pathParts) > 2 && pathParts[2] == "events" {
+		h.handleEvents(w, r, dbName)
+		return
+	}
+
+	// Если в пути есть идентификатор - возвращаем один товар
+	if len(pathParts) > 2 {
+		id, err := strconv.Atoi(pathParts[2])
+		if err != nil {
+			http.Error(w, "Неверный формат идентификатора", http.StatusBadRequest)
+			return
+		}
+
+		product, exists, getErr := store.GetProduct(id)
+
+		if getErr != nil {
+			http.Error(w, "Ошибка при получении товара: "+getErr.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if !exists {
+			http.Error(w, "Товар не найден", http.StatusNotFound)
+			return
+		}
+
+		setETag(w, product.Version)
+		json.NewEncoder(w).Encode(product)
+		return
+	}
+
+	// Возвращаем страницу списка товаров с учетом фильтров и сортировки
+	query, err := parseProductQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Выгрузка списка в CSV, NDJSON или XLSX
+	w.Header().Add("Vary", "Accept")
+	format, err := exportFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if format != "" {
+		h.handleExport(w, r, store, dbName, query, format)
+		return
+	}
+
+	page, err := store.ListProducts(query)
+	if err != nil {
+		http.Error(w, "Ошибка при получении товаров: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	setPageHeaders(w, r, query, page.Total, len(page.Products))
+	json.NewEncoder(w).Encode(page.Products)
+}
+
+// handlePost обрабатывает POST запросы (создание нового товара, пакет операций, импорт прайс-листа)
+func (h *APIHandler) handlePost(w http.ResponseWriter, r *http.Request, store storage.ProductStore, dbName, resource string, pathParts []string) {
+	if resource != "products" {
+		http.Error(w, "Ресурс не найден", http.StatusNotFound)
+		return
+	}
+
+	if len(pathParts) > 2 {
+		switch pathParts[2] {
+		case "_bulk":
+			h.handleBulk(w, r, store, dbName)
+		case "_import":
+			h.handleImport(w, r, store, dbName)
+		default:
+			http.Error(w, "Ресурс не найден", http.StatusNotFound)
+		}
+		return
+	}
+
+	var product models.Product
+	if err := json.NewDecoder(r.Body).Decode(&product); err != nil {
+		http.Error(w, "Ошибка чтения данных: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Добавляем товар в выбранную БД; если ID не указан, его назначает база
+	created, err := h.createProduct(store, dbName, product)
+	if err != nil {
+		var duplicate *storage.DuplicateError
+		if errors.As(err, &duplicate) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if storage.IsSchemaViolation(err) {
+			http.Error(w, "Товар не соответствует схеме коллекции: "+err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		http.Error(w, "Ошибка при создании товара: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/%s/products/%d", dbName, created.ID))
+	setETag(w, created.Version)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{
+		"status":  "success",
+		"id":      created.ID,
+		"message": fmt.Sprintf("Товар с ID %d успешно создан в базе %s", created.ID, dbName),
+	})
+}
+
+// handlePut обрабатывает PUT запросы (обновление товара)
+func (h *APIHandler) handlePut(w http.ResponseWriter, r *http.Request, store storage.ProductStore, dbName, resource string, pathParts []string) {
+	if resource != "products" || len(pathParts) <= 2 {
+		http.Error(w, "Неверный путь запроса", http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.Atoi(pathParts[2])
+	if err != nil {
+		http.Error(w, "Неверный формат идентификатора", http.StatusBadRequest)
+		return
+	}
+
+	var product models.Product
+	if err := json.NewDecoder(r.Body).Decode(&product); err != nil {
+		http.Error(w, "Ошибка чтения данных: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Удостоверимся, что ID в пути и в теле запроса совпадают
+	if product.ID != id {
+		http.Error(w, "ID в пути и в теле запроса не совпадают", http.StatusBadRequest)
+		return
+	}
+
+	// Версия, которую клиент видел перед изменением (заголовок If-Match)
+	version, err := ifMatchVersion(r, store, id)
+	if err != nil {
+		writeChangeError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	// Обновляем товар в выбранной БД
+	updated, err := h.updateProduct(store, dbName, product, version)
+	if err != nil {
+		writeChangeError(w, err, http.StatusNotFound)
+		return
+	}
+
+	setETag(w, updated.Version)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "success",
+		"message": fmt.Sprintf("Товар с ID %d успешно обновлен в базе %s", id, dbName),
+	})
+}
+
+// handleDelete обрабатывает DELETE запросы (удаление товара)
+func (h *APIHandler) handleDelete(w http.ResponseWriter, r *http.Request, store storage.ProductStore, dbName, resource string, pathParts []string) {
+	if resource != "products" || len(pathParts) <= 2 {
+		http.Error(w, "Неверный путь запроса", http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.Atoi(pathParts[2])
+	if err != nil {
+		http.Error(w, "Неверный формат идентификатора", http.StatusBadRequest)
+		return
+	}
+
+	version, err := ifMatchVersion(r, store, id)
+	if err != nil {
+		writeChangeError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	// Удаляем товар из выбранной БД
+	if err := h.deleteProduct(store, dbName, id, version); err != nil {
+		writeChangeError(w, err, http.StatusNotFound)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "success",
+		"message": fmt.Sprintf("Товар с ID %d успешно удален из базы %s", id, dbName),
+	})
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"project/internal/storage"
+)
+
+// readinessTimeout максимальное время проверки одной базы данных в /readyz
+const readinessTimeout = 2 * time.Second
+
+// handleHealthz сообщает, что процесс жив (без обращения к базам данных)
+func (h *APIHandler) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// handleReadyz проверяет доступность всех баз данных.
+// Ответ 503 возвращается, если недоступна хотя бы одна обязательная база;
+// недоступность необязательной базы отмечается статусом degraded
+func (h *APIHandler) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	databases := h.dbManager.CheckHealth(r.Context(), readinessTimeout)
+
+	status := "ok"
+	code := http.StatusOK
+	for _, db := range databases {
+		if db.Status == storage.StatusUp {
+			continue
+		}
+		if db.Required {
+			status = "unavailable"
+			code = http.StatusServiceUnavailable
+			break
+		}
+		status = "degraded"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    status,
+		"databases": databases,
+	})
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"project/internal/catalog"
+	"project/internal/storage"
+)
+
+// maxImportSize наибольший размер загружаемого прайс-листа
+const maxImportSize = 32 << 20
+
+// handleImport обрабатывает POST /{db}/products/_import: тело запроса - файл прайс-листа CSV или XLSX.
+// Параметры: format (csv, xlsx; по умолчанию по Content-Type или содержимому), sheet - лист XLSX,
+// column=поле:Заголовок - дополнительное сопоставление столбца (можно повторять), supplier - поставщик
+// для строк без поставщика, mode (upsert, create), dry_run=true - только план без записи в базу,
+// atomic=true - загрузить все строки или ни одной
+func (h *APIHandler) handleImport(w http.ResponseWriter, r *http.Request, store storage.ProductStore, dbName string) {
+	query := r.URL.Query()
+
+	columns, err := catalog.ParseColumns(query["column"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := catalog.CheckMode(query.Get("mode")); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	format := query.Get("format")
+	if format == "" {
+		format = catalog.DetectFormat(r.Header.Get("Content-Type"), "")
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxImportSize)
+	sheet, err := catalog.Parse(body, catalog.Options{
+		Format:   format,
+		Columns:  catalog.MergeColumns(columns, h.imports.Columns),
+		Sheet:    query.Get("sheet"),
+		Supplier: query.Get("supplier"),
+	})
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Файл больше 32 МБ", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Ошибка разбора прайс-листа: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := catalog.Plan(store, sheet, query.Get("mode"))
+	if err != nil {
+		http.Error(w, "Ошибка чтения товаров: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	report.DryRun = query.Get("dry_run") == "true"
+	atomic := query.Get("atomic") == "true"
+	if !report.DryRun {
+		err := report.Apply(func(operations []storage.BulkOperation, atomic bool) ([]storage.BulkResult, error) {
+			return h.bulkWrite(store, dbName, operations, atomic)
+		}, atomic)
+		if errors.Is(err, catalog.ErrAtomicTooLarge) {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if errors.Is(err, storage.ErrAtomicNotSupported) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Ошибка записи товаров: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	code := http.StatusOK
+	if report.Status() == "error" && !report.DryRun {
+		code = http.StatusUnprocessableEntity
+	}
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(struct {
+		Status string `json:"status"`
+		*catalog.Report
+	}{report.Status(), report})
+}
@@ -0,0 +1,326 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"project/internal/models"
+	"project/internal/storage"
+)
+
+// Форматы частичного изменения товара
+const (
+	mergePatchType = "application/merge-patch+json"
+	jsonPatchType  = "application/json-patch+json"
+)
+
+// Ограничения PATCH
+const (
+	maxPatchSize = 1 << 20
+	// patchAttempts количество попыток применить изменение, если товар параллельно изменили (без If-Match)
+	patchAttempts = 5
+)
+
+// patchError ошибка применения изменения с кодом ответа:
+// 400 - неверный формат изменения, 409 - изменение неприменимо к товару (путь не существует, не прошла проверка test),
+// 422 - результат изменения не является корректным товаром
+type patchError struct {
+	status  int
+	message string
+}
+
+func (e *patchError) Error() string {
+	return e.message
+}
+
+func patchErrorf(status int, format string, args ...any) error {
+	return &patchError{status: status, message: fmt.Sprintf(format, args...)}
+}
+
+// jsonPatchOperation операция JSON Patch (RFC 6902)
+type jsonPatchOperation struct {
+	Op    string           `json:"op"`
+	Path  string           `json:"path"`
+	From  string           `json:"from"`
+	Value *json.RawMessage `json:"value"`
+}
+
+// patchDocument товар в виде JSON-объекта, к которому применяется изменение.
+// Товар - плоский объект, поэтому пути изменений указывают на его поля верхнего уровня
+type patchDocument map[string]json.RawMessage
+
+// pointerField разбирает JSON Pointer (RFC 6901) вида /поле
+func pointerField(pointer string) (string, error) {
+	if pointer == "" {
+		return "", patchErrorf(http.StatusUnprocessableEntity, "путь \"\" указывает на весь товар, а не на его поле")
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return "", patchErrorf(http.StatusBadRequest, "путь %q должен начинаться с /", pointer)
+	}
+	field := pointer[1:]
+	if strings.Contains(field, "/") {
+		return "", patchErrorf(http.StatusConflict, "путь %q не существует: у полей товара нет вложенных значений", pointer)
+	}
+	return strings.NewReplacer("~1", "/", "~0", "~").Replace(field), nil
+}
+
+// applyMergePatch применяет JSON Merge Patch (RFC 7396): поля изменения заменяют поля товара, null удаляет поле
+func (d patchDocument) applyMergePatch(patch []byte) error {
+	var changes map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &changes); err != nil || changes == nil {
+		return patchErrorf(http.StatusBadRequest, "изменение в формате %s должно быть JSON-объектом", mergePatchType)
+	}
+	for field, value := range changes {
+		if string(value) == "null" {
+			delete(d, field)
+			continue
+		}
+		d[field] = value
+	}
+	return nil
+}
+
+// applyJSONPatch применяет операции JSON Patch (RFC 6902) по порядку; при ошибке любой операции изменение не применяется
+func (d patchDocument) applyJSONPatch(patch []byte) error {
+	var operations []jsonPatchOperation
+	if err := json.Unmarshal(patch, &operations); err != nil {
+		return patchErrorf(http.StatusBadRequest, "изменение в формате %s должно быть массивом операций", jsonPatchType)
+	}
+
+	for i, operation := range operations {
+		if operation.Path == "" {
+			if err := d.applyRootOperation(i, operation); err != nil {
+				return err
+			}
+			continue
+		}
+		field, err := pointerField(operation.Path)
+		if err != nil {
+			return err
+		}
+		current, exists := d[field]
+
+		switch operation.Op {
+		case "add", "replace", "test":
+			if operation.Value == nil {
+				return patchErrorf(http.StatusBadRequest, "операция %d (%s): не указано значение value", i, operation.Op)
+			}
+			if operation.Op != "add" && !exists {
+				return patchErrorf(http.StatusConflict, "операция %d (%s): поле %s не существует", i, operation.Op, operation.Path)
+			}
+			if operation.Op == "test" {
+				if !jsonEqual(current, *operation.Value) {
+					return patchErrorf(http.StatusConflict, "операция %d (test): значение поля %s не совпадает", i, operation.Path)
+				}
+				continue
+			}
+			d[field] = *operation.Value
+
+		case "remove":
+			if !exists {
+				return patchErrorf(http.StatusConflict, "операция %d (remove): поле %s не существует", i, operation.Path)
+			}
+			delete(d, field)
+
+		case "move", "copy":
+			from, err := pointerField(operation.From)
+			if err != nil {
+				return err
+			}
+			value, ok := d[from]
+			if !ok {
+				return patchErrorf(http.StatusConflict, "операция %d (%s): поле %s не существует", i, operation.Op, operation.From)
+			}
+			if operation.Op == "move" {
+				delete(d, from)
+			}
+			d[field] = value
+
+		default:
+			return patchErrorf(http.StatusBadRequest, "операция %d: неизвестная операция %q", i, operation.Op)
+		}
+	}
+	return nil
+}
+
+// applyRootOperation применяет операцию с путем "" (весь товар): test сравнивает товар целиком,
+// add и replace заменяют его объектом value. Остальные операции не оставляют на месте товара объект
+func (d patchDocument) applyRootOperation(i int, operation jsonPatchOperation) error {
+	switch operation.Op {
+	case "add", "replace", "test":
+		if operation.Value == nil {
+			return patchErrorf(http.StatusBadRequest, "операция %d (%s): не указано значение value", i, operation.Op)
+		}
+		if operation.Op == "test" {
+			current, err := json.Marshal(d)
+			if err != nil {
+				return err
+			}
+			if !jsonEqual(current, *operation.Value) {
+				return patchErrorf(http.StatusConflict, "операция %d (test): товар не совпадает", i)
+			}
+			return nil
+		}
+		var document patchDocument
+		if err := json.Unmarshal(*operation.Value, &document); err != nil || document == nil {
+			return patchErrorf(http.StatusUnprocessableEntity, "операция %d (%s): товар целиком можно заменить только JSON-объектом", i, operation.Op)
+		}
+		clear(d)
+		maps.Copy(d, document)
+		return nil
+
+	case "remove", "move", "copy":
+		return patchErrorf(http.StatusUnprocessableEntity, "операция %d (%s): товар целиком можно только проверить (test) или заменить (add, replace)", i, operation.Op)
+
+	default:
+		return patchErrorf(http.StatusBadRequest, "операция %d: неизвестная операция %q", i, operation.Op)
+	}
+}
+
+// jsonEqual сравнивает JSON-значения без учета форматирования (1.0 и 1 равны)
+func jsonEqual(a, b json.RawMessage) bool {
+	var left, right any
+	if json.Unmarshal(a, &left) != nil || json.Unmarshal(b, &right) != nil {
+		return false
+	}
+	return reflect.DeepEqual(left, right)
+}
+
+// applyPatch применяет изменение формата contentType к товару и возвращает измененный товар
+func applyPatch(product models.Product, contentType string, patch []byte) (models.Product, error) {
+	data, err := json.Marshal(product)
+	if err != nil {
+		return models.Product{}, err
+	}
+	var document patchDocument
+	if err := json.Unmarshal(data, &document); err != nil {
+		return models.Product{}, err
+	}
+
+	if contentType == mergePatchType {
+		err = document.applyMergePatch(patch)
+	} else {
+		err = document.applyJSONPatch(patch)
+	}
+	if err != nil {
+		return models.Product{}, err
+	}
+
+	// Удаленные поля получают нулевые значения, неизвестные поля и значения другого типа отклоняются
+	data, err = json.Marshal(document)
+	if err != nil {
+		return models.Product{}, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var patched models.Product
+	if err := decoder.Decode(&patched); err != nil {
+		return models.Product{}, patchErrorf(http.StatusUnprocessableEntity, "результат изменения не является товаром: %v", err)
+	}
+
+	if patched.ID != product.ID || patched.Version != product.Version {
+		return models.Product{}, patchErrorf(http.StatusUnprocessableEntity, "поля id и version изменять нельзя")
+	}
+	return patched, nil
+}
+
+// handlePatch обрабатывает PATCH запросы (частичное изменение товара) в форматах
+// application/merge-patch+json и application/json-patch+json. В базе изменяются только поля,
+// значения которых изменились, при условии, что товар не изменили с момента чтения
+func (h *APIHandler) handlePatch(w http.ResponseWriter, r *http.Request, store storage.ProductStore, dbName, resource string, pathParts []string) {
+	if resource != "products" || len(pathParts) <= 2 {
+		http.Error(w, "Неверный путь запроса", http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.Atoi(pathParts[2])
+	if err != nil {
+		http.Error(w, "Неверный формат идентификатора", http.StatusBadRequest)
+		return
+	}
+
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType != mergePatchType && contentType != jsonPatchType {
+		w.Header().Set("Accept-Patch", mergePatchType+", "+jsonPatchType)
+		http.Error(w, fmt.Sprintf("Тело запроса должно иметь тип %s или %s", mergePatchType, jsonPatchType), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	patch, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPatchSize))
+	if err != nil {
+		http.Error(w, "Ошибка чтения данных: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	version, err := ifMatchVersion(r, store, id)
+	if err != nil {
+		writeChangeError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	for attempt := 0; attempt < patchAttempts; attempt++ {
+		current, exists, err := store.GetProduct(id)
+		if err != nil {
+			http.Error(w, "Ошибка при получении товара: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !exists {
+			http.Error(w, "Товар не найден", http.StatusNotFound)
+			return
+		}
+		if version != 0 && current.Version != version {
+			writeChangeError(w, &storage.VersionConflictError{ID: id, Expected: version, Current: current.Version}, http.StatusPreconditionFailed)
+			return
+		}
+
+		patched, err := applyPatch(current, contentType, patch)
+		if err != nil {
+			var invalid *patchError
+			if errors.As(err, &invalid) {
+				http.Error(w, invalid.message, invalid.status)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Изменение, не меняющее значений (например, только test), не создает новую версию
+		fields := storage.ChangedFields(current, patched)
+		if len(fields) == 0 {
+			setETag(w, current.Version)
+			json.NewEncoder(w).Encode(current)
+			return
+		}
+
+		// Изменение рассчитано по прочитанной версии товара и записывается, только если она не изменилась
+		updated, err := h.patchProduct(store, dbName, patched, fields, current.Version)
+		var conflict *storage.VersionConflictError
+		if errors.As(err, &conflict) && version == 0 {
+			continue
+		}
+		if err != nil {
+			// Товар могли удалить после чтения
+			if _, exists, getErr := store.GetProduct(id); getErr == nil && !exists {
+				http.Error(w, "Товар не найден", http.StatusNotFound)
+				return
+			}
+			writeChangeError(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		setETag(w, updated.Version)
+		json.NewEncoder(w).Encode(updated)
+		return
+	}
+
+	http.Error(w, "Товар одновременно изменяется другими запросами, повторите попытку", http.StatusConflict)
+}
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"project/internal/models"
+)
+
+func TestApplyPatch(t *testing.T) {
+	product := models.Product{
+		ID:          7,
+		Name:        "Чайник",
+		Category:    "Кухня",
+		Price:       1500,
+		Description: "Стеклянный",
+		InStock:     true,
+		Supplier:    "Альфа",
+		Version:     3,
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		patch       string
+		// status код ошибки patchError; 0 - изменение применяется
+		status int
+		want   func(p *models.Product)
+	}{
+		{
+			name:        "merge: изменение цены",
+			contentType: mergePatchType,
+			patch:       `{"price": 1990.5}`,
+			want:        func(p *models.Product) { p.Price = 1990.5 },
+		},
+		{
+			name:        "merge: null обнуляет поле",
+			contentType: mergePatchType,
+			patch:       `{"description": null, "in_stock": false}`,
+			want: func(p *models.Product) {
+				p.Description = ""
+				p.InStock = false
+			},
+		},
+		{
+			name:        "merge: не объект",
+			contentType: mergePatchType,
+			patch:       `[1, 2]`,
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "merge: неизвестное поле",
+			contentType: mergePatchType,
+			patch:       `{"color": "red"}`,
+			status:      http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "merge: значение другого типа",
+			contentType: mergePatchType,
+			patch:       `{"price": "дорого"}`,
+			status:      http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "merge: изменение id",
+			contentType: mergePatchType,
+			patch:       `{"id": 8}`,
+			status:      http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "merge: изменение version",
+			contentType: mergePatchType,
+			patch:       `{"version": 4}`,
+			status:      http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "json patch: test и replace",
+			contentType: jsonPatchType,
+			patch:       `[{"op": "test", "path": "/price", "value": 1500.0}, {"op": "replace", "path": "/name", "value": "Термос"}]`,
+			want:        func(p *models.Product) { p.Name = "Термос" },
+		},
+		{
+			name:        "json patch: copy и remove",
+			contentType: jsonPatchType,
+			patch:       `[{"op": "copy", "from": "/name", "path": "/description"}, {"op": "remove", "path": "/supplier"}]`,
+			want: func(p *models.Product) {
+				p.Description = "Чайник"
+				p.Supplier = ""
+			},
+		},
+		{
+			name:        "json patch: test не прошел",
+			contentType: jsonPatchType,
+			patch:       `[{"op": "test", "path": "/price", "value": 1}, {"op": "replace", "path": "/name", "value": "Термос"}]`,
+			status:      http.StatusConflict,
+		},
+		{
+			name:        "json patch: replace отсутствующего поля",
+			contentType: jsonPatchType,
+			patch:       `[{"op": "replace", "path": "/color", "value": "red"}]`,
+			status:      http.StatusConflict,
+		},
+		{
+			name:        "json patch: вложенный путь",
+			contentType: jsonPatchType,
+			patch:       `[{"op": "add", "path": "/name/0", "value": "x"}]`,
+			status:      http.StatusConflict,
+		},
+		{
+			name:        "json patch: путь без /",
+			contentType: jsonPatchType,
+			patch:       `[{"op": "add", "path": "name", "value": "x"}]`,
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "json patch: test всего товара",
+			contentType: jsonPatchType,
+			patch:       `[{"op": "test", "path": "", "value": {"id": 7, "name": "Чайник", "category": "Кухня", "price": 1500, "description": "Стеклянный", "in_stock": true, "supplier": "Альфа", "version": 3}}]`,
+			want:        func(p *models.Product) {},
+		},
+		{
+			name:        "json patch: test всего товара не прошел",
+			contentType: jsonPatchType,
+			patch:       `[{"op": "test", "path": "", "value": {"id": 7}}]`,
+			status:      http.StatusConflict,
+		},
+		{
+			name:        "json patch: replace всего товара",
+			contentType: jsonPatchType,
+			patch:       `[{"op": "replace", "path": "", "value": {"id": 7, "name": "Термос", "price": 990, "version": 3}}]`,
+			want: func(p *models.Product) {
+				*p = models.Product{ID: 7, Name: "Термос", Price: 990, Version: 3}
+			},
+		},
+		{
+			name:        "json patch: replace всего товара не объектом",
+			contentType: jsonPatchType,
+			patch:       `[{"op": "replace", "path": "", "value": [1]}]`,
+			status:      http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "json patch: remove всего товара",
+			contentType: jsonPatchType,
+			patch:       `[{"op": "remove", "path": ""}]`,
+			status:      http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "json patch: copy всего товара в поле",
+			contentType: jsonPatchType,
+			patch:       `[{"op": "copy", "from": "", "path": "/description"}]`,
+			status:      http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "json patch: нет value",
+			contentType: jsonPatchType,
+			patch:       `[{"op": "replace", "path": "/name"}]`,
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "json patch: неизвестная операция",
+			contentType: jsonPatchType,
+			patch:       `[{"op": "increment", "path": "/price", "value": 1}]`,
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "json patch: не массив",
+			contentType: jsonPatchType,
+			patch:       `{"op": "replace"}`,
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "json patch: add неизвестного поля",
+			contentType: jsonPatchType,
+			patch:       `[{"op": "add", "path": "/color", "value": "red"}]`,
+			status:      http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "json patch: move в id",
+			contentType: jsonPatchType,
+			patch:       `[{"op": "move", "from": "/version", "path": "/id"}]`,
+			status:      http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyPatch(product, tt.contentType, []byte(tt.patch))
+			if tt.status != 0 {
+				var patchErr *patchError
+				if !errors.As(err, &patchErr) {
+					t.Fatalf("ожидалась ошибка с кодом %d, получено %v", tt.status, err)
+				}
+				if patchErr.status != tt.status {
+					t.Fatalf("код ошибки %d, ожидался %d: %v", patchErr.status, tt.status, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("неожиданная ошибка: %v", err)
+			}
+			want := product
+			tt.want(&want)
+			if got != want {
+				t.Fatalf("получено %+v, ожидалось %+v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"project/internal/storage"
+)
+
+// Размер страницы списка товаров без параметра limit и наибольший размер страницы.
+// Все товары без ограничения выдает только выгрузка (export)
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+// parseProductQuery разбирает параметры списка товаров:
+// limit, offset, sort=price,-name и фильтры category, supplier, in_stock, min_price, max_price.
+// Без limit возвращается страница из defaultPageLimit товаров
+func parseProductQuery(values url.Values) (storage.ProductQuery, error) {
+	query := storage.ProductQuery{
+		Limit:    defaultPageLimit,
+		Category: values.Get("category"),
+		Supplier: values.Get("supplier"),
+	}
+
+	if value := values.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return query, fmt.Errorf("параметр limit должен быть целым числом от 1 до %d", maxPageLimit)
+		}
+		query.Limit = limit
+	}
+
+	if value := values.Get("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return query, fmt.Errorf("параметр offset должен быть неотрицательным целым числом")
+		}
+		query.Offset = offset
+	}
+
+	if value := values.Get("sort"); value != "" {
+		sort, err := storage.ParseSort(value)
+		if err != nil {
+			return query, err
+		}
+		query.Sort = sort
+	}
+
+	if value := values.Get("in_stock"); value != "" {
+		inStock, err := strconv.ParseBool(value)
+		if err != nil {
+			return query, fmt.Errorf("параметр in_stock должен быть true или false")
+		}
+		query.InStock = &inStock
+	}
+
+	for _, param := range []struct {
+		name  string
+		value **float64
+	}{
+		{"min_price", &query.MinPrice},
+		{"max_price", &query.MaxPrice},
+	} {
+		value := values.Get(param.name)
+		if value == "" {
+			continue
+		}
+		price, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return query, fmt.Errorf("параметр %s должен быть числом", param.name)
+		}
+		*param.value = &price
+	}
+
+	return query, nil
+}
+
+// setPageHeaders добавляет в ответ метаданные страницы:
+// X-Total-Count - общее количество товаров, Link - ссылки на следующую и предыдущую страницы
+func setPageHeaders(w http.ResponseWriter, r *http.Request, query storage.ProductQuery, total int64, count int) {
+	w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
+
+	pageURL := func(offset int) string {
+		values := r.URL.Query()
+		values.Set("offset", strconv.Itoa(offset))
+		values.Set("limit", strconv.Itoa(query.Limit))
+		return r.URL.Path + "?" + values.Encode()
+	}
+
+	var links []string
+	if next := query.Offset + count; int64(next) < total && count > 0 {
+		links = append(links, fmt.Sprintf(`<%s>; rel="next"`, pageURL(next)))
+	}
+	if query.Offset > 0 {
+		prev := query.Offset - query.Limit
+		if prev < 0 {
+			prev = 0
+		}
+		links = append(links, fmt.Sprintf(`<%s>; rel="prev"`, pageURL(prev)))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestParseProductQueryPage(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		limit  int
+		offset int
+		// invalid параметры страницы отклоняются
+		invalid bool
+	}{
+		{name: "без параметров", query: "", limit: defaultPageLimit},
+		{name: "только offset", query: "offset=200", limit: defaultPageLimit, offset: 200},
+		{name: "явный limit", query: "limit=10&offset=20", limit: 10, offset: 20},
+		{name: "наибольший limit", query: "limit=1000", limit: maxPageLimit},
+		{name: "limit больше наибольшего", query: "limit=1001", invalid: true},
+		{name: "нулевой limit", query: "limit=0", invalid: true},
+		{name: "отрицательный offset", query: "offset=-1", invalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			query, err := parseProductQuery(values)
+			if tt.invalid {
+				if err == nil {
+					t.Fatalf("ожидалась ошибка, получено limit=%d offset=%d", query.Limit, query.Offset)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("неожиданная ошибка: %v", err)
+			}
+			if query.Limit != tt.limit || query.Offset != tt.offset {
+				t.Fatalf("получено limit=%d offset=%d, ожидалось limit=%d offset=%d", query.Limit, query.Offset, tt.limit, tt.offset)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"project/internal/storage"
+)
+
+// handleReconciliation выполняет сверку товаров между базами данных и возвращает отчет.
+// Параметры: key=id|name_supplier, databases=products_db,suppliers_db, format=json|csv
+// (формат также выбирается заголовком Accept: text/csv)
+func (h *APIHandler) handleReconciliation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	opts := storage.ReconcileOptions{Key: r.URL.Query().Get("key")}
+	if value := r.URL.Query().Get("databases"); value != "" {
+		opts.Databases = strings.Split(value, ",")
+	}
+
+	report, err := h.dbManager.Reconcile(r.Context(), opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeReconcileReport(w, r, report)
+}
+
+// handleLatestReconciliation возвращает отчет последней фоновой сверки
+func (h *APIHandler) handleLatestReconciliation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	report := h.dbManager.LastReconcileReport()
+	if report == nil {
+		http.Error(w, "Фоновая сверка еще не выполнялась или отключена в конфигурации", http.StatusNotFound)
+		return
+	}
+
+	writeReconcileReport(w, r, *report)
+}
+
+// wantsCSV сообщает, запросил ли клиент отчет в формате CSV
+func wantsCSV(r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format == "csv"
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/csv")
+}
+
+// writeReconcileReport отправляет отчет сверки в формате JSON или CSV
+func writeReconcileReport(w http.ResponseWriter, r *http.Request, report storage.ReconcileReport) {
+	if !wantsCSV(r) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="reconciliation-%s.csv"`, report.GeneratedAt.Format("20060102-150405")))
+
+	// Одна строка на каждое различающееся поле; для отсутствующих записей поле не заполняется
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"key", "type", "present_in", "missing_in", "field", "values"})
+	for _, issue := range report.Issues {
+		present := strings.Join(issue.PresentIn, ";")
+		missing := strings.Join(issue.MissingIn, ";")
+		if len(issue.Differences) == 0 {
+			writer.Write([]string{issue.Key, issue.Type, present, missing, "", ""})
+			continue
+		}
+		for _, difference := range issue.Differences {
+			writer.Write([]string{issue.Key, issue.Type, present, missing, difference.Field, formatValues(difference.Values)})
+		}
+	}
+	// Базы, не участвовавшие в сверке из-за ошибок, отмечаются отдельными строками
+	for _, sourceErr := range report.Errors {
+		writer.Write([]string{"", "error", "", sourceErr.Database, "", sourceErr.Error})
+	}
+	writer.Flush()
+}
+
+// formatValues записывает значения поля по базам в виде "база=значение; ..."
+func formatValues(values map[string]string) string {
+	sources := make([]string, 0, len(values))
+	for source := range values {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	parts := make([]string, len(sources))
+	for i, source := range sources {
+		parts[i] = source + "=" + values[source]
+	}
+	return strings.Join(parts, "; ")
+}
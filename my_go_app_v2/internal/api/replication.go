@@ -0,0 +1,54 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// handleReplicationStatus возвращает состояние репликации: для каждой ведомой базы
+// количество ожидающих и неудавшихся изменений, отставание и список неудавшихся изменений
+func (h *APIHandler) handleReplicationStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.replicator == nil {
+		http.Error(w, "Репликация не настроена", http.StatusNotFound)
+		return
+	}
+
+	status, err := h.replicator.Status()
+	if err != nil {
+		http.Error(w, "Ошибка чтения очереди репликации: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(status)
+}
+
+// handleReplicationRetry возвращает неудавшиеся изменения в очередь: POST /replication/retry[?follower=имя]
+func (h *APIHandler) handleReplicationRetry(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.replicator == nil {
+		http.Error(w, "Репликация не настроена", http.StatusNotFound)
+		return
+	}
+
+	count, err := h.replicator.Retry(r.URL.Query().Get("follower"))
+	if err != nil {
+		http.Error(w, "Ошибка обновления очереди репликации: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "success",
+		"message": fmt.Sprintf("В очередь репликации возвращено изменений: %d", count),
+	})
+}
@@ -1,17 +1,107 @@
-package api
-
-import (
-	"net/http"
-
-	"project/internal/storage"
-)
-
-// SetupRoutes настраивает маршруты API
-func SetupRoutes(dbManager *storage.DBManager) {
-	apiHandler := NewAPIHandler(dbManager)
-
-	// Обрабатываем только запросы к API, начинающиеся с названия базы данных
-	http.HandleFunc("/products_db/", apiHandler.ServeHTTP)
-	http.HandleFunc("/suppliers_db/", apiHandler.ServeHTTP)
-	http.HandleFunc("/inventory_db/", apiHandler.ServeHTTP)
-}
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"project/internal/changefeed"
+	"project/internal/config"
+	"project/internal/metrics"
+	"project/internal/replication"
+	"project/internal/storage"
+	"project/internal/webhooks"
+)
+
+// SetupRoutes настраивает маршруты API и возвращает обработчик API
+// (его Close завершает открытые потоки событий при остановке сервера)
+func SetupRoutes(dbManager *storage.DBManager, feed *changefeed.Feed, replicator *replication.Replicator, dispatcher *webhooks.Dispatcher, imports config.ImportConfig) *APIHandler {
+	apiHandler := NewAPIHandler(dbManager, feed, replicator, dispatcher, imports)
+
+	// Обрабатываем только запросы к API, начинающиеся с названия зарегистрированной базы данных
+	for _, dbName := range dbManager.Names() {
+		http.Handle("/"+dbName+"/", metrics.Instrument(apiRoute, apiHandler))
+	}
+
+	// Федеративный запрос товаров сразу ко всем базам данных
+	handleInstrumented("/all/products", apiHandler.handleAllProducts)
+
+	// Сверка товаров между базами данных: отчет по запросу и результат последней фоновой сверки
+	handleInstrumented("/reconciliation", apiHandler.handleReconciliation)
+	handleInstrumented("/reconciliation/latest", apiHandler.handleLatestReconciliation)
+
+	// Состояние репликации и повторная отправка неудавшихся изменений
+	handleInstrumented("/replication", apiHandler.handleReplicationStatus)
+	handleInstrumented("/replication/retry", apiHandler.handleReplicationRetry)
+
+	// WebSocket: подписка на изменения товаров и команды над товарами
+	handleInstrumented("/ws", apiHandler.handleWebSocket)
+
+	// Список доступных баз данных для веб-интерфейса
+	handleInstrumented("/databases", apiHandler.handleDatabases)
+
+	// Проверки живости и готовности для оркестраторов и healthcheck в docker-compose
+	handleInstrumented("/healthz", apiHandler.handleHealthz)
+	handleInstrumented("/readyz", apiHandler.handleReadyz)
+
+	// Метрики в формате Prometheus
+	http.Handle("/metrics", metrics.Handler())
+
+	return apiHandler
+}
+
+// handleInstrumented регистрирует обработчик служебного маршрута со сбором метрик
+func handleInstrumented(pattern string, handler http.HandlerFunc) {
+	http.Handle(pattern, metrics.Instrument(func(*http.Request) string { return pattern }, handler))
+}
+
+// apiRoute метка маршрута API для метрик: идентификаторы товаров заменяются шаблоном,
+// чтобы количество временных рядов не зависело от данных
+func apiRoute(r *http.Request) string {
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	route := "/" + pathParts[0]
+	if len(pathParts) < 2 {
+		return route
+	}
+	if pathParts[1] == "webhooks" {
+		return route + webhookRoute(pathParts[2:])
+	}
+	if pathParts[1] != "products" {
+		return route + "/{unknown}"
+	}
+
+	route += "/products"
+	if len(pathParts) > 2 {
+		if _, err := strconv.Atoi(pathParts[2]); err == nil {
+			return route + "/{id}"
+		}
+		if pathParts[2] == "search" || pathParts[2] == "events" || pathParts[2] == "_bulk" || pathParts[2] == "_import" {
+			return route + "/" + pathParts[2]
+		}
+		return route + "/{unknown}"
+	}
+	return route
+}
+
+// webhookRoute метка маршрута подписок на вебхуки
+func webhookRoute(pathParts []string) string {
+	route := "/webhooks"
+	if len(pathParts) == 0 {
+		return route
+	}
+	if pathParts[0] == "dead-letters" {
+		route += "/dead-letters"
+	} else if _, err := strconv.ParseInt(pathParts[0], 10, 64); err == nil {
+		route += "/{id}"
+	} else {
+		return route + "/{unknown}"
+	}
+	if len(pathParts) > 1 {
+		if pathParts[1] == "retry" || pathParts[1] == "deliveries" {
+			return route + "/" + pathParts[1]
+		}
+		return route + "/{unknown}"
+	}
+	return route
+}
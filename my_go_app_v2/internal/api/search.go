@@ -0,0 +1,45 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"project/internal/storage"
+)
+
+// Количество результатов полнотекстового поиска
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// handleSearch обрабатывает полнотекстовый поиск: GET /{db}/products/search?q=цемент&limit=20.
+// Результаты упорядочены по релевантности, оценка возвращается в поле score
+func (h *APIHandler) handleSearch(w http.ResponseWriter, r *http.Request, store storage.ProductStore) {
+	text := strings.TrimSpace(r.URL.Query().Get("q"))
+	if text == "" {
+		http.Error(w, "Не задан поисковый запрос (параметр q)", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultSearchLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxSearchLimit {
+			http.Error(w, fmt.Sprintf("параметр limit должен быть целым числом от 1 до %d", maxSearchLimit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	results, err := store.SearchProducts(text, limit)
+	if err != nil {
+		http.Error(w, "Ошибка при поиске товаров: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(results)
+}
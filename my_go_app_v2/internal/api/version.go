@@ -0,0 +1,84 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"project/internal/storage"
+)
+
+// setETag передает версию товара в заголовке ETag
+func setETag(w http.ResponseWriter, version int64) {
+	w.Header().Set("ETag", `"`+strconv.FormatInt(version, 10)+`"`)
+}
+
+// ifMatchVersion возвращает версию товара, которую клиент ожидает по заголовку If-Match (0 - без проверки).
+// Слабые теги (W/"...") при сравнении для If-Match не совпадают никогда. Если в заголовке несколько тегов
+// или ни одного подходящего, он сравнивается с текущей версией товара; при несовпадении, а также если товара нет,
+// возвращается *storage.VersionConflictError
+func ifMatchVersion(r *http.Request, store storage.ProductStore, id int) (int64, error) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return 0, nil
+	}
+
+	var versions []int64
+	wildcard := false
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			wildcard = true
+			continue
+		}
+		if len(tag) < 2 || !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) {
+			continue
+		}
+		if version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64); err == nil && version > 0 {
+			versions = append(versions, version)
+		}
+	}
+
+	product, exists, err := store.GetProduct(id)
+	if err != nil {
+		return 0, err
+	}
+	// Условие If-Match для отсутствующего товара не выполняется, даже если это "*"
+	if !exists {
+		return 0, &storage.VersionConflictError{ID: id}
+	}
+	switch {
+	case wildcard:
+		return 0, nil
+	case len(versions) == 1:
+		// Версию атомарно с изменением проверит хранилище
+		return versions[0], nil
+	case slices.Contains(versions, product.Version):
+		return product.Version, nil
+	}
+	return 0, &storage.VersionConflictError{ID: id, Current: product.Version}
+}
+
+// writeChangeError отвечает на ошибку изменения товара: 412 с текущей версией в ETag,
+// если товар изменен после того, как клиент его прочитал, 422, если база отклонила товар
+// валидатором схемы, иначе status
+func writeChangeError(w http.ResponseWriter, err error, status int) {
+	var conflict *storage.VersionConflictError
+	if errors.As(err, &conflict) && conflict.Current == 0 {
+		http.Error(w, fmt.Sprintf("Товар с ID %d не найден, условие If-Match не выполнено", conflict.ID), http.StatusPreconditionFailed)
+		return
+	}
+	if errors.As(err, &conflict) {
+		setETag(w, conflict.Current)
+		http.Error(w, fmt.Sprintf("Товар с ID %d был изменен: текущая версия %d", conflict.ID, conflict.Current), http.StatusPreconditionFailed)
+		return
+	}
+	if storage.IsSchemaViolation(err) {
+		http.Error(w, "Товар не соответствует схеме коллекции: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	http.Error(w, err.Error(), status)
+}
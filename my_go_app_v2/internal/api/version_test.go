@@ -0,0 +1,90 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"project/internal/changefeed"
+	"project/internal/config"
+	"project/internal/models"
+	"project/internal/storage"
+)
+
+// newTestHandler создает обработчик с одной базой test_db (SQLite в памяти), в которой есть товар с ID 1 версии 1
+func newTestHandler(t *testing.T) *APIHandler {
+	t.Helper()
+	dbManager, err := storage.NewDBManager(&config.Config{Databases: []config.DatabaseConfig{
+		{Name: "test_db", Driver: config.DriverSQLite, DSN: ":memory:"},
+	}})
+	if err != nil {
+		t.Fatalf("открытие баз: %v", err)
+	}
+	feed := changefeed.New(dbManager)
+	h := NewAPIHandler(dbManager, feed, nil, nil, config.ImportConfig{})
+	t.Cleanup(func() {
+		h.Close()
+		feed.Close()
+		dbManager.Close()
+	})
+
+	store, err := dbManager.Store("test_db")
+	if err != nil {
+		t.Fatalf("база test_db: %v", err)
+	}
+	product := models.Product{ID: 1, Name: "Цемент", Category: "Смеси", Price: 450, InStock: true, Supplier: "Альфа"}
+	if _, err := store.AddProduct(product); err != nil {
+		t.Fatalf("добавление товара: %v", err)
+	}
+	return h
+}
+
+func TestIfMatch(t *testing.T) {
+	const product = `{"id": %d, "name": "Цемент М500", "category": "Смеси", "price": 480, "in_stock": true, "supplier": "Альфа"}`
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		ifMatch string
+		body    string
+		status  int
+		// etag ожидаемый заголовок ETag ответа ("" - не проверяется)
+		etag string
+	}{
+		{name: "PUT с текущей версией", method: http.MethodPut, path: "/test_db/products/1", ifMatch: `"1"`, body: fmt.Sprintf(product, 1), status: http.StatusOK, etag: `"2"`},
+		{name: "PUT с устаревшей версией", method: http.MethodPut, path: "/test_db/products/1", ifMatch: `"5"`, body: fmt.Sprintf(product, 1), status: http.StatusPreconditionFailed, etag: `"1"`},
+		{name: "PUT без If-Match", method: http.MethodPut, path: "/test_db/products/1", body: fmt.Sprintf(product, 1), status: http.StatusOK, etag: `"2"`},
+		{name: "PUT отсутствующего товара", method: http.MethodPut, path: "/test_db/products/2", ifMatch: `"1"`, body: fmt.Sprintf(product, 2), status: http.StatusPreconditionFailed},
+		{name: "PUT отсутствующего товара с *", method: http.MethodPut, path: "/test_db/products/2", ifMatch: `*`, body: fmt.Sprintf(product, 2), status: http.StatusPreconditionFailed},
+		{name: "PATCH с устаревшей версией", method: http.MethodPatch, path: "/test_db/products/1", ifMatch: `"5"`, body: `{"price": 480}`, status: http.StatusPreconditionFailed, etag: `"1"`},
+		{name: "DELETE с устаревшей версией", method: http.MethodDelete, path: "/test_db/products/1", ifMatch: `"5"`, status: http.StatusPreconditionFailed, etag: `"1"`},
+		{name: "DELETE с текущей версией", method: http.MethodDelete, path: "/test_db/products/1", ifMatch: `"1"`, status: http.StatusOK},
+		{name: "DELETE отсутствующего товара", method: http.MethodDelete, path: "/test_db/products/2", ifMatch: `"1"`, status: http.StatusPreconditionFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(t)
+
+			r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.method == http.MethodPatch {
+				r.Header.Set("Content-Type", mergePatchType)
+			}
+			if tt.ifMatch != "" {
+				r.Header.Set("If-Match", tt.ifMatch)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("получен код %d, ожидался %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.etag != "" && w.Header().Get("ETag") != tt.etag {
+				t.Fatalf("получен ETag %s, ожидался %s", w.Header().Get("ETag"), tt.etag)
+			}
+		})
+	}
+}
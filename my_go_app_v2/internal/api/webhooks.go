@@ -0,0 +1,192 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"project/internal/webhooks"
+)
+
+// Размер журнала доставок подписки
+const (
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 500
+)
+
+// webhookRequest тело запроса создания подписки
+type webhookRequest struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+}
+
+// handleWebhooks обрабатывает запросы к подпискам базы данных:
+//
+//	GET    /{db}/webhooks                           - список подписок
+//	POST   /{db}/webhooks                           - создание подписки
+//	DELETE /{db}/webhooks/{id}                      - удаление подписки
+//	GET    /{db}/webhooks/{id}/deliveries[?status=] - журнал доставок подписки
+//	GET    /{db}/webhooks/dead-letters              - недоставленные уведомления
+//	POST   /{db}/webhooks/dead-letters/retry[?id=]  - повторная отправка недоставленных уведомлений
+func (h *APIHandler) handleWebhooks(w http.ResponseWriter, r *http.Request, dbName string, pathParts []string) {
+	if h.webhooks == nil {
+		http.Error(w, "Вебхуки не настроены", http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case len(pathParts) == 2:
+		switch r.Method {
+		case http.MethodGet:
+			h.listWebhooks(w, dbName)
+		case http.MethodPost:
+			h.createWebhook(w, r, dbName)
+		default:
+			http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		}
+
+	case pathParts[2] == "dead-letters":
+		switch {
+		case len(pathParts) == 3 && r.Method == http.MethodGet:
+			h.listDeadLetters(w, dbName)
+		case len(pathParts) == 4 && pathParts[3] == "retry" && r.Method == http.MethodPost:
+			h.retryDeadLetters(w, r, dbName)
+		default:
+			http.Error(w, "Ресурс не найден", http.StatusNotFound)
+		}
+
+	default:
+		id, err := strconv.ParseInt(pathParts[2], 10, 64)
+		if err != nil {
+			http.Error(w, "Неверный формат идентификатора подписки", http.StatusBadRequest)
+			return
+		}
+		switch {
+		case len(pathParts) == 3 && r.Method == http.MethodDelete:
+			h.deleteWebhook(w, dbName, id)
+		case len(pathParts) == 4 && pathParts[3] == "deliveries" && r.Method == http.MethodGet:
+			h.listDeliveries(w, r, dbName, id)
+		default:
+			http.Error(w, "Ресурс не найден", http.StatusNotFound)
+		}
+	}
+}
+
+// listWebhooks возвращает подписки базы данных
+func (h *APIHandler) listWebhooks(w http.ResponseWriter, dbName string) {
+	subscriptions, err := h.webhooks.Subscriptions(dbName)
+	if err != nil {
+		http.Error(w, "Ошибка чтения подписок: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(subscriptions)
+}
+
+// createWebhook создает подписку. Ключ подписи возвращается только в ответе на этот запрос
+func (h *APIHandler) createWebhook(w http.ResponseWriter, r *http.Request, dbName string) {
+	var request webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	subscription, err := h.webhooks.Subscribe(dbName, request.URL, request.Secret, request.Events)
+	if err != nil {
+		http.Error(w, "Ошибка при создании подписки: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(subscription)
+}
+
+// deleteWebhook удаляет подписку вместе с ее очередью доставок
+func (h *APIHandler) deleteWebhook(w http.ResponseWriter, dbName string, id int64) {
+	err := h.webhooks.Unsubscribe(dbName, id)
+	if errors.Is(err, webhooks.ErrNotFound) {
+		http.Error(w, "Подписка не найдена", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Ошибка при удалении подписки: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "success",
+		"message": "Подписка успешно удалена",
+	})
+}
+
+// listDeliveries возвращает журнал последних доставок подписки с попытками: ?status=pending|delivered|dead, ?limit=
+func (h *APIHandler) listDeliveries(w http.ResponseWriter, r *http.Request, dbName string, id int64) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", webhooks.StatusPending, webhooks.StatusDelivered, webhooks.StatusDead:
+	default:
+		http.Error(w, "Параметр status должен быть pending, delivered или dead", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultDeliveriesLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxDeliveriesLimit {
+			http.Error(w, fmt.Sprintf("Параметр limit должен быть целым числом от 1 до %d", maxDeliveriesLimit), http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	deliveries, err := h.webhooks.Deliveries(dbName, id, status, limit)
+	if errors.Is(err, webhooks.ErrNotFound) {
+		http.Error(w, "Подписка не найдена", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Ошибка чтения журнала доставок: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+// listDeadLetters возвращает уведомления базы данных, не доставленные после всех попыток
+func (h *APIHandler) listDeadLetters(w http.ResponseWriter, dbName string) {
+	deliveries, err := h.webhooks.DeadLetters(dbName)
+	if err != nil {
+		http.Error(w, "Ошибка чтения недоставленных уведомлений: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+// retryDeadLetters возвращает недоставленные уведомления в очередь: все или одно (?id=)
+func (h *APIHandler) retryDeadLetters(w http.ResponseWriter, r *http.Request, dbName string) {
+	var id int64
+	if value := r.URL.Query().Get("id"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			http.Error(w, "Неверный формат идентификатора доставки", http.StatusBadRequest)
+			return
+		}
+		id = parsed
+	}
+
+	count, err := h.webhooks.RetryDeadLetters(dbName, id)
+	if err != nil {
+		http.Error(w, "Ошибка обновления очереди доставок: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "success",
+		"message": fmt.Sprintf("В очередь доставки возвращено уведомлений: %d", count),
+	})
+}
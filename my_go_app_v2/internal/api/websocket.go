@@ -0,0 +1,347 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"project/internal/models"
+	"project/internal/storage"
+
+	"github.com/gorilla/websocket"
+)
+
+// Параметры соединения WebSocket
+const (
+	wsWriteTimeout = 10 * time.Second
+	// wsPongTimeout клиент считается отключившимся, если не ответил на ping за это время
+	wsPongTimeout  = 60 * time.Second
+	wsPingInterval = wsPongTimeout * 9 / 10
+	wsMaxMessage   = 64 << 10
+	wsSendBuffer   = 256
+)
+
+// wsUpgrader по умолчанию принимает соединения только со страниц того же origin
+var wsUpgrader = websocket.Upgrader{}
+
+// wsRequest сообщение клиента: подписка или команда над товарами.
+// Типы: subscribe, unsubscribe, list, get, create, update, delete
+type wsRequest struct {
+	Type string `json:"type"`
+	// RequestID возвращается в ответе, чтобы клиент мог сопоставить ответ с командой
+	RequestID string `json:"request_id,omitempty"`
+
+	// Параметры подписки; пустой список означает "любые"
+	Databases  []string `json:"databases,omitempty"`
+	Categories []string `json:"categories,omitempty"`
+	ProductIDs []int    `json:"product_ids,omitempty"`
+
+	// Параметры команд
+	Database  string          `json:"database,omitempty"`
+	ProductID int             `json:"product_id,omitempty"`
+	Product   *models.Product `json:"product,omitempty"`
+	// Version ожидаемая версия товара для update и delete (0 - без проверки)
+	Version int64             `json:"version,omitempty"`
+	Params  map[string]string `json:"params,omitempty"`
+}
+
+// wsResult ответ на сообщение клиента
+type wsResult struct {
+	Type      string           `json:"type"`
+	RequestID string           `json:"request_id,omitempty"`
+	Status    string           `json:"status"`
+	Message   string           `json:"message,omitempty"`
+	Product   *models.Product  `json:"product,omitempty"`
+	Products  []models.Product `json:"products,omitempty"`
+	Total     *int64           `json:"total,omitempty"`
+}
+
+// wsEvent уведомление об изменении товара
+type wsEvent struct {
+	Type string `json:"type"`
+	productEvent
+}
+
+// wsFilter подписка клиента
+type wsFilter struct {
+	databases  map[string]bool
+	categories map[string]bool
+	productIDs map[int]bool
+}
+
+// matches проверяет, подходит ли событие под подписку
+func (f *wsFilter) matches(event productEvent) bool {
+	if len(f.databases) > 0 && !f.databases[event.Database] {
+		return false
+	}
+	if len(f.categories) > 0 && !f.categories[event.Product.Category] {
+		return false
+	}
+	if len(f.productIDs) > 0 && !f.productIDs[event.Product.ID] {
+		return false
+	}
+	return true
+}
+
+// wsClient соединение WebSocket
+type wsClient struct {
+	h    *APIHandler
+	conn *websocket.Conn
+	send chan interface{}
+	done chan struct{}
+
+	mu sync.Mutex
+	// filter текущая подписка (nil - клиент не подписан)
+	filter *wsFilter
+}
+
+// handleWebSocket обрабатывает соединения WebSocket на /ws: клиент подписывается на изменения
+// товаров в выбранных базах, категориях или по идентификаторам и выполняет команды над товарами
+// через то же соединение
+func (h *APIHandler) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade уже отправил клиенту ответ с ошибкой
+		return
+	}
+
+	client := &wsClient{
+		h:    h,
+		conn: conn,
+		send: make(chan interface{}, wsSendBuffer),
+		done: make(chan struct{}),
+	}
+
+	events := h.events.subscribeAll()
+	defer h.events.unsubscribeAll(events)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		client.writeLoop()
+	}()
+	go func() {
+		defer wg.Done()
+		client.forwardEvents(events)
+	}()
+
+	client.readLoop()
+	close(client.done)
+	conn.Close()
+	wg.Wait()
+}
+
+// readLoop читает и выполняет сообщения клиента, пока соединение не будет закрыто
+func (c *wsClient) readLoop() {
+	c.conn.SetReadLimit(wsMaxMessage)
+	c.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	})
+
+	for {
+		var request wsRequest
+		if err := c.conn.ReadJSON(&request); err != nil {
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+				c.reply(wsResult{Status: "error", Message: "Ошибка чтения сообщения: " + err.Error()})
+				continue
+			}
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Printf("WebSocket: соединение с %s закрыто: %v", c.conn.RemoteAddr(), err)
+			}
+			return
+		}
+
+		result := c.handle(request)
+		result.RequestID = request.RequestID
+		if !c.reply(result) {
+			return
+		}
+	}
+}
+
+// reply отправляет ответ клиенту; false, если соединение уже закрывается
+func (c *wsClient) reply(result wsResult) bool {
+	result.Type = "result"
+	select {
+	case c.send <- result:
+		return true
+	case <-c.done:
+		return false
+	}
+}
+
+// writeLoop отправляет клиенту ответы и события и поддерживает соединение ping-сообщениями
+func (c *wsClient) writeLoop() {
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-c.h.events.closed:
+			// Сервер останавливается
+			c.conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, "сервер останавливается"), time.Now().Add(wsWriteTimeout))
+			c.conn.Close()
+			return
+		case message := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := c.conn.WriteJSON(message); err != nil {
+				c.conn.Close()
+				return
+			}
+		case <-ping.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				c.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// forwardEvents передает клиенту события, подходящие под его подписку.
+// Клиент, не успевающий получать события, отключается
+func (c *wsClient) forwardEvents(events chan productEvent) {
+	for {
+		select {
+		case <-c.done:
+			return
+		case event, ok := <-events:
+			if !ok {
+				c.conn.Close()
+				return
+			}
+
+			c.mu.Lock()
+			filter := c.filter
+			c.mu.Unlock()
+			if filter == nil || !filter.matches(event) {
+				continue
+			}
+
+			select {
+			case c.send <- wsEvent{Type: "event", productEvent: event}:
+			default:
+				log.Printf("WebSocket: клиент %s не успевает получать события, соединение закрыто", c.conn.RemoteAddr())
+				c.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// handle выполняет сообщение клиента
+func (c *wsClient) handle(request wsRequest) wsResult {
+	switch request.Type {
+	case "subscribe":
+		return c.subscribe(request)
+	case "unsubscribe":
+		c.mu.Lock()
+		c.filter = nil
+		c.mu.Unlock()
+		return wsResult{Status: "success", Message: "Подписка отменена"}
+	case "list", "get", "create", "update", "delete":
+		return c.command(request)
+	}
+	return wsResult{Status: "error", Message: fmt.Sprintf("Неизвестный тип сообщения %q", request.Type)}
+}
+
+// subscribe заменяет подписку клиента
+func (c *wsClient) subscribe(request wsRequest) wsResult {
+	filter := &wsFilter{
+		databases:  make(map[string]bool),
+		categories: make(map[string]bool),
+		productIDs: make(map[int]bool),
+	}
+	for _, name := range request.Databases {
+		if _, err := c.h.dbManager.Store(name); errors.Is(err, storage.ErrUnknownDatabase) {
+			return wsResult{Status: "error", Message: fmt.Sprintf("База данных %s не найдена", name)}
+		}
+		filter.databases[name] = true
+	}
+	for _, category := range request.Categories {
+		filter.categories[category] = true
+	}
+	for _, id := range request.ProductIDs {
+		filter.productIDs[id] = true
+	}
+
+	c.mu.Lock()
+	c.filter = filter
+	c.mu.Unlock()
+
+	return wsResult{Status: "success", Message: "Подписка оформлена"}
+}
+
+// command выполняет команду над товарами; ошибки и сообщения совпадают с REST API
+func (c *wsClient) command(request wsRequest) wsResult {
+	store, err := c.h.dbManager.Store(request.Database)
+	if err != nil {
+		var unavailable *storage.UnavailableError
+		if errors.As(err, &unavailable) {
+			return wsResult{Status: "error", Message: unavailable.Error()}
+		}
+		return wsResult{Status: "error", Message: "База данных не найдена"}
+	}
+
+	if (request.Type == "create" || request.Type == "update") && request.Product == nil {
+		return wsResult{Status: "error", Message: "Не передан товар (поле product)"}
+	}
+
+	switch request.Type {
+	case "list":
+		values := url.Values{}
+		for key, value := range request.Params {
+			values.Set(key, value)
+		}
+		query, err := parseProductQuery(values)
+		if err != nil {
+			return wsResult{Status: "error", Message: err.Error()}
+		}
+		page, err := store.ListProducts(query)
+		if err != nil {
+			return wsResult{Status: "error", Message: "Ошибка при получении товаров: " + err.Error()}
+		}
+		return wsResult{Status: "success", Products: page.Products, Total: &page.Total}
+
+	case "get":
+		product, exists, err := store.GetProduct(request.ProductID)
+		if err != nil {
+			return wsResult{Status: "error", Message: "Ошибка при получении товара: " + err.Error()}
+		}
+		if !exists {
+			return wsResult{Status: "error", Message: "Товар не найден"}
+		}
+		return wsResult{Status: "success", Product: &product}
+
+	case "create":
+		product, err := c.h.createProduct(store, request.Database, *request.Product)
+		if err != nil {
+			return wsResult{Status: "error", Message: err.Error()}
+		}
+		return wsResult{Status: "success", Message: fmt.Sprintf("Товар с ID %d успешно создан в базе %s", product.ID, request.Database), Product: &product}
+
+	case "update":
+		product, err := c.h.updateProduct(store, request.Database, *request.Product, request.Version)
+		if err != nil {
+			return wsResult{Status: "error", Message: err.Error()}
+		}
+		return wsResult{Status: "success", Message: fmt.Sprintf("Товар с ID %d успешно обновлен в базе %s", product.ID, request.Database), Product: &product}
+
+	default:
+		if err := c.h.deleteProduct(store, request.Database, request.ProductID, request.Version); err != nil {
+			return wsResult{Status: "error", Message: err.Error()}
+		}
+		return wsResult{Status: "success", Message: fmt.Sprintf("Товар с ID %d успешно удален из базы %s", request.ProductID, request.Database)}
+	}
+}
@@ -0,0 +1,122 @@
+package catalog
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Fields поля товара, которые можно загрузить из прайс-листа. Имена совпадают с JSON-полями товара
+var Fields = []string{"id", "name", "category", "price", "description", "in_stock", "supplier"}
+
+// defaultColumns заголовки столбцов, которые распознаются без настройки (в нормализованном виде, см. normalizeHeader)
+var defaultColumns = map[string][]string{
+	"id":          {"id", "код", "код товара", "ид"},
+	"name":        {"наименование", "наименование товара", "название", "товар", "name"},
+	"category":    {"категория", "группа", "раздел", "category"},
+	"price":       {"цена", "цена, руб", "цена руб", "цена (руб)", "цена, ₽", "стоимость", "price"},
+	"description": {"описание", "характеристики", "description"},
+	"in_stock":    {"в наличии", "наличие", "остаток", "in_stock"},
+	"supplier":    {"поставщик", "supplier"},
+}
+
+// isField проверяет, что поле можно загрузить из прайс-листа
+func isField(field string) bool {
+	for _, f := range Fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeHeader приводит заголовок столбца к виду для сравнения:
+// нижний регистр, е вместо ё, одиночные пробелы, без точки и двоеточия в конце
+func normalizeHeader(header string) string {
+	header = strings.ToLower(strings.Join(strings.Fields(header), " "))
+	header = strings.ReplaceAll(header, "ё", "е")
+	return strings.TrimRight(header, ".:")
+}
+
+// ParseColumns разбирает сопоставления столбцов вида "поле:Заголовок" (например, "price:Цена опт")
+func ParseColumns(specs []string) (map[string][]string, error) {
+	columns := make(map[string][]string)
+	for _, spec := range specs {
+		field, header, ok := strings.Cut(spec, ":")
+		field = strings.TrimSpace(field)
+		if !ok || strings.TrimSpace(header) == "" {
+			return nil, fmt.Errorf("сопоставление %q должно иметь вид поле:Заголовок", spec)
+		}
+		if !isField(field) {
+			return nil, fmt.Errorf("неизвестное поле %q в сопоставлении %q", field, spec)
+		}
+		columns[field] = append(columns[field], header)
+	}
+	return columns, nil
+}
+
+// MergeColumns объединяет сопоставления столбцов. Заголовки из более ранних наборов имеют приоритет
+func MergeColumns(sets ...map[string][]string) map[string][]string {
+	columns := make(map[string][]string)
+	for _, set := range sets {
+		for field, headers := range set {
+			columns[field] = append(columns[field], headers...)
+		}
+	}
+	return columns
+}
+
+// columnMap сопоставление столбцов файла полям товара
+type columnMap struct {
+	// fields поле товара для каждого столбца ("" - столбец не загружается)
+	fields []string
+	// headers исходный заголовок столбца для каждого поля
+	headers map[string]string
+	// ignored заголовки столбцов, не сопоставленных полям
+	ignored []string
+}
+
+// mapColumns сопоставляет заголовки строки header полям товара. Сначала применяются явно заданные
+// заголовки columns (в порядке приоритета), затем заголовки по умолчанию для оставшихся полей и столбцов
+func mapColumns(header []string, columns map[string][]string) columnMap {
+	m := columnMap{fields: make([]string, len(header)), headers: make(map[string]string)}
+
+	normalized := make([]string, len(header))
+	for i, cell := range header {
+		normalized[i] = normalizeHeader(cell)
+	}
+
+	assign := func(aliases map[string][]string) {
+		for _, field := range Fields {
+			if _, mapped := m.headers[field]; mapped {
+				continue
+			}
+		search:
+			for _, alias := range aliases[field] {
+				alias = normalizeHeader(alias)
+				for i, cell := range normalized {
+					if cell != "" && cell == alias && m.fields[i] == "" {
+						m.fields[i] = field
+						m.headers[field] = strings.TrimSpace(header[i])
+						break search
+					}
+				}
+			}
+		}
+	}
+	assign(columns)
+	assign(defaultColumns)
+
+	for i, cell := range header {
+		if m.fields[i] == "" && strings.TrimSpace(cell) != "" {
+			m.ignored = append(m.ignored, strings.TrimSpace(cell))
+		}
+	}
+	return m
+}
+
+// isHeader сообщает, похожа ли строка на заголовок таблицы: распознаны столбец id или name и хотя бы еще один
+func (m columnMap) isHeader() bool {
+	_, hasID := m.headers["id"]
+	_, hasName := m.headers["name"]
+	return (hasID || hasName) && len(m.headers) >= 2
+}
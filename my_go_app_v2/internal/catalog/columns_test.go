@@ -0,0 +1,96 @@
+package catalog
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMapColumns(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  []string
+		columns map[string][]string
+		fields  []string
+		headers map[string]string
+		ignored []string
+	}{
+		{
+			name:   "заголовки по умолчанию",
+			header: []string{"Код", "Наименование", "Цена, руб.", "Наличие", "Поставщик"},
+			fields: []string{"id", "name", "price", "in_stock", "supplier"},
+			headers: map[string]string{
+				"id": "Код", "name": "Наименование", "price": "Цена, руб.", "in_stock": "Наличие", "supplier": "Поставщик",
+			},
+		},
+		{
+			name:    "регистр, пробелы и ё",
+			header:  []string{"  НАЗВАНИЕ ", "Группа:", "Характеристики"},
+			fields:  []string{"name", "category", "description"},
+			headers: map[string]string{"name": "НАЗВАНИЕ", "category": "Группа:", "description": "Характеристики"},
+		},
+		{
+			name:    "нераспознанные и пустые столбцы",
+			header:  []string{"Товар", "", "Артикул", "Цена"},
+			fields:  []string{"name", "", "", "price"},
+			headers: map[string]string{"name": "Товар", "price": "Цена"},
+			ignored: []string{"Артикул"},
+		},
+		{
+			name:    "явное сопоставление важнее заголовков по умолчанию",
+			header:  []string{"Наименование", "Цена", "Цена опт"},
+			columns: map[string][]string{"price": {"Цена опт"}},
+			fields:  []string{"name", "", "price"},
+			headers: map[string]string{"name": "Наименование", "price": "Цена опт"},
+			ignored: []string{"Цена"},
+		},
+		{
+			name:    "порядок явных заголовков задает приоритет",
+			header:  []string{"Название", "Номенклатура"},
+			columns: map[string][]string{"name": {"Номенклатура", "Название"}},
+			fields:  []string{"", "name"},
+			headers: map[string]string{"name": "Номенклатура"},
+			ignored: []string{"Название"},
+		},
+		{
+			name:    "столбец сопоставляется одному полю",
+			header:  []string{"Цена", "Цена"},
+			fields:  []string{"price", ""},
+			headers: map[string]string{"price": "Цена"},
+			ignored: []string{"Цена"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mapColumns(tt.header, tt.columns)
+			if !reflect.DeepEqual(m.fields, tt.fields) {
+				t.Errorf("поля %q, ожидалось %q", m.fields, tt.fields)
+			}
+			if !reflect.DeepEqual(m.headers, tt.headers) {
+				t.Errorf("заголовки %v, ожидалось %v", m.headers, tt.headers)
+			}
+			if !reflect.DeepEqual(m.ignored, tt.ignored) {
+				t.Errorf("пропущенные столбцы %q, ожидалось %q", m.ignored, tt.ignored)
+			}
+		})
+	}
+}
+
+func TestColumnMapIsHeader(t *testing.T) {
+	tests := []struct {
+		header []string
+		want   bool
+	}{
+		{header: []string{"Наименование", "Цена"}, want: true},
+		{header: []string{"Код", "Поставщик"}, want: true},
+		{header: []string{"Наименование"}, want: false},
+		{header: []string{"Цена", "Наличие"}, want: false},
+		{header: []string{"Прайс-лист ООО «Альфа»", "", ""}, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := mapColumns(tt.header, nil).isHeader(); got != tt.want {
+			t.Errorf("isHeader(%q) = %v, ожидалось %v", tt.header, got, tt.want)
+		}
+	}
+}
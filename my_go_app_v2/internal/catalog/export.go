@@ -0,0 +1,190 @@
+package catalog
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"project/internal/models"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// FormatNDJSON формат выгрузки: JSON-объект товара на каждой строке
+const FormatNDJSON = "ndjson"
+
+// MIMENDJSON MIME-тип NDJSON
+const MIMENDJSON = "application/x-ndjson"
+
+// exportHeaders заголовки столбцов выгрузки. Они совпадают с заголовками, которые распознает импорт,
+// поэтому выгруженный файл можно отредактировать и загрузить обратно
+var exportHeaders = []string{"Код", "Наименование", "Категория", "Цена", "Описание", "В наличии", "Поставщик", "Версия"}
+
+// exportSheet имя листа книги XLSX
+const exportSheet = "Товары"
+
+// Writer построчная запись выгрузки каталога
+type Writer interface {
+	Write(product models.Product) error
+	// Close дописывает выгрузку; для XLSX книга целиком передается получателю только здесь
+	Close() error
+	// Discard освобождает ресурсы выгрузки, которая не будет дописана (временные файлы книги XLSX)
+	Discard()
+}
+
+// NewWriter создает запись выгрузки в формате format (csv, ndjson, xlsx)
+func NewWriter(w io.Writer, format string) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w)
+	case FormatNDJSON:
+		return &ndjsonWriter{encoder: json.NewEncoder(w)}, nil
+	case FormatXLSX:
+		return newXLSXWriter(w)
+	}
+	return nil, fmt.Errorf("неизвестный формат выгрузки %q: ожидается csv, ndjson или xlsx", format)
+}
+
+// ContentType MIME-тип формата выгрузки
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return MIMECSV + "; charset=utf-8"
+	case FormatNDJSON:
+		return MIMENDJSON
+	case FormatXLSX:
+		return MIMEXLSX
+	}
+	return "application/octet-stream"
+}
+
+// csvWriter CSV в виде, который открывает русская версия Excel: UTF-8 с BOM,
+// точка с запятой между столбцами, десятичная запятая в цене
+type csvWriter struct {
+	writer *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	if _, err := io.WriteString(w, "\xef\xbb\xbf"); err != nil {
+		return nil, err
+	}
+	writer := csv.NewWriter(w)
+	writer.Comma = ';'
+	if err := writer.Write(exportHeaders); err != nil {
+		return nil, err
+	}
+	return &csvWriter{writer: writer}, nil
+}
+
+func (c *csvWriter) Write(product models.Product) error {
+	return c.writer.Write([]string{
+		strconv.Itoa(product.ID),
+		product.Name,
+		product.Category,
+		strings.Replace(strconv.FormatFloat(product.Price, 'f', -1, 64), ".", ",", 1),
+		product.Description,
+		formatStock(product.InStock),
+		product.Supplier,
+		strconv.FormatInt(product.Version, 10),
+	})
+}
+
+func (c *csvWriter) Close() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+func (c *csvWriter) Discard() {}
+
+// ndjsonWriter товары в том же JSON-представлении, что и в ответах API
+type ndjsonWriter struct {
+	encoder *json.Encoder
+}
+
+func (n *ndjsonWriter) Write(product models.Product) error {
+	return n.encoder.Encode(product)
+}
+
+func (n *ndjsonWriter) Close() error {
+	return nil
+}
+
+func (n *ndjsonWriter) Discard() {}
+
+// xlsxWriter книга XLSX. Потоковая запись excelize держит в памяти только небольшой буфер строк,
+// остальное сбрасывается во временный файл; книга собирается и передается в Close
+type xlsxWriter struct {
+	w      io.Writer
+	book   *excelize.File
+	stream *excelize.StreamWriter
+	row    int
+}
+
+func newXLSXWriter(w io.Writer) (*xlsxWriter, error) {
+	book := excelize.NewFile()
+	if err := book.SetSheetName(book.GetSheetName(0), exportSheet); err != nil {
+		book.Close()
+		return nil, err
+	}
+	stream, err := book.NewStreamWriter(exportSheet)
+	if err != nil {
+		book.Close()
+		return nil, err
+	}
+
+	x := &xlsxWriter{w: w, book: book, stream: stream, row: 1}
+	header := make([]any, len(exportHeaders))
+	for i, title := range exportHeaders {
+		header[i] = title
+	}
+	if err := x.writeRow(header); err != nil {
+		book.Close()
+		return nil, err
+	}
+	return x, nil
+}
+
+func (x *xlsxWriter) writeRow(values []any) error {
+	cell, err := excelize.CoordinatesToCellName(1, x.row)
+	if err != nil {
+		return err
+	}
+	x.row++
+	return x.stream.SetRow(cell, values)
+}
+
+func (x *xlsxWriter) Write(product models.Product) error {
+	return x.writeRow([]any{
+		product.ID,
+		product.Name,
+		product.Category,
+		product.Price,
+		product.Description,
+		formatStock(product.InStock),
+		product.Supplier,
+		product.Version,
+	})
+}
+
+func (x *xlsxWriter) Close() error {
+	defer x.book.Close()
+	if err := x.stream.Flush(); err != nil {
+		return err
+	}
+	return x.book.Write(x.w)
+}
+
+func (x *xlsxWriter) Discard() {
+	x.book.Close()
+}
+
+// formatStock наличие товара словами, которые распознает импорт
+func formatStock(inStock bool) string {
+	if inStock {
+		return "да"
+	}
+	return "нет"
+}
@@ -0,0 +1,360 @@
+package catalog
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"project/internal/models"
+
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Форматы прайс-листов
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// MIME-типы форматов прайс-листов
+const (
+	MIMECSV  = "text/csv"
+	MIMEXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+// headerSearchRows сколько первых строк файла просматривается в поисках заголовка таблицы:
+// прайс-листы часто начинаются с названия организации и даты
+const headerSearchRows = 20
+
+// Options параметры разбора прайс-листа
+type Options struct {
+	// Format csv или xlsx; пустое значение - определить по содержимому
+	Format string
+	// Columns дополнительные заголовки столбцов для полей товара, приоритетнее заголовков по умолчанию
+	Columns map[string][]string
+	// Sheet лист XLSX (по умолчанию первый)
+	Sheet string
+	// Supplier поставщик для строк, в которых он не указан
+	Supplier string
+}
+
+// Row строка прайс-листа
+type Row struct {
+	// Line номер строки в файле, начиная с 1
+	Line    int
+	Product models.Product
+	// Fields поля, заполненные в строке. Пустые ячейки не изменяют существующий товар
+	Fields []string
+	// Errors ошибки в значениях ячеек
+	Errors []string
+}
+
+// Sheet разобранный прайс-лист
+type Sheet struct {
+	Rows []Row
+	// Columns заголовок столбца, из которого загружается каждое поле
+	Columns map[string]string
+	// Ignored заголовки столбцов, не сопоставленных полям товара
+	Ignored []string
+}
+
+// DetectFormat определяет формат по MIME-типу или имени файла; пустое значение - формат неизвестен
+func DetectFormat(contentType, filename string) string {
+	switch {
+	case strings.HasPrefix(contentType, MIMEXLSX), strings.HasSuffix(strings.ToLower(filename), ".xlsx"):
+		return FormatXLSX
+	case strings.HasPrefix(contentType, MIMECSV), strings.HasSuffix(strings.ToLower(filename), ".csv"):
+		return FormatCSV
+	}
+	return ""
+}
+
+// Parse читает прайс-лист и разбирает его строки в товары. Ошибка возвращается, если файл не удалось
+// прочитать или в нем не найден заголовок таблицы; ошибки в отдельных строках записываются в Row.Errors
+func Parse(r io.Reader, opts Options) (*Sheet, error) {
+	for field := range opts.Columns {
+		if !isField(field) {
+			return nil, fmt.Errorf("неизвестное поле %q в сопоставлении столбцов", field)
+		}
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать файл: %w", err)
+	}
+
+	format := opts.Format
+	if format == "" {
+		// XLSX - zip-архив
+		format = FormatCSV
+		if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+			format = FormatXLSX
+		}
+	}
+
+	var records [][]string
+	var lines []int
+	switch format {
+	case FormatCSV:
+		records, lines, err = readCSV(data, opts.Columns)
+	case FormatXLSX:
+		records, lines, err = readXLSX(data, opts.Sheet)
+	default:
+		return nil, fmt.Errorf("неизвестный формат %q: ожидается csv или xlsx", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	header, columns := findHeader(records, opts.Columns)
+	if header < 0 {
+		return nil, fmt.Errorf("не найден заголовок таблицы: нужны столбцы с кодом (ID) или наименованием товара и хотя бы еще одно поле, " +
+			"например \"Наименование\" и \"Цена\"")
+	}
+
+	sheet := &Sheet{Columns: columns.headers, Ignored: columns.ignored}
+	for i := header + 1; i < len(records); i++ {
+		row, ok := parseRow(records[i], columns)
+		if !ok {
+			continue
+		}
+		row.Line = lines[i]
+		if opts.Supplier != "" && row.Product.Supplier == "" {
+			row.Product.Supplier = opts.Supplier
+			row.Fields = append(row.Fields, "supplier")
+		}
+		sheet.Rows = append(sheet.Rows, row)
+	}
+	return sheet, nil
+}
+
+// findHeader ищет заголовок таблицы среди первых headerSearchRows строк; -1 - заголовок не найден
+func findHeader(records [][]string, columns map[string][]string) (int, columnMap) {
+	for i := 0; i < len(records) && i < headerSearchRows; i++ {
+		if mapped := mapColumns(records[i], columns); mapped.isHeader() {
+			return i, mapped
+		}
+	}
+	return -1, columnMap{}
+}
+
+// readCSV читает CSV в кодировке UTF-8 (с BOM или без) или Windows-1251.
+// Разделитель (точка с запятой, запятая или табуляция) определяется по строке заголовка
+func readCSV(data []byte, columns map[string][]string) ([][]string, []int, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		decoded, err := charmap.Windows1251.NewDecoder().Bytes(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("не удалось определить кодировку файла: %v", err)
+		}
+		data = decoded
+	}
+
+	reader := newCSVReader(data, csvDelimiter(data, columns))
+	var records [][]string
+	var lines []int
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("ошибка чтения CSV: %v", err)
+		}
+		line, _ := reader.FieldPos(0)
+		records = append(records, record)
+		lines = append(lines, line)
+	}
+	return records, lines, nil
+}
+
+// csvDelimiter выбирает разделитель, с которым среди первых headerSearchRows строк находится заголовок
+// с наибольшим числом распознанных столбцов. Строки над заголовком (название организации, адрес) могут
+// содержать запятые, поэтому первая строка файла для выбора не подходит. Если заголовок не найден
+// ни с одним разделителем, выбирается точка с запятой
+func csvDelimiter(data []byte, columns map[string][]string) rune {
+	delimiter, best := ';', 0
+	for _, candidate := range []rune{';', '\t', ','} {
+		reader := newCSVReader(data, candidate)
+		var records [][]string
+		for len(records) < headerSearchRows {
+			record, err := reader.Read()
+			if err != nil {
+				break
+			}
+			records = append(records, record)
+		}
+
+		if header, mapped := findHeader(records, columns); header >= 0 && len(mapped.headers) > best {
+			delimiter, best = candidate, len(mapped.headers)
+		}
+	}
+	return delimiter
+}
+
+// newCSVReader создает читатель CSV с разделителем delimiter, допускающий строки разной длины
+func newCSVReader(data []byte, delimiter rune) *csv.Reader {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	return reader
+}
+
+// readXLSX читает лист книги XLSX (по умолчанию первый). Ячейки читаются без числовых форматов книги
+func readXLSX(data []byte, sheet string) ([][]string, []int, error) {
+	book, err := excelize.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, fmt.Errorf("не удалось открыть книгу XLSX: %v", err)
+	}
+	defer book.Close()
+
+	if sheet == "" {
+		sheet = book.GetSheetName(0)
+	}
+	if index, err := book.GetSheetIndex(sheet); err != nil || index < 0 {
+		return nil, nil, fmt.Errorf("в книге нет листа %q", sheet)
+	}
+
+	records, err := book.GetRows(sheet, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return nil, nil, fmt.Errorf("ошибка чтения листа %q: %v", sheet, err)
+	}
+	lines := make([]int, len(records))
+	for i := range lines {
+		lines[i] = i + 1
+	}
+	return records, lines, nil
+}
+
+// parseRow разбирает строку таблицы. ok=false - в строке нет ни одного значения в загружаемых столбцах
+func parseRow(record []string, columns columnMap) (row Row, ok bool) {
+	for i, field := range columns.fields {
+		if field == "" || i >= len(record) {
+			continue
+		}
+		value := strings.TrimSpace(record[i])
+		if value == "" {
+			continue
+		}
+		ok = true
+
+		if err := setField(&row.Product, field, value); err != nil {
+			row.Errors = append(row.Errors, fmt.Sprintf("%s: %v", columns.headers[field], err))
+			continue
+		}
+		row.Fields = append(row.Fields, field)
+	}
+	return row, ok
+}
+
+// setField записывает значение ячейки в поле товара
+func setField(product *models.Product, field, value string) error {
+	var err error
+	switch field {
+	case "id":
+		product.ID, err = parseID(value)
+	case "name":
+		product.Name = value
+	case "category":
+		product.Category = value
+	case "price":
+		product.Price, err = parsePrice(value)
+	case "description":
+		product.Description = value
+	case "in_stock":
+		product.InStock, err = parseStock(value)
+	case "supplier":
+		product.Supplier = value
+	}
+	return err
+}
+
+// parseID разбирает ID товара. Табличные редакторы могут сохранить целое число как 12.0 или 12,0
+func parseID(value string) (int, error) {
+	number, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+	if err != nil || number != math.Trunc(number) || number < 1 || number > math.MaxInt32 {
+		return 0, fmt.Errorf("неверный ID %q: ожидается целое положительное число", value)
+	}
+	return int(number), nil
+}
+
+// decimalPattern число после нормализации разделителей
+var decimalPattern = regexp.MustCompile(`^-?\d+(\.\d+)?$`)
+
+// parsePrice разбирает цену: "1 234,50", "1234.5", "1.234,50", "1 234,50 руб."
+func parsePrice(value string) (float64, error) {
+	s := strings.ToLower(strings.TrimSpace(value))
+	for _, currency := range []string{"руб.", "руб", "р.", "₽"} {
+		s = strings.TrimSpace(strings.TrimSuffix(s, currency))
+	}
+	// Пробелы (в том числе неразрывные) и апострофы разделяют разряды
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || r == '\'' {
+			return -1
+		}
+		return r
+	}, s)
+
+	// Десятичный разделитель - последняя запятая или точка; второй знак разделяет разряды
+	comma, dot := strings.LastIndex(s, ","), strings.LastIndex(s, ".")
+	switch {
+	case comma >= 0 && dot >= 0 && comma > dot:
+		s = strings.Replace(strings.ReplaceAll(s, ".", ""), ",", ".", 1)
+	case comma >= 0 && dot >= 0:
+		s = strings.ReplaceAll(s, ",", "")
+	case strings.Count(s, ",") > 1:
+		s = strings.ReplaceAll(s, ",", "")
+	case strings.Count(s, ".") > 1:
+		s = strings.ReplaceAll(s, ".", "")
+	case comma >= 0:
+		s = strings.Replace(s, ",", ".", 1)
+	}
+
+	if !decimalPattern.MatchString(s) {
+		return 0, fmt.Errorf("неверный формат цены %q", value)
+	}
+	price, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("неверный формат цены %q", value)
+	}
+	if price < 0 {
+		return 0, fmt.Errorf("цена не может быть отрицательной")
+	}
+	return price, nil
+}
+
+// Значения наличия товара
+var (
+	inStockValues  = []string{"да", "есть", "в наличии", "+", "true", "yes", "y", "истина"}
+	outStockValues = []string{"нет", "-", "false", "no", "n", "ложь", "под заказ", "отсутствует"}
+)
+
+// parseStock разбирает наличие товара: да/нет, есть/нет, +/- или количество на складе
+func parseStock(value string) (bool, error) {
+	s := strings.ToLower(value)
+	for _, v := range inStockValues {
+		if s == v {
+			return true, nil
+		}
+	}
+	for _, v := range outStockValues {
+		if s == v {
+			return false, nil
+		}
+	}
+
+	quantity, err := strconv.ParseFloat(strings.Replace(s, ",", ".", 1), 64)
+	if err != nil || quantity < 0 {
+		return false, fmt.Errorf("неверное значение наличия %q: ожидается да/нет или количество", value)
+	}
+	return quantity > 0, nil
+}
@@ -0,0 +1,80 @@
+package catalog
+
+import "testing"
+
+func TestParsePrice(t *testing.T) {
+	tests := []struct {
+		value string
+		want  float64
+		// wantErr значение должно быть отклонено
+		wantErr bool
+	}{
+		{value: "1234.5", want: 1234.5},
+		{value: "1234,5", want: 1234.5},
+		{value: "1 234,50", want: 1234.5},
+		{value: "1 234,50", want: 1234.5},
+		{value: "1.234,50", want: 1234.5},
+		{value: "1,234.50", want: 1234.5},
+		{value: "1'234.50", want: 1234.5},
+		{value: "1.234.567", want: 1234567},
+		{value: "1,234,567", want: 1234567},
+		{value: "1 234,50 руб.", want: 1234.5},
+		{value: "990 руб", want: 990},
+		{value: "990р.", want: 990},
+		{value: "990 ₽", want: 990},
+		{value: " 0 ", want: 0},
+		{value: "", wantErr: true},
+		{value: "дорого", wantErr: true},
+		{value: "12.5$", wantErr: true},
+		{value: "1e3", wantErr: true},
+		{value: "-10", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parsePrice(tt.value)
+		switch {
+		case tt.wantErr && err == nil:
+			t.Errorf("parsePrice(%q) = %v, ожидалась ошибка", tt.value, got)
+		case !tt.wantErr && err != nil:
+			t.Errorf("parsePrice(%q): неожиданная ошибка: %v", tt.value, err)
+		case !tt.wantErr && got != tt.want:
+			t.Errorf("parsePrice(%q) = %v, ожидалось %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseStock(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    bool
+		wantErr bool
+	}{
+		{value: "да", want: true},
+		{value: "Есть", want: true},
+		{value: "В наличии", want: true},
+		{value: "+", want: true},
+		{value: "TRUE", want: true},
+		{value: "нет", want: false},
+		{value: "-", want: false},
+		{value: "под заказ", want: false},
+		{value: "ложь", want: false},
+		{value: "15", want: true},
+		{value: "0,5", want: true},
+		{value: "0", want: false},
+		{value: "-3", wantErr: true},
+		{value: "много", wantErr: true},
+		{value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseStock(tt.value)
+		switch {
+		case tt.wantErr && err == nil:
+			t.Errorf("parseStock(%q) = %v, ожидалась ошибка", tt.value, got)
+		case !tt.wantErr && err != nil:
+			t.Errorf("parseStock(%q): неожиданная ошибка: %v", tt.value, err)
+		case !tt.wantErr && got != tt.want:
+			t.Errorf("parseStock(%q) = %v, ожидалось %v", tt.value, got, tt.want)
+		}
+	}
+}
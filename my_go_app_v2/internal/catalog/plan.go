@@ -0,0 +1,287 @@
+package catalog
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"project/internal/models"
+	"project/internal/storage"
+)
+
+// Режимы импорта
+const (
+	// ModeUpsert создает новые товары и обновляет существующие (по умолчанию)
+	ModeUpsert = "upsert"
+	// ModeCreate только создает товары; строки с ID существующих товаров считаются ошибкой
+	ModeCreate = "create"
+)
+
+// CheckMode проверяет режим импорта (пустое значение - режим по умолчанию)
+func CheckMode(mode string) error {
+	if mode != "" && mode != ModeUpsert && mode != ModeCreate {
+		return fmt.Errorf("неизвестный режим импорта %q: ожидается upsert или create", mode)
+	}
+	return nil
+}
+
+// lookupBatchSize количество ID в одном запросе существующих товаров
+const lookupBatchSize = 500
+
+// ErrAtomicTooLarge прайс-лист изменяет больше товаров, чем можно записать одним пакетом
+var ErrAtomicTooLarge = fmt.Errorf("в режиме atomic прайс-лист может создать и изменить не больше %d товаров", storage.MaxBulkOperations)
+
+// Действия со строкой прайс-листа
+const (
+	ActionCreate    = "create"
+	ActionUpdate    = "update"
+	ActionUnchanged = "unchanged"
+	// ActionInvalid строка содержит ошибки или конфликтует с базой и не загружается
+	ActionInvalid = "invalid"
+)
+
+// Item действие со строкой прайс-листа и его результат
+type Item struct {
+	Line   int    `json:"line"`
+	Action string `json:"action"`
+	ID     int    `json:"id,omitempty"`
+	Name   string `json:"name,omitempty"`
+	// Changes поля существующего товара, которые изменяет строка
+	Changes []string `json:"changes,omitempty"`
+	// Status результат применения в терминах HTTP (см. storage.BulkResult); 0 - не применялось
+	Status  int    `json:"status,omitempty"`
+	Version int64  `json:"version,omitempty"`
+	Error   string `json:"error,omitempty"`
+
+	// product товар, который будет записан в базу; для update значимы только поля Changes
+	product models.Product
+	// read версия существующего товара, с которой сравнивалась строка
+	read int64
+}
+
+// Report план импорта и, после Apply, его результат
+type Report struct {
+	Mode   string `json:"mode"`
+	DryRun bool   `json:"dry_run"`
+	Atomic bool   `json:"atomic"`
+	// Columns заголовок столбца, из которого загружено каждое поле
+	Columns map[string]string `json:"columns"`
+	Ignored []string          `json:"ignored_columns,omitempty"`
+
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Failed    int `json:"failed"`
+
+	Items []Item `json:"items"`
+
+	// applied план передан в базу данных
+	applied bool
+}
+
+// Plan сравнивает строки прайс-листа с товарами базы и определяет действие с каждой строкой.
+// Строки без ID создают новые товары. Пустые ячейки не изменяют поля существующего товара
+func Plan(store storage.ProductStore, sheet *Sheet, mode string) (*Report, error) {
+	if mode == "" {
+		mode = ModeUpsert
+	}
+	if err := CheckMode(mode); err != nil {
+		return nil, err
+	}
+
+	existing, err := existingProducts(store, sheet.Rows)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{Mode: mode, Columns: sheet.Columns, Ignored: sheet.Ignored, Items: make([]Item, 0, len(sheet.Rows))}
+	// seen строка, в которой встретился ID
+	seen := make(map[int]int)
+	for _, row := range sheet.Rows {
+		item := Item{Line: row.Line, ID: row.Product.ID, Name: row.Product.Name}
+		report.plan(&item, row, mode, seen, existing)
+		report.Items = append(report.Items, item)
+	}
+	report.count()
+	return report, nil
+}
+
+// existingProducts читает товары с ID из строк прайс-листа запросами по lookupBatchSize ID
+func existingProducts(store storage.ProductStore, rows []Row) (map[int]models.Product, error) {
+	var ids []int
+	unique := make(map[int]bool)
+	for _, row := range rows {
+		if id := row.Product.ID; id != 0 && len(row.Errors) == 0 && !unique[id] {
+			unique[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	existing := make(map[int]models.Product, len(ids))
+	for start := 0; start < len(ids); start += lookupBatchSize {
+		page, err := store.ListProducts(storage.ProductQuery{IDs: ids[start:min(start+lookupBatchSize, len(ids))]})
+		if err != nil {
+			return nil, err
+		}
+		for _, product := range page.Products {
+			existing[product.ID] = product
+		}
+	}
+	return existing, nil
+}
+
+// plan определяет действие с одной строкой
+func (r *Report) plan(item *Item, row Row, mode string, seen map[int]int, existing map[int]models.Product) {
+	if len(row.Errors) > 0 {
+		item.invalid(strings.Join(row.Errors, "; "))
+		return
+	}
+
+	id := row.Product.ID
+	if id != 0 {
+		if line, duplicate := seen[id]; duplicate {
+			item.invalid(fmt.Sprintf("ID %d уже встречается в строке %d", id, line))
+			return
+		}
+		seen[id] = row.Line
+	}
+
+	current, exists := existing[id]
+
+	switch {
+	case exists && mode == ModeCreate:
+		item.invalid(fmt.Sprintf("продукт с ID %d уже существует", id))
+
+	case exists:
+		var changes []string
+		for _, field := range storage.ChangedFields(current, row.Product) {
+			if slices.Contains(row.Fields, field) {
+				changes = append(changes, field)
+			}
+		}
+		if item.Name == "" {
+			item.Name = current.Name
+		}
+		if len(changes) == 0 {
+			item.Action = ActionUnchanged
+			return
+		}
+		item.Action = ActionUpdate
+		item.Changes = changes
+		item.product = row.Product
+		item.read = current.Version
+
+	case !slices.Contains(row.Fields, "name"):
+		item.invalid("для нового товара нужно наименование")
+	case !slices.Contains(row.Fields, "price"):
+		item.invalid("для нового товара нужна цена")
+
+	default:
+		item.Action = ActionCreate
+		item.product = row.Product
+	}
+}
+
+func (i *Item) invalid(message string) {
+	i.Action = ActionInvalid
+	i.Error = message
+}
+
+// failed сообщает, что строка не загружена или не будет загружена из-за ошибки
+func (i Item) failed() bool {
+	return i.Action == ActionInvalid || (i.Status != 0 && i.Status != http.StatusOK && i.Status != http.StatusCreated)
+}
+
+// count пересчитывает итоги плана
+func (r *Report) count() {
+	r.Created, r.Updated, r.Unchanged, r.Failed = 0, 0, 0, 0
+	for _, item := range r.Items {
+		switch {
+		case item.failed():
+			r.Failed++
+		case item.Action == ActionCreate:
+			r.Created++
+		case item.Action == ActionUpdate:
+			r.Updated++
+		case item.Action == ActionUnchanged:
+			r.Unchanged++
+		}
+	}
+}
+
+// BulkWriter выполняет пакет операций: storage.ProductStore.BulkWrite или обертка,
+// которая дополнительно сообщает об изменениях
+type BulkWriter func(operations []storage.BulkOperation, atomic bool) ([]storage.BulkResult, error)
+
+// Apply записывает созданные и измененные товары пакетами не больше storage.MaxBulkOperations операций.
+// Режим atomic допускается, только если все операции помещаются в один пакет. Обновление записывает только
+// измененные поля и только если версия товара не изменилась после Plan, иначе строка получает статус 412.
+// В режиме atomic пакет применяется целиком или не применяется совсем; если в плане есть ошибочные строки,
+// пакет не выполняется
+func (r *Report) Apply(write BulkWriter, atomic bool) error {
+	r.Atomic = atomic
+
+	var operations []storage.BulkOperation
+	var pending []int
+	for i, item := range r.Items {
+		product := item.product
+		switch item.Action {
+		case ActionCreate:
+			operations = append(operations, storage.BulkOperation{Op: storage.BulkCreate, Product: &product})
+		case ActionUpdate:
+			operations = append(operations, storage.BulkOperation{Op: storage.BulkPatch, Product: &product, Fields: item.Changes, Version: item.read})
+		default:
+			continue
+		}
+		pending = append(pending, i)
+	}
+	if len(operations) == 0 {
+		return nil
+	}
+	if atomic && len(operations) > storage.MaxBulkOperations {
+		return ErrAtomicTooLarge
+	}
+
+	r.applied = true
+	if atomic && r.Failed > 0 {
+		for _, i := range pending {
+			r.Items[i].Status = http.StatusFailedDependency
+			r.Items[i].Error = "не применено: прайс-лист содержит ошибочные строки"
+		}
+		r.count()
+		return nil
+	}
+
+	for start := 0; start < len(operations); start += storage.MaxBulkOperations {
+		end := min(start+storage.MaxBulkOperations, len(operations))
+		results, err := write(operations[start:end], atomic)
+		if err != nil {
+			r.count()
+			return err
+		}
+		for k, i := range pending[start:end] {
+			item := &r.Items[i]
+			item.Status = results[k].Status
+			item.Version = results[k].Version
+			item.Error = results[k].Error
+			if results[k].ID != 0 {
+				item.ID = results[k].ID
+			}
+		}
+	}
+	r.count()
+	return nil
+}
+
+// Status итог импорта: success - все строки загружены (или будут загружены), error - ни одна строка
+// не загружена из-за ошибок, partial - часть строк не загружена
+func (r *Report) Status() string {
+	switch {
+	case r.Failed == 0:
+		return "success"
+	case r.Created+r.Updated == 0 || (r.applied && r.Atomic):
+		return "error"
+	}
+	return "partial"
+}
@@ -0,0 +1,181 @@
+package changefeed
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"project/internal/models"
+	"project/internal/storage"
+)
+
+// Операции изменения товара в событии ProductChanged
+const (
+	Created = storage.ChangeCreated
+	Updated = storage.ChangeUpdated
+	Deleted = storage.ChangeDeleted
+)
+
+// Параметры перезапуска отслеживания после ошибки
+const (
+	restartInitialDelay = time.Second
+	restartMaxDelay     = time.Minute
+)
+
+// ProductChanged единое событие изменения товара для всех баз данных.
+// Изменения обнаруживаются в самих базах: потоками изменений MongoDB, журналом изменений
+// на триггерах в SQL-базах, напрямую в хранилище в памяти
+type ProductChanged struct {
+	Database  string `json:"database"`
+	Operation string `json:"operation"`
+	ProductID int    `json:"product_id"`
+	// Product состояние товара после изменения, для удаления - перед удалением
+	Product *models.Product `json:"product,omitempty"`
+	Time    time.Time       `json:"time"`
+}
+
+// Feed отслеживает изменения товаров во всех базах данных и рассылает их подписчикам внутри процесса
+type Feed struct {
+	dbManager *storage.DBManager
+
+	mu          sync.Mutex
+	subscribers map[*subscriber]struct{}
+	// watching базы, отслеживание которых сейчас запущено
+	watching map[string]bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// subscriber подписчик на события
+type subscriber struct {
+	ch chan ProductChanged
+	// dropped количество событий, пропущенных из-за переполнения буфера
+	dropped int
+}
+
+// New создает ленту изменений и запускает отслеживание каждой зарегистрированной базы
+func New(dbManager *storage.DBManager) *Feed {
+	f := &Feed{
+		dbManager:   dbManager,
+		subscribers: make(map[*subscriber]struct{}),
+		watching:    make(map[string]bool),
+	}
+	f.ctx, f.cancel = context.WithCancel(context.Background())
+
+	for _, name := range dbManager.Names() {
+		f.wg.Add(1)
+		go f.watch(name)
+	}
+	return f
+}
+
+// Subscribe подписывает на события всех баз данных. Если подписчик не успевает читать
+// и буфер канала заполнен, новые события для него пропускаются.
+// Возвращаемая функция отменяет подписку и закрывает канал
+func (f *Feed) Subscribe(buffer int) (<-chan ProductChanged, func()) {
+	s := &subscriber{ch: make(chan ProductChanged, buffer)}
+
+	f.mu.Lock()
+	f.subscribers[s] = struct{}{}
+	f.mu.Unlock()
+
+	var once sync.Once
+	return s.ch, func() {
+		once.Do(func() {
+			f.mu.Lock()
+			delete(f.subscribers, s)
+			f.mu.Unlock()
+			close(s.ch)
+		})
+	}
+}
+
+// Publish рассылает событие всем подписчикам
+func (f *Feed) Publish(event ProductChanged) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for s := range f.subscribers {
+		select {
+		case s.ch <- event:
+		default:
+			s.dropped++
+			if s.dropped == 1 || s.dropped%1000 == 0 {
+				log.Printf("Лента изменений: подписчик не успевает обрабатывать события, пропущено %d", s.dropped)
+			}
+		}
+	}
+}
+
+// Watching сообщает, что изменения базы name отслеживаются и попадут в ленту сами.
+// Изменения баз, которые лента не отслеживает (например, MongoDB без набора реплик),
+// публикует через Publish тот, кто их выполнил
+func (f *Feed) Watching(name string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.watching[name]
+}
+
+func (f *Feed) setWatching(name string, watching bool) {
+	f.mu.Lock()
+	f.watching[name] = watching
+	f.mu.Unlock()
+}
+
+// Close останавливает отслеживание изменений
+func (f *Feed) Close() {
+	f.cancel()
+	f.wg.Wait()
+}
+
+// watch отслеживает изменения одной базы данных. После ошибки или пока база недоступна
+// отслеживание перезапускается с экспоненциально растущей задержкой и продолжается с прерванного места.
+// База считается отслеживаемой с момента, когда известна позиция начала отслеживания: изменения,
+// сделанные после него, попадут в ленту и при перезапуске
+func (f *Feed) watch(name string) {
+	defer f.wg.Done()
+
+	var position storage.WatchPosition
+	delay := restartInitialDelay
+	for {
+		started := time.Now()
+		err := f.dbManager.WatchChanges(f.ctx, name, &position, func() { f.setWatching(name, true) }, func(change storage.ProductChange) {
+			f.Publish(ProductChanged{
+				Database:  name,
+				Operation: change.Operation,
+				ProductID: change.ProductID,
+				Product:   change.Product,
+				Time:      change.Time,
+			})
+		})
+		if f.ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, storage.ErrWatchNotSupported) {
+			f.setWatching(name, false)
+			log.Printf("Лента изменений: база %s: %v", name, err)
+			return
+		}
+
+		// Если отслеживание проработало долго, следующая ошибка считается новой и задержка сбрасывается
+		if time.Since(started) > restartMaxDelay {
+			delay = restartInitialDelay
+		}
+
+		var unavailable *storage.UnavailableError
+		if !errors.As(err, &unavailable) {
+			log.Printf("Лента изменений: ошибка отслеживания базы %s: %v. Повтор через %v", name, err, delay)
+		}
+
+		select {
+		case <-f.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, restartMaxDelay)
+	}
+}
@@ -0,0 +1,417 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Драйверы баз данных, поддерживаемые приложением
+const (
+	DriverMongoDB  = "mongodb"
+	DriverPostgres = "postgres"
+	DriverMySQL    = "mysql"
+	DriverSQLite   = "sqlite"
+	DriverMemory   = "memory"
+)
+
+// DefaultPath путь к файлу конфигурации по умолчанию
+const DefaultPath = "config.json"
+
+// Config конфигурация приложения
+type Config struct {
+	Databases []DatabaseConfig `json:"databases"`
+	// Reconciliation периодическая сверка товаров между базами данных
+	Reconciliation ReconciliationConfig `json:"reconciliation,omitempty"`
+	// Replication односторонняя репликация изменений из ведущей базы в ведомые
+	Replication ReplicationConfig `json:"replication,omitempty"`
+	// Webhooks доставка уведомлений об изменениях товаров внешним системам
+	Webhooks WebhooksConfig `json:"webhooks,omitempty"`
+	// Import импорт прайс-листов поставщиков
+	Import ImportConfig `json:"import,omitempty"`
+}
+
+// ImportConfig параметры импорта прайс-листов
+type ImportConfig struct {
+	// Columns дополнительные заголовки столбцов для полей товара, например {"price": ["Цена опт"]}.
+	// Заголовки по умолчанию ("Наименование", "Цена" и другие) тоже распознаются
+	Columns map[string][]string `json:"columns,omitempty"`
+}
+
+// WebhooksConfig параметры доставки вебхуков
+type WebhooksConfig struct {
+	// StorePath файл SQLite с подписками и очередью доставок (по умолчанию data/webhooks.sqlite)
+	StorePath string `json:"store_path,omitempty"`
+	// MaxAttempts количество попыток доставки, после которого уведомление попадает в список недоставленных (по умолчанию 10)
+	MaxAttempts int `json:"max_attempts,omitempty"`
+	// Timeout таймаут одного запроса к получателю (по умолчанию 10s)
+	Timeout string `json:"timeout,omitempty"`
+	// AllowedNetworks подсети CIDR во внутренних сетях, в которые разрешена доставка (например, 127.0.0.1/32
+	// для локального получателя). Без них уведомления отправляются только на публичные адреса
+	AllowedNetworks []string `json:"allowed_networks,omitempty"`
+}
+
+// ReplicationConfig параметры односторонней репликации
+type ReplicationConfig struct {
+	// Source ведущая база; изменения, выполненные в ней через API, переносятся в ведомые базы.
+	// Пустое значение отключает репликацию
+	Source string `json:"source,omitempty"`
+	// Followers ведомые базы
+	Followers []string `json:"followers,omitempty"`
+	// QueuePath файл SQLite с очередью изменений (по умолчанию data/replication.sqlite)
+	QueuePath string `json:"queue_path,omitempty"`
+	// MaxAttempts количество попыток применения изменения, после которого оно считается неудавшимся (по умолчанию 20)
+	MaxAttempts int `json:"max_attempts,omitempty"`
+}
+
+// ReconciliationConfig параметры фоновой сверки баз данных
+type ReconciliationConfig struct {
+	// Interval период запуска сверки (например, "1h"); пустое значение отключает фоновую сверку
+	Interval string `json:"interval,omitempty"`
+	// Key ключ сопоставления товаров: id (по умолчанию) или name_supplier
+	Key string `json:"key,omitempty"`
+}
+
+// DatabaseConfig описание логической базы данных
+type DatabaseConfig struct {
+	// Name имя логической базы, оно же префикс маршрутов API (/{name}/products)
+	Name string `json:"name"`
+	// Driver тип хранилища: mongodb, postgres, mysql, sqlite или memory
+	Driver string `json:"driver"`
+	// DSN строка подключения в формате драйвера (для sqlite - путь к файлу или ":memory:").
+	// Если не задана, строка собирается из отдельных параметров подключения
+	DSN string `json:"dsn,omitempty"`
+	// Параметры подключения к серверу СУБД
+	Host     string `json:"host,omitempty"`
+	Port     int    `json:"port,omitempty"`
+	User     string `json:"user,omitempty"`
+	Password string `json:"password,omitempty"`
+	Database string `json:"database,omitempty"`
+	// Required - без этой базы сервис не считается готовым (/readyz), по умолчанию true
+	Required *bool `json:"required,omitempty"`
+	// Options дополнительные параметры драйвера
+	Options map[string]string `json:"options,omitempty"`
+}
+
+// dbNamePattern допустимое имя логической базы данных (используется в URL)
+var dbNamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// reservedNames имена, занятые служебными маршрутами приложения
+var reservedNames = map[string]bool{
+	"static":         true,
+	"databases":      true,
+	"healthz":        true,
+	"readyz":         true,
+	"metrics":        true,
+	"all":            true,
+	"reconciliation": true,
+	"replication":    true,
+	"ws":             true,
+}
+
+// knownDrivers множество поддерживаемых драйверов
+var knownDrivers = map[string]bool{
+	DriverMongoDB:  true,
+	DriverPostgres: true,
+	DriverMySQL:    true,
+	DriverSQLite:   true,
+	DriverMemory:   true,
+}
+
+// Load читает конфигурацию из JSON-файла и проверяет ее корректность
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать файл конфигурации %s: %v", path, err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("ошибка разбора файла конфигурации %s: %v", path, err)
+	}
+
+	if err := cfg.prepare(); err != nil {
+		return nil, fmt.Errorf("некорректная конфигурация %s: %v", path, err)
+	}
+
+	return &cfg, nil
+}
+
+// prepare применяет переменные окружения и проверяет итоговую конфигурацию
+func (c *Config) prepare() error {
+	if err := c.ApplyEnv(); err != nil {
+		return err
+	}
+	return c.Validate()
+}
+
+// FromEnv загружает конфигурацию из файла, указанного в переменной окружения CONFIG_PATH.
+// Если переменная не задана и файла config.json нет, используется конфигурация по умолчанию
+func FromEnv() (*Config, error) {
+	path := os.Getenv("CONFIG_PATH")
+	if path == "" {
+		if _, err := os.Stat(DefaultPath); errors.Is(err, os.ErrNotExist) {
+			cfg := Default()
+			if err := cfg.prepare(); err != nil {
+				return nil, fmt.Errorf("некорректная конфигурация по умолчанию: %v", err)
+			}
+			return cfg, nil
+		}
+		path = DefaultPath
+	}
+	return Load(path)
+}
+
+// Validate проверяет корректность конфигурации
+func (c *Config) Validate() error {
+	if len(c.Databases) == 0 {
+		return fmt.Errorf("не описано ни одной базы данных")
+	}
+
+	seen := make(map[string]bool)
+	for i, db := range c.Databases {
+		if !dbNamePattern.MatchString(db.Name) {
+			return fmt.Errorf("база данных #%d: недопустимое имя %q", i+1, db.Name)
+		}
+		if reservedNames[db.Name] {
+			return fmt.Errorf("база данных %s: имя зарезервировано", db.Name)
+		}
+		if seen[db.Name] {
+			return fmt.Errorf("база данных %s описана повторно", db.Name)
+		}
+		seen[db.Name] = true
+
+		if !knownDrivers[db.Driver] {
+			return fmt.Errorf("база данных %s: неизвестный драйвер %q", db.Name, db.Driver)
+		}
+		if err := db.validateConnection(); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Reconciliation.validate(); err != nil {
+		return err
+	}
+	if err := c.Replication.validate(seen); err != nil {
+		return err
+	}
+	if err := c.Import.validate(); err != nil {
+		return err
+	}
+	if _, err := c.Webhooks.TimeoutOrDefault(); err != nil {
+		return err
+	}
+	_, err := c.Webhooks.Networks()
+	return err
+}
+
+// importFields поля товара, для которых можно задать заголовки столбцов прайс-листа
+var importFields = map[string]bool{
+	"id": true, "name": true, "category": true, "price": true, "description": true, "in_stock": true, "supplier": true,
+}
+
+// validate проверяет, что заголовки столбцов заданы для известных полей товара
+func (i ImportConfig) validate() error {
+	for field := range i.Columns {
+		if !importFields[field] {
+			return fmt.Errorf("import: неизвестное поле %q в columns", field)
+		}
+	}
+	return nil
+}
+
+// validate проверяет, что ведущая и ведомые базы репликации описаны в конфигурации
+func (r ReplicationConfig) validate(databases map[string]bool) error {
+	if r.Source == "" {
+		if len(r.Followers) > 0 {
+			return fmt.Errorf("replication: не задана ведущая база (source)")
+		}
+		return nil
+	}
+	if !databases[r.Source] {
+		return fmt.Errorf("replication: ведущая база %s не описана в конфигурации", r.Source)
+	}
+	if len(r.Followers) == 0 {
+		return fmt.Errorf("replication: не заданы ведомые базы (followers)")
+	}
+
+	seen := make(map[string]bool)
+	for _, follower := range r.Followers {
+		switch {
+		case !databases[follower]:
+			return fmt.Errorf("replication: ведомая база %s не описана в конфигурации", follower)
+		case follower == r.Source:
+			return fmt.Errorf("replication: база %s не может быть ведущей и ведомой одновременно", follower)
+		case seen[follower]:
+			return fmt.Errorf("replication: ведомая база %s указана повторно", follower)
+		}
+		seen[follower] = true
+	}
+	if r.MaxAttempts < 0 {
+		return fmt.Errorf("replication: параметр max_attempts должен быть положительным")
+	}
+	return nil
+}
+
+// StorePathOrDefault путь к файлу подписок
+func (w WebhooksConfig) StorePathOrDefault() string {
+	if w.StorePath == "" {
+		return "data/webhooks.sqlite"
+	}
+	return w.StorePath
+}
+
+// MaxAttemptsOrDefault количество попыток доставки уведомления
+func (w WebhooksConfig) MaxAttemptsOrDefault() int {
+	if w.MaxAttempts <= 0 {
+		return 10
+	}
+	return w.MaxAttempts
+}
+
+// TimeoutOrDefault таймаут запроса к получателю
+func (w WebhooksConfig) TimeoutOrDefault() (time.Duration, error) {
+	if w.Timeout == "" {
+		return 10 * time.Second, nil
+	}
+	timeout, err := time.ParseDuration(w.Timeout)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("webhooks: параметр timeout должен быть положительной длительностью (например, 10s)")
+	}
+	return timeout, nil
+}
+
+// Networks разбирает подсети, в которые разрешена доставка
+func (w WebhooksConfig) Networks() ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(w.AllowedNetworks))
+	for _, cidr := range w.AllowedNetworks {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("webhooks: неверная подсеть %q в allowed_networks: ожидается CIDR, например 127.0.0.1/32", cidr)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// QueuePathOrDefault путь к файлу очереди репликации
+func (r ReplicationConfig) QueuePathOrDefault() string {
+	if r.QueuePath == "" {
+		return "data/replication.sqlite"
+	}
+	return r.QueuePath
+}
+
+// MaxAttemptsOrDefault количество попыток применения изменения
+func (r ReplicationConfig) MaxAttemptsOrDefault() int {
+	if r.MaxAttempts == 0 {
+		return 20
+	}
+	return r.MaxAttempts
+}
+
+// validate проверяет параметры фоновой сверки
+func (r ReconciliationConfig) validate() error {
+	if _, err := r.IntervalDuration(); err != nil {
+		return err
+	}
+	if r.Key != "" && r.Key != "id" && r.Key != "name_supplier" {
+		return fmt.Errorf("reconciliation: неизвестный ключ сверки %q (допустимо: id, name_supplier)", r.Key)
+	}
+	return nil
+}
+
+// IntervalDuration период фоновой сверки (0 - сверка отключена)
+func (r ReconciliationConfig) IntervalDuration() (time.Duration, error) {
+	if r.Interval == "" {
+		return 0, nil
+	}
+	interval, err := time.ParseDuration(r.Interval)
+	if err != nil || interval <= 0 {
+		return 0, fmt.Errorf("reconciliation: параметр interval должен быть положительной длительностью (например, 1h)")
+	}
+	return interval, nil
+}
+
+// IsRequired сообщает, нужна ли база для готовности сервиса
+func (d DatabaseConfig) IsRequired() bool {
+	return d.Required == nil || *d.Required
+}
+
+// Option возвращает строковый параметр драйвера или значение по умолчанию
+func (d DatabaseConfig) Option(key, def string) string {
+	if value, ok := d.Options[key]; ok && value != "" {
+		return value
+	}
+	return def
+}
+
+// IntOption возвращает целочисленный параметр драйвера или значение по умолчанию
+func (d DatabaseConfig) IntOption(key string, def int) (int, error) {
+	value, ok := d.Options[key]
+	if !ok || value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("база данных %s: параметр %s должен быть целым числом", d.Name, key)
+	}
+	return n, nil
+}
+
+// DurationOption возвращает параметр-длительность (например, "5m") или значение по умолчанию
+func (d DatabaseConfig) DurationOption(key string, def time.Duration) (time.Duration, error) {
+	value, ok := d.Options[key]
+	if !ok || value == "" {
+		return def, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("база данных %s: параметр %s должен быть длительностью (например, 30s)", d.Name, key)
+	}
+	return duration, nil
+}
+
+// Default конфигурация, соответствующая стенду docker-compose.
+// Пароли не хранятся в коде и задаются переменными окружения (например, SUPPLIERS_DB_PASSWORD)
+func Default() *Config {
+	return &Config{
+		Databases: []DatabaseConfig{
+			{
+				Name:     "products_db",
+				Driver:   DriverMongoDB,
+				Host:     "mongo_db",
+				Port:     27017,
+				User:     "user",
+				Database: "products_db",
+				Options:  map[string]string{"auth_mechanism": "SCRAM-SHA-256"},
+			},
+			{
+				Name:     "suppliers_db",
+				Driver:   DriverPostgres,
+				Host:     "postgres_db",
+				Port:     5432,
+				User:     "user",
+				Database: "suppliers_db",
+			},
+			{
+				Name:     "inventory_db",
+				Driver:   DriverMySQL,
+				Host:     "mysql_db",
+				Port:     3306,
+				User:     "user",
+				Database: "inventory_db",
+			},
+			{
+				Name:   "edge_db",
+				Driver: DriverSQLite,
+				DSN:    "data/edge_db.sqlite",
+			},
+		},
+	}
+}
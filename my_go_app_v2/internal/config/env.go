@@ -0,0 +1,141 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix префикс переменных окружения логической базы данных (suppliers_db -> SUPPLIERS_DB)
+func (d DatabaseConfig) EnvPrefix() string {
+	return strings.ToUpper(d.Name)
+}
+
+// EnvName имя переменной окружения для параметра подключения (например, SUPPLIERS_DB_PASSWORD)
+func (d DatabaseConfig) EnvName(param string) string {
+	return d.EnvPrefix() + "_" + strings.ToUpper(param)
+}
+
+// lookupEnv возвращает значение параметра из переменной окружения NAME
+// или из файла, путь к которому указан в NAME_FILE (в стиле Docker secrets).
+// Сообщения об ошибках содержат только имена переменных и путь к файлу, но не само значение
+func lookupEnv(name string) (string, bool, error) {
+	value, hasValue := os.LookupEnv(name)
+	path, hasFile := os.LookupEnv(name + "_FILE")
+
+	if hasValue && hasFile {
+		return "", false, fmt.Errorf("заданы одновременно переменные %s и %s_FILE", name, name)
+	}
+	if hasFile {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", false, fmt.Errorf("не удалось прочитать файл %s из переменной %s_FILE: %v", path, name, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), true, nil
+	}
+	return value, hasValue, nil
+}
+
+// applyEnv переопределяет параметры подключения значениями из переменных окружения
+func (d *DatabaseConfig) applyEnv() error {
+	params := []struct {
+		name  string
+		value *string
+	}{
+		{"dsn", &d.DSN},
+		{"host", &d.Host},
+		{"user", &d.User},
+		{"password", &d.Password},
+		{"database", &d.Database},
+	}
+
+	for _, param := range params {
+		value, ok, err := lookupEnv(d.EnvName(param.name))
+		if err != nil {
+			return fmt.Errorf("база данных %s: %v", d.Name, err)
+		}
+		if ok {
+			*param.value = value
+		}
+	}
+
+	port, ok, err := lookupEnv(d.EnvName("port"))
+	if err != nil {
+		return fmt.Errorf("база данных %s: %v", d.Name, err)
+	}
+	if ok {
+		d.Port, err = strconv.Atoi(port)
+		if err != nil {
+			return fmt.Errorf("база данных %s: переменная %s должна содержать номер порта", d.Name, d.EnvName("port"))
+		}
+	}
+
+	return nil
+}
+
+// ApplyEnv переопределяет параметры подключения всех баз значениями из переменных окружения
+func (c *Config) ApplyEnv() error {
+	for i := range c.Databases {
+		if err := c.Databases[i].applyEnv(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// missingParam формирует ошибку об отсутствующем параметре подключения
+func (d DatabaseConfig) missingParam(param string) error {
+	return fmt.Errorf("база данных %s: не задан параметр %s (поле %q в конфигурации, переменная окружения %s или %s_FILE)",
+		d.Name, param, param, d.EnvName(param), d.EnvName(param))
+}
+
+// validateConnection проверяет, что для драйвера заданы все необходимые параметры подключения
+func (d DatabaseConfig) validateConnection() error {
+	switch d.Driver {
+	case DriverMemory:
+		return nil
+	case DriverSQLite:
+		if d.DSN == "" {
+			return d.missingParam("dsn")
+		}
+		return nil
+	}
+
+	// Полная строка подключения заменяет отдельные параметры
+	if d.DSN != "" {
+		return nil
+	}
+
+	required := []struct {
+		name  string
+		value string
+	}{
+		{"host", d.Host},
+		{"user", d.User},
+		{"password", d.Password},
+		{"database", d.Database},
+	}
+	for _, param := range required {
+		if param.value == "" {
+			return d.missingParam(param.name)
+		}
+	}
+
+	return nil
+}
+
+// Redact скрывает пароль и строку подключения в тексте сообщения
+func (d DatabaseConfig) Redact(message string) string {
+	secrets := []string{d.Password, url.QueryEscape(d.Password), url.PathEscape(d.Password)}
+	if d.Driver != DriverSQLite {
+		secrets = append([]string{d.DSN}, secrets...)
+	}
+	for _, secret := range secrets {
+		if secret != "" {
+			message = strings.ReplaceAll(message, secret, "***")
+		}
+	}
+	return message
+}
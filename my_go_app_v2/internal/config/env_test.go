@@ -0,0 +1,108 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplyEnv(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(secret, []byte("s3cret\r\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		want DatabaseConfig
+		// err часть текста ожидаемой ошибки ("" - ошибки нет)
+		err string
+	}{
+		{
+			name: "без переменных",
+			want: DatabaseConfig{Host: "config-host", Port: 5432, User: "app"},
+		},
+		{
+			name: "переменные заменяют значения конфигурации",
+			env:  map[string]string{"SUPPLIERS_DB_HOST": "env-host", "SUPPLIERS_DB_PORT": "6432", "SUPPLIERS_DB_DATABASE": "suppliers"},
+			want: DatabaseConfig{Host: "env-host", Port: 6432, User: "app", Database: "suppliers"},
+		},
+		{
+			name: "значение из файла _FILE без перевода строки",
+			env:  map[string]string{"SUPPLIERS_DB_PASSWORD_FILE": secret},
+			want: DatabaseConfig{Host: "config-host", Port: 5432, User: "app", Password: "s3cret"},
+		},
+		{
+			name: "пустая переменная тоже заменяет значение",
+			env:  map[string]string{"SUPPLIERS_DB_USER": ""},
+			want: DatabaseConfig{Host: "config-host", Port: 5432},
+		},
+		{
+			name: "переменная и _FILE одновременно",
+			env:  map[string]string{"SUPPLIERS_DB_PASSWORD": "s3cret", "SUPPLIERS_DB_PASSWORD_FILE": secret},
+			err:  "SUPPLIERS_DB_PASSWORD и SUPPLIERS_DB_PASSWORD_FILE",
+		},
+		{
+			name: "файл _FILE не найден",
+			env:  map[string]string{"SUPPLIERS_DB_PASSWORD_FILE": secret + ".missing"},
+			err:  "SUPPLIERS_DB_PASSWORD_FILE",
+		},
+		{
+			name: "порт не число",
+			env:  map[string]string{"SUPPLIERS_DB_PORT": "postgres"},
+			err:  "SUPPLIERS_DB_PORT должна содержать номер порта",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			cfg := DatabaseConfig{Name: "suppliers_db", Driver: DriverPostgres, Host: "config-host", Port: 5432, User: "app"}
+
+			err := cfg.applyEnv()
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("получено %v, ожидалась ошибка с %q", err, tt.err)
+				}
+				if strings.Contains(err.Error(), "s3cret") {
+					t.Fatalf("ошибка содержит значение секрета: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("неожиданная ошибка: %v", err)
+			}
+
+			if cfg.Host != tt.want.Host || cfg.Port != tt.want.Port || cfg.User != tt.want.User ||
+				cfg.Password != tt.want.Password || cfg.Database != tt.want.Database || cfg.DSN != tt.want.DSN {
+				t.Fatalf("получено %+v, ожидалось %+v", cfg, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadMissingParam(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{"databases": [{"name": "suppliers_db", "driver": "postgres", "host": "db", "user": "app", "database": "suppliers"}]}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Load(path)
+	if err == nil || !strings.Contains(err.Error(), "SUPPLIERS_DB_PASSWORD или SUPPLIERS_DB_PASSWORD_FILE") {
+		t.Fatalf("получено %v, ожидалась ошибка об отсутствующем пароле", err)
+	}
+
+	t.Setenv("SUPPLIERS_DB_PASSWORD", "s3cret")
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if cfg.Databases[0].Password != "s3cret" {
+		t.Fatalf("получен пароль %q, ожидалось значение переменной окружения", cfg.Databases[0].Password)
+	}
+}
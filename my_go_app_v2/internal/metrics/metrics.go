@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// httpRequests количество обработанных HTTP-запросов
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Количество обработанных HTTP-запросов по маршруту, методу и коду ответа.",
+	}, []string{"route", "method", "status"})
+
+	// httpDuration время обработки HTTP-запросов
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Время обработки HTTP-запросов по маршруту и методу.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	// storageDuration время выполнения операций хранилищ
+	storageDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "storage_operation_duration_seconds",
+		Help:    "Время выполнения операций с товарами по базе данных, драйверу и операции.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"database", "driver", "operation"})
+
+	// storageErrors количество ошибок операций хранилищ
+	storageErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "storage_operation_errors_total",
+		Help: "Количество ошибок операций с товарами по базе данных, драйверу и операции.",
+	}, []string{"database", "driver", "operation"})
+)
+
+// Handler отдает метрики в текстовом формате Prometheus
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// statusRecorder запоминает код ответа, записанный обработчиком
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Hijack передает соединение обработчику (нужно для WebSocket, который проверяет http.Hijacker напрямую)
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("соединение не поддерживает http.Hijacker")
+	}
+	if r.status == 0 {
+		r.status = http.StatusSwitchingProtocols
+	}
+	return hijacker.Hijack()
+}
+
+// Unwrap дает http.ResponseController доступ к исходному ResponseWriter
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Instrument оборачивает обработчик сбором метрик HTTP.
+// route вычисляет метку маршрута по запросу и должна возвращать ограниченный набор значений
+func Instrument(route func(r *http.Request) string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := &statusRecorder{ResponseWriter: w}
+		start := time.Now()
+
+		next.ServeHTTP(recorder, r)
+
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		label := route(r)
+		httpDuration.WithLabelValues(label, r.Method).Observe(time.Since(start).Seconds())
+		httpRequests.WithLabelValues(label, r.Method, strconv.Itoa(status)).Inc()
+	})
+}
+
+// ObserveStorage учитывает длительность и результат операции хранилища
+func ObserveStorage(database, driver, operation string, start time.Time, err error) {
+	storageDuration.WithLabelValues(database, driver, operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		storageErrors.WithLabelValues(database, driver, operation).Inc()
+	}
+}
+
+// RegisterDBStats публикует статистику пула соединений database/sql
+// (открытые соединения, занятые, количество и время ожидания) с меткой db_name
+func RegisterDBStats(name string, db *sql.DB) {
+	collector := collectors.NewDBStatsCollector(db, name)
+	if err := prometheus.Register(collector); err != nil {
+		// При повторном подключении к базе заменяем сборщик старого пула
+		if already, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			prometheus.Unregister(already.ExistingCollector)
+			prometheus.MustRegister(collector)
+		}
+	}
+}
@@ -1,12 +1,18 @@
-package models
-
-// Product представляет строительный товар в каталоге
-type Product struct {
-	ID          int     `json:"id"`
-	Name        string  `json:"name"`
-	Category    string  `json:"category"`
-	Price       float64 `json:"price"`
-	Description string  `json:"description"`
-	InStock     bool    `json:"in_stock"`
-	Supplier    string  `json:"supplier"`
-}
+package models
+
+// Product представляет строительный товар в каталоге.
+// Тег schema задает ограничения полей, из которых строится валидатор коллекции MongoDB:
+// required - поле обязательно, minLength и maxLength - длина строки в символах,
+// minimum и maximum - границы числа
+type Product struct {
+	ID          int     `json:"id" schema:"required,minimum=1"`
+	Name        string  `json:"name" schema:"required,minLength=1,maxLength=100"`
+	Category    string  `json:"category" schema:"required,maxLength=50"`
+	Price       float64 `json:"price" schema:"required,minimum=0"`
+	Description string  `json:"description"`
+	InStock     bool    `json:"in_stock" schema:"required"`
+	Supplier    string  `json:"supplier" schema:"required,maxLength=100"`
+	// Version номер версии товара: 1 при создании, увеличивается при каждом изменении.
+	// Назначается хранилищем, значение из запроса клиента не сохраняется
+	Version int64 `json:"version" schema:"required,minimum=1"`
+}
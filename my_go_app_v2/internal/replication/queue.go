@@ -0,0 +1,244 @@
+package replication
+
+import (
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"project/internal/models"
+
+	_ "modernc.org/sqlite"
+)
+
+// Состояния элемента очереди репликации
+const (
+	statusPending = "pending"
+	statusFailed  = "failed"
+)
+
+// queueSchema таблицы очереди: элементы, ожидающие применения к ведомым базам,
+// и время последнего успешно примененного изменения для каждой ведомой базы
+const queueSchema = `
+CREATE TABLE IF NOT EXISTS replication_queue (
+	id              INTEGER PRIMARY KEY AUTOINCREMENT,
+	follower        TEXT    NOT NULL,
+	operation       TEXT    NOT NULL,
+	product_id      INTEGER NOT NULL,
+	payload         TEXT    NOT NULL,
+	created_at      INTEGER NOT NULL,
+	attempts        INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at INTEGER NOT NULL,
+	last_error      TEXT    NOT NULL DEFAULT '',
+	status          TEXT    NOT NULL DEFAULT 'pending'
+);
+CREATE INDEX IF NOT EXISTS replication_queue_follower_idx ON replication_queue (follower, status, id);
+CREATE TABLE IF NOT EXISTS replication_followers (
+	follower   TEXT PRIMARY KEY,
+	applied_at INTEGER NOT NULL
+);`
+
+// Item изменение, ожидающее применения к ведомой базе
+type Item struct {
+	ID            int64          `json:"id"`
+	Follower      string         `json:"follower"`
+	Operation     string         `json:"operation"`
+	ProductID     int            `json:"product_id"`
+	Product       models.Product `json:"product"`
+	CreatedAt     time.Time      `json:"created_at"`
+	Attempts      int            `json:"attempts"`
+	NextAttemptAt time.Time      `json:"next_attempt_at"`
+	LastError     string         `json:"last_error,omitempty"`
+}
+
+// queue надежная очередь изменений в файле SQLite: элементы переживают перезапуск сервера
+type queue struct {
+	db *sql.DB
+}
+
+// openQueue открывает (и при необходимости создает) файл очереди
+func openQueue(path string) (*queue, error) {
+	if path != ":memory:" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, err
+		}
+		path = "file:" + path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(queueSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &queue{db: db}, nil
+}
+
+// close закрывает файл очереди
+func (q *queue) close() error {
+	return q.db.Close()
+}
+
+// push добавляет изменение в очередь каждой ведомой базы одной транзакцией
+func (q *queue) push(followers []string, operation string, product models.Product) error {
+	payload, err := json.Marshal(product)
+	if err != nil {
+		return err
+	}
+
+	tx, err := q.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UnixNano()
+	for _, follower := range followers {
+		_, err := tx.Exec(`INSERT INTO replication_queue (follower, operation, product_id, payload, created_at, next_attempt_at)
+			VALUES (?, ?, ?, ?, ?, ?)`, follower, operation, product.ID, string(payload), now, now)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// scanItem читает элемент очереди из строки результата
+func scanItem(row interface{ Scan(dest ...any) error }) (Item, error) {
+	var item Item
+	var payload string
+	var createdAt, nextAttemptAt int64
+	err := row.Scan(&item.ID, &item.Follower, &item.Operation, &item.ProductID, &payload,
+		&createdAt, &item.Attempts, &nextAttemptAt, &item.LastError)
+	if err != nil {
+		return Item{}, err
+	}
+	if err := json.Unmarshal([]byte(payload), &item.Product); err != nil {
+		return Item{}, err
+	}
+	item.CreatedAt = time.Unix(0, createdAt).UTC()
+	item.NextAttemptAt = time.Unix(0, nextAttemptAt).UTC()
+	return item, nil
+}
+
+const itemColumns = `id, follower, operation, product_id, payload, created_at, attempts, next_attempt_at, last_error`
+
+// head возвращает самый старый ожидающий элемент ведомой базы.
+// Изменения применяются строго по порядку, поэтому следующий элемент ждет, пока не будет применен предыдущий
+func (q *queue) head(follower string) (Item, bool, error) {
+	row := q.db.QueryRow(`SELECT `+itemColumns+` FROM replication_queue
+		WHERE follower = ? AND status = ? ORDER BY id LIMIT 1`, follower, statusPending)
+	item, err := scanItem(row)
+	if err == sql.ErrNoRows {
+		return Item{}, false, nil
+	}
+	if err != nil {
+		return Item{}, false, err
+	}
+	return item, true, nil
+}
+
+// done удаляет примененный элемент и запоминает время применения
+func (q *queue) done(item Item) error {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM replication_queue WHERE id = ?`, item.ID); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO replication_followers (follower, applied_at) VALUES (?, ?)
+		ON CONFLICT (follower) DO UPDATE SET applied_at = excluded.applied_at`, item.Follower, time.Now().UnixNano())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// retry откладывает элемент до следующей попытки
+func (q *queue) retry(item Item, cause error, nextAttempt time.Time) error {
+	_, err := q.db.Exec(`UPDATE replication_queue SET attempts = attempts + 1, next_attempt_at = ?, last_error = ? WHERE id = ?`,
+		nextAttempt.UnixNano(), cause.Error(), item.ID)
+	return err
+}
+
+// fail переводит элемент в список неудавшихся: он больше не блокирует очередь ведомой базы
+func (q *queue) fail(item Item, cause error) error {
+	_, err := q.db.Exec(`UPDATE replication_queue SET attempts = attempts + 1, last_error = ?, status = ? WHERE id = ?`,
+		cause.Error(), statusFailed, item.ID)
+	return err
+}
+
+// requeue возвращает неудавшиеся элементы в очередь; если follower пуст - для всех ведомых баз.
+// Возвращенные элементы применяются в порядке их исходного добавления
+func (q *queue) requeue(follower string) (int64, error) {
+	result, err := q.db.Exec(`UPDATE replication_queue SET status = ?, attempts = 0, next_attempt_at = ?
+		WHERE status = ? AND (? = '' OR follower = ?)`, statusPending, time.Now().UnixNano(), statusFailed, follower, follower)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// followerStats сводка очереди ведомой базы
+type followerStats struct {
+	pending       int
+	failed        int
+	oldestPending time.Time
+	appliedAt     time.Time
+}
+
+// stats возвращает сводку очереди ведомой базы
+func (q *queue) stats(follower string) (followerStats, error) {
+	var stats followerStats
+	var oldest sql.NullInt64
+	err := q.db.QueryRow(`SELECT
+			COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0),
+			MIN(CASE WHEN status = ? THEN created_at END)
+		FROM replication_queue WHERE follower = ?`,
+		statusPending, statusFailed, statusPending, follower).Scan(&stats.pending, &stats.failed, &oldest)
+	if err != nil {
+		return followerStats{}, err
+	}
+	if oldest.Valid {
+		stats.oldestPending = time.Unix(0, oldest.Int64).UTC()
+	}
+
+	var appliedAt int64
+	err = q.db.QueryRow(`SELECT applied_at FROM replication_followers WHERE follower = ?`, follower).Scan(&appliedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return followerStats{}, err
+	}
+	if err == nil {
+		stats.appliedAt = time.Unix(0, appliedAt).UTC()
+	}
+	return stats, nil
+}
+
+// failed возвращает неудавшиеся элементы ведомой базы
+func (q *queue) failed(follower string) ([]Item, error) {
+	rows, err := q.db.Query(`SELECT `+itemColumns+` FROM replication_queue
+		WHERE follower = ? AND status = ? ORDER BY id`, follower, statusFailed)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []Item{}
+	for rows.Next() {
+		item, err := scanItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
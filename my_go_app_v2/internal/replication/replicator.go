@@ -0,0 +1,279 @@
+package replication
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"project/internal/config"
+	"project/internal/models"
+	"project/internal/storage"
+)
+
+// Операции, передаваемые ведомым базам
+const (
+	OperationCreate = "create"
+	OperationUpdate = "update"
+	OperationDelete = "delete"
+)
+
+// Параметры повторных попыток применения изменения
+const (
+	retryInitialDelay = time.Second
+	retryMaxDelay     = 5 * time.Minute
+	// pollInterval период проверки очереди, если новых изменений не поступало
+	pollInterval = 5 * time.Second
+)
+
+// Replicator асинхронно переносит изменения товаров из ведущей базы в ведомые через надежную очередь
+type Replicator struct {
+	source      string
+	followers   []string
+	maxAttempts int
+
+	dbManager *storage.DBManager
+	queue     *queue
+
+	// wake - по каналу на ведомую базу, сигнал о новом элементе в очереди
+	wake map[string]chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New открывает очередь репликации и запускает по обработчику на каждую ведомую базу.
+// Если репликация не описана в конфигурации, возвращается nil
+func New(cfg config.ReplicationConfig, dbManager *storage.DBManager) (*Replicator, error) {
+	if cfg.Source == "" {
+		return nil, nil
+	}
+
+	q, err := openQueue(cfg.QueuePathOrDefault())
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть очередь репликации %s: %v", cfg.QueuePathOrDefault(), err)
+	}
+
+	r := &Replicator{
+		source:      cfg.Source,
+		followers:   cfg.Followers,
+		maxAttempts: cfg.MaxAttemptsOrDefault(),
+		dbManager:   dbManager,
+		queue:       q,
+		wake:        make(map[string]chan struct{}),
+	}
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+
+	for _, follower := range r.followers {
+		r.wake[follower] = make(chan struct{}, 1)
+		r.wg.Add(1)
+		go r.run(follower)
+	}
+
+	return r, nil
+}
+
+// Source имя ведущей базы данных
+func (r *Replicator) Source() string {
+	return r.source
+}
+
+// Enqueue ставит изменение товара ведущей базы в очередь всех ведомых баз.
+// Изменения других баз игнорируются
+func (r *Replicator) Enqueue(dbName, operation string, product models.Product) error {
+	if r == nil || dbName != r.source {
+		return nil
+	}
+	if err := r.queue.push(r.followers, operation, product); err != nil {
+		return fmt.Errorf("не удалось поставить изменение товара %d в очередь репликации: %v", product.ID, err)
+	}
+	for _, follower := range r.followers {
+		select {
+		case r.wake[follower] <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Retry возвращает неудавшиеся изменения в очередь (для всех ведомых баз, если follower пуст)
+func (r *Replicator) Retry(follower string) (int64, error) {
+	count, err := r.queue.requeue(follower)
+	if err != nil {
+		return 0, err
+	}
+	for name, wake := range r.wake {
+		if follower == "" || follower == name {
+			select {
+			case wake <- struct{}{}:
+			default:
+			}
+		}
+	}
+	return count, nil
+}
+
+// Close останавливает обработчики и закрывает очередь; непримененные изменения остаются в файле очереди
+func (r *Replicator) Close() {
+	if r == nil {
+		return
+	}
+	r.cancel()
+	r.wg.Wait()
+	if err := r.queue.close(); err != nil {
+		log.Printf("Ошибка закрытия очереди репликации: %v", err)
+	}
+}
+
+// run применяет изменения к ведомой базе по порядку, пока репликатор не будет остановлен
+func (r *Replicator) run(follower string) {
+	defer r.wg.Done()
+
+	for {
+		wait := pollInterval
+
+		item, ok, err := r.queue.head(follower)
+		switch {
+		case err != nil:
+			log.Printf("Ошибка чтения очереди репликации %s: %v", follower, err)
+		case ok:
+			if delay := time.Until(item.NextAttemptAt); delay > 0 {
+				wait = delay
+				break
+			}
+			r.process(item)
+			// Сразу переходим к следующему элементу
+			wait = 0
+		}
+
+		if wait > 0 {
+			timer := time.NewTimer(min(wait, pollInterval))
+			select {
+			case <-r.ctx.Done():
+				timer.Stop()
+				return
+			case <-r.wake[follower]:
+				timer.Stop()
+			case <-timer.C:
+			}
+		} else if r.ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// process применяет один элемент очереди и записывает результат
+func (r *Replicator) process(item Item) {
+	err := r.apply(item)
+	if err == nil {
+		if err := r.queue.done(item); err != nil {
+			log.Printf("Ошибка обновления очереди репликации %s: %v", item.Follower, err)
+		}
+		return
+	}
+
+	attempts := item.Attempts + 1
+	if attempts >= r.maxAttempts {
+		log.Printf("Репликация: изменение %s товара %d в базу %s не применено после %d попыток: %v",
+			item.Operation, item.ProductID, item.Follower, attempts, err)
+		if err := r.queue.fail(item, err); err != nil {
+			log.Printf("Ошибка обновления очереди репликации %s: %v", item.Follower, err)
+		}
+		return
+	}
+
+	delay := retryInitialDelay << min(item.Attempts, 16)
+	delay = min(delay, retryMaxDelay)
+	if err := r.queue.retry(item, err, time.Now().Add(delay)); err != nil {
+		log.Printf("Ошибка обновления очереди репликации %s: %v", item.Follower, err)
+	}
+}
+
+// apply применяет изменение к ведомой базе. Применение идемпотентно:
+// создание и обновление записывают товар независимо от того, есть ли он в ведомой базе,
+// а удаление отсутствующего товара считается успешным. Версии товаров в каждой базе свои,
+// поэтому изменения применяются без проверки версии
+func (r *Replicator) apply(item Item) error {
+	store, err := r.dbManager.Store(item.Follower)
+	if err != nil {
+		return err
+	}
+
+	_, exists, err := store.GetProduct(item.ProductID)
+	if err != nil {
+		return err
+	}
+
+	switch item.Operation {
+	case OperationCreate, OperationUpdate:
+		if exists {
+			_, err := store.UpdateProduct(item.Product, 0)
+			return err
+		}
+		_, err := store.AddProduct(item.Product)
+		return err
+	case OperationDelete:
+		if !exists {
+			return nil
+		}
+		return store.DeleteProduct(item.ProductID, 0)
+	}
+	return fmt.Errorf("неизвестная операция репликации %q", item.Operation)
+}
+
+// FollowerStatus состояние репликации в одну ведомую базу
+type FollowerStatus struct {
+	Name string `json:"name"`
+	// Pending количество изменений, ожидающих применения
+	Pending int `json:"pending"`
+	// Failed количество изменений, не примененных после всех попыток
+	Failed int `json:"failed"`
+	// LagSeconds возраст самого старого непримененного изменения
+	LagSeconds float64 `json:"lag_seconds"`
+	// LastAppliedAt время последнего примененного изменения
+	LastAppliedAt *time.Time `json:"last_applied_at,omitempty"`
+	// LastError ошибка последней попытки применения ожидающего изменения
+	LastError   string `json:"last_error,omitempty"`
+	FailedItems []Item `json:"failed_items"`
+}
+
+// Status состояние репликации
+type Status struct {
+	Source    string           `json:"source"`
+	Followers []FollowerStatus `json:"followers"`
+}
+
+// Status возвращает состояние очередей всех ведомых баз
+func (r *Replicator) Status() (Status, error) {
+	status := Status{Source: r.source, Followers: []FollowerStatus{}}
+	for _, follower := range r.followers {
+		stats, err := r.queue.stats(follower)
+		if err != nil {
+			return Status{}, err
+		}
+		failed, err := r.queue.failed(follower)
+		if err != nil {
+			return Status{}, err
+		}
+
+		followerStatus := FollowerStatus{
+			Name:        follower,
+			Pending:     stats.pending,
+			Failed:      stats.failed,
+			FailedItems: failed,
+		}
+		if !stats.oldestPending.IsZero() {
+			followerStatus.LagSeconds = time.Since(stats.oldestPending).Seconds()
+			if head, ok, err := r.queue.head(follower); err == nil && ok {
+				followerStatus.LastError = head.LastError
+			}
+		}
+		if !stats.appliedAt.IsZero() {
+			followerStatus.LastAppliedAt = &stats.appliedAt
+		}
+		status.Followers = append(status.Followers, followerStatus)
+	}
+	return status, nil
+}
@@ -0,0 +1,847 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"project/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MaxBulkOperations наибольшее число операций в одном пакете
+const MaxBulkOperations = 1000
+
+// Операции пакетного изменения товаров
+const (
+	BulkCreate = "create"
+	BulkUpsert = "upsert"
+	BulkPatch  = "patch"
+	BulkDelete = "delete"
+)
+
+// BulkOperation операция пакетного изменения
+type BulkOperation struct {
+	Op string `json:"op"`
+	// Product товар для create, upsert и patch. create без ID получает ID, назначенный базой данных
+	Product *models.Product `json:"product,omitempty"`
+	// ID товара для delete
+	ID int `json:"id,omitempty"`
+	// Fields изменяемые поля для patch
+	Fields []string `json:"fields,omitempty"`
+	// Version ожидаемая версия товара для patch и delete (0 - без проверки)
+	Version int64 `json:"version,omitempty"`
+}
+
+// ErrAtomicNotSupported хранилище не может применить пакет по принципу "все или ничего"
+var ErrAtomicNotSupported = errors.New("режим \"все или ничего\" (atomic=true) в MongoDB доступен только в наборе реплик или шардированном кластере")
+
+// targetID ID товара, указанный в операции
+func (o BulkOperation) targetID() int {
+	if o.Op == BulkDelete || o.Product == nil {
+		return o.ID
+	}
+	return o.Product.ID
+}
+
+// BulkResult результат операции пакета. Status - код в терминах HTTP:
+// 201 товар создан, 200 изменен или удален, 400 неверная операция, 404 товар не найден,
+// 409 ID занят или уже встречается в пакете, 412 версия товара не совпала с ожидаемой, 424 операция отменена из-за ошибки другой операции в режиме "все или ничего",
+// 500 ошибка базы данных
+type BulkResult struct {
+	Index   int    `json:"index"`
+	Op      string `json:"op"`
+	ID      int    `json:"id,omitempty"`
+	Status  int    `json:"status"`
+	Version int64  `json:"version,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Succeeded сообщает, что операция применена
+func (r BulkResult) Succeeded() bool {
+	return r.Status == http.StatusOK || r.Status == http.StatusCreated
+}
+
+func (r *BulkResult) succeed(status, id int, version int64) {
+	r.Status = status
+	r.ID = id
+	r.Version = version
+}
+
+func (r *BulkResult) fail(status int, err error) {
+	r.Status = status
+	r.Error = err.Error()
+}
+
+// bulkStatus код результата для ошибки операции
+func bulkStatus(err error) int {
+	var duplicate *DuplicateError
+	if errors.As(err, &duplicate) || isUniqueViolation(err) {
+		return http.StatusConflict
+	}
+	var conflict *VersionConflictError
+	if errors.As(err, &conflict) {
+		return http.StatusPreconditionFailed
+	}
+	if IsSchemaViolation(err) {
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+// newBulkResults проверяет операции и готовит результаты. Неверные операции сразу получают статус 400,
+// повторные операции с товаром, уже изменяемым пакетом, - 409, остальные - 0 (еще не выполнены).
+// valid сообщает, что все операции верны
+func newBulkResults(operations []BulkOperation) (results []BulkResult, valid bool) {
+	results = make([]BulkResult, len(operations))
+	valid = true
+	// first номер первой операции пакета с каждым ID
+	first := make(map[int]int)
+	for i, operation := range operations {
+		results[i] = BulkResult{Index: i, Op: operation.Op, ID: operation.targetID()}
+
+		var err error
+		switch operation.Op {
+		case BulkCreate:
+			if operation.Product == nil {
+				err = fmt.Errorf("не указан товар")
+			}
+		case BulkUpsert:
+			if operation.Product == nil {
+				err = fmt.Errorf("не указан товар")
+			} else if operation.Product.ID == 0 {
+				err = fmt.Errorf("для upsert нужен ID товара")
+			}
+		case BulkPatch:
+			if operation.Product == nil {
+				err = fmt.Errorf("не указан товар")
+			} else if operation.Product.ID == 0 {
+				err = fmt.Errorf("для patch нужен ID товара")
+			} else {
+				err = checkFields(operation.Fields)
+			}
+		case BulkDelete:
+			if operation.ID == 0 {
+				err = fmt.Errorf("для delete нужен ID товара")
+			}
+		default:
+			err = fmt.Errorf("неизвестная операция %q: ожидается create, upsert, patch или delete", operation.Op)
+		}
+		if err != nil {
+			results[i].fail(http.StatusBadRequest, err)
+			valid = false
+			continue
+		}
+
+		// Результат операции и событие об изменении относятся к одному состоянию товара,
+		// поэтому товар изменяется в пакете один раз
+		id := operation.targetID()
+		if id == 0 {
+			continue
+		}
+		if j, ok := first[id]; ok {
+			results[i].fail(http.StatusConflict, fmt.Errorf("товар с ID %d уже изменяет операция %d пакета", id, j))
+			valid = false
+			continue
+		}
+		first[id] = i
+	}
+	return results, valid
+}
+
+// abortBulk отменяет пакет в режиме "все или ничего": выполненные и невыполненные операции
+// получают статус 424, результат ошибочных операций сохраняется
+func abortBulk(operations []BulkOperation, results []BulkResult) {
+	for i := range results {
+		if results[i].Status != 0 && !results[i].Succeeded() {
+			continue
+		}
+		results[i] = BulkResult{
+			Index:  i,
+			Op:     operations[i].Op,
+			ID:     operations[i].targetID(),
+			Status: http.StatusFailedDependency,
+			Error:  "не применено: другая операция пакета завершилась ошибкой",
+		}
+	}
+}
+
+// ----- Хранилище в памяти -----
+
+// BulkWrite применяет операции под одной блокировкой. В режиме atomic при первой ошибке
+// все изменения пакета откатываются по журналу прежних значений
+func (m *MemoryClient) BulkWrite(operations []BulkOperation, atomic bool) ([]BulkResult, error) {
+	results, valid := newBulkResults(operations)
+	if atomic && !valid {
+		abortBulk(operations, results)
+		return results, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// journal прежнее состояние каждого измененного товара (existed=false - товара не было)
+	type previous struct {
+		product models.Product
+		existed bool
+	}
+	journal := make(map[int]previous)
+	remember := func(id int) {
+		if _, ok := journal[id]; !ok {
+			product, existed := m.products[id]
+			journal[id] = previous{product: product, existed: existed}
+		}
+	}
+	lastID := m.lastID
+
+	var changes []ProductChange
+	now := time.Now().UTC()
+	for i, operation := range operations {
+		result := &results[i]
+		if result.Status != 0 {
+			continue
+		}
+
+		switch operation.Op {
+		case BulkCreate:
+			product := *operation.Product
+			if product.ID == 0 {
+				product.ID = m.lastID + 1
+			}
+			if _, exists := m.products[product.ID]; exists {
+				result.fail(http.StatusConflict, &DuplicateError{ID: product.ID})
+				break
+			}
+			remember(product.ID)
+			m.lastID = max(m.lastID, product.ID)
+			product.Version = 1
+			m.products[product.ID] = product
+			result.succeed(http.StatusCreated, product.ID, product.Version)
+			changes = append(changes, ProductChange{Operation: ChangeCreated, ProductID: product.ID, Product: &product, Time: now})
+
+		case BulkUpsert:
+			product := *operation.Product
+			remember(product.ID)
+			change := ProductChange{Operation: ChangeUpdated, ProductID: product.ID, Product: &product, Time: now}
+			if current, exists := m.products[product.ID]; exists {
+				product.Version = current.Version + 1
+				result.succeed(http.StatusOK, product.ID, product.Version)
+			} else {
+				m.lastID = max(m.lastID, product.ID)
+				product.Version = 1
+				result.succeed(http.StatusCreated, product.ID, product.Version)
+				change.Operation = ChangeCreated
+			}
+			m.products[product.ID] = product
+			changes = append(changes, change)
+
+		case BulkPatch:
+			current, exists := m.products[operation.Product.ID]
+			switch {
+			case !exists:
+				result.fail(http.StatusNotFound, fmt.Errorf("продукт с ID %d не найден", operation.Product.ID))
+			case operation.Version != 0 && current.Version != operation.Version:
+				result.fail(http.StatusPreconditionFailed, &VersionConflictError{ID: current.ID, Expected: operation.Version, Current: current.Version})
+			default:
+				remember(current.ID)
+				CopyFields(&current, *operation.Product, operation.Fields)
+				current.Version++
+				m.products[current.ID] = current
+				result.succeed(http.StatusOK, current.ID, current.Version)
+				changes = append(changes, ProductChange{Operation: ChangeUpdated, ProductID: current.ID, Product: &current, Time: now})
+			}
+
+		case BulkDelete:
+			current, exists := m.products[operation.ID]
+			if !exists {
+				result.fail(http.StatusNotFound, fmt.Errorf("продукт с ID %d не найден", operation.ID))
+				break
+			}
+			if operation.Version != 0 && current.Version != operation.Version {
+				result.fail(http.StatusPreconditionFailed, &VersionConflictError{ID: current.ID, Expected: operation.Version, Current: current.Version})
+				break
+			}
+			remember(operation.ID)
+			delete(m.products, operation.ID)
+			result.succeed(http.StatusOK, operation.ID, 0)
+			changes = append(changes, ProductChange{Operation: ChangeDeleted, ProductID: operation.ID, Product: &current, Time: now})
+		}
+
+		if atomic && !result.Succeeded() {
+			for id, prev := range journal {
+				if prev.existed {
+					m.products[id] = prev.product
+				} else {
+					delete(m.products, id)
+				}
+			}
+			m.lastID = lastID
+			abortBulk(operations, results)
+			return results, nil
+		}
+	}
+
+	for _, change := range changes {
+		m.watchers.notify(change)
+	}
+	return results, nil
+}
+
+// ----- SQL -----
+
+// BulkWrite выполняет операции в одной транзакции. Без atomic каждая операция выполняется
+// внутри точки сохранения: ошибка откатывает только эту операцию, и транзакция PostgreSQL
+// остается пригодной для следующих. В режиме atomic первая ошибка откатывает всю транзакцию
+func (s *sqlStore) BulkWrite(operations []BulkOperation, atomic bool) ([]BulkResult, error) {
+	results, valid := newBulkResults(operations)
+	if atomic && !valid {
+		abortBulk(operations, results)
+		return results, nil
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	explicitIDs := false
+	for i, operation := range operations {
+		result := &results[i]
+		if result.Status != 0 {
+			continue
+		}
+
+		if !atomic {
+			if _, err := tx.Exec(`SAVEPOINT bulk_item`); err != nil {
+				return nil, err
+			}
+		}
+
+		s.applyBulk(tx, operation, result)
+		if result.Status == http.StatusCreated && operation.Product.ID != 0 {
+			explicitIDs = true
+		}
+
+		switch {
+		case atomic && !result.Succeeded():
+			abortBulk(operations, results)
+			return results, nil
+		case atomic:
+		case result.Succeeded():
+			if _, err := tx.Exec(`RELEASE SAVEPOINT bulk_item`); err != nil {
+				return nil, err
+			}
+		default:
+			if _, err := tx.Exec(`ROLLBACK TO SAVEPOINT bulk_item`); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	if explicitIDs {
+		s.syncSequence()
+	}
+	return results, nil
+}
+
+// applyBulk выполняет одну операцию пакета в транзакции и записывает ее результат
+func (s *sqlStore) applyBulk(tx *sql.Tx, operation BulkOperation, result *BulkResult) {
+	switch operation.Op {
+	case BulkCreate:
+		product := *operation.Product
+		if product.ID == 0 {
+			id, err := s.insertGenerated(tx, product)
+			if err != nil {
+				result.fail(bulkStatus(err), err)
+				return
+			}
+			product.ID = id
+		} else if err := s.insertProduct(tx, product); err != nil {
+			result.fail(bulkStatus(err), err)
+			return
+		}
+		result.succeed(http.StatusCreated, product.ID, 1)
+
+	case BulkUpsert:
+		product := *operation.Product
+		current, exists, err := s.selectVersion(tx, product.ID)
+		if err != nil {
+			result.fail(http.StatusInternalServerError, err)
+			return
+		}
+		if !exists {
+			if err := s.insertProduct(tx, product); err != nil {
+				result.fail(bulkStatus(err), err)
+				return
+			}
+			result.succeed(http.StatusCreated, product.ID, 1)
+			return
+		}
+		if err := s.updateFields(tx, product, patchFields); err != nil {
+			result.fail(http.StatusInternalServerError, err)
+			return
+		}
+		result.succeed(http.StatusOK, product.ID, current+1)
+
+	case BulkPatch:
+		id := operation.Product.ID
+		current, exists, err := s.selectVersion(tx, id)
+		switch {
+		case err != nil:
+			result.fail(http.StatusInternalServerError, err)
+			return
+		case !exists:
+			result.fail(http.StatusNotFound, fmt.Errorf("продукт с ID %d не найден", id))
+			return
+		case operation.Version != 0 && current != operation.Version:
+			result.fail(http.StatusPreconditionFailed, &VersionConflictError{ID: id, Expected: operation.Version, Current: current})
+			return
+		}
+		if err := s.updateFields(tx, *operation.Product, operation.Fields); err != nil {
+			result.fail(bulkStatus(err), err)
+			return
+		}
+		result.succeed(http.StatusOK, id, current+1)
+
+	case BulkDelete:
+		query, args := `DELETE FROM products WHERE id = ?`, []any{operation.ID}
+		if operation.Version != 0 {
+			query += ` AND version = ?`
+			args = append(args, operation.Version)
+		}
+		res, err := tx.Exec(s.dialect.rebind(query), args...)
+		if err != nil {
+			result.fail(http.StatusInternalServerError, err)
+			return
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			result.fail(http.StatusInternalServerError, err)
+			return
+		}
+		if affected == 0 {
+			// Товара нет или его версия не равна ожидаемой
+			current, exists, err := s.selectVersion(tx, operation.ID)
+			switch {
+			case err != nil:
+				result.fail(http.StatusInternalServerError, err)
+			case exists:
+				result.fail(http.StatusPreconditionFailed, &VersionConflictError{ID: operation.ID, Expected: operation.Version, Current: current})
+			default:
+				result.fail(http.StatusNotFound, fmt.Errorf("продукт с ID %d не найден", operation.ID))
+			}
+			return
+		}
+		result.succeed(http.StatusOK, operation.ID, 0)
+	}
+}
+
+// ----- MongoDB -----
+
+// mongoIllegalOperation код ошибки сервера MongoDB, с которым отдельный сервер (не набор реплик)
+// отклоняет транзакции
+const mongoIllegalOperation = 20
+
+// BulkWrite выполняет операции упорядоченным BulkWrite. Без atomic после ошибки операции
+// выполнение продолжается со следующей. В режиме atomic BulkWrite выполняется в транзакции,
+// для этого MongoDB должна работать как набор реплик; на отдельном сервере возвращается ErrAtomicNotSupported
+func (m *MongoDBClient) BulkWrite(operations []BulkOperation, atomic bool) ([]BulkResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if atomic {
+		replicated, err := m.replicated(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !replicated {
+			return nil, ErrAtomicNotSupported
+		}
+	}
+
+	results, valid := newBulkResults(operations)
+	if atomic && !valid {
+		abortBulk(operations, results)
+		return results, nil
+	}
+
+	// Удаление отсутствующего товара BulkWrite не считает ошибкой, а upsert не сообщает, создал ли он товар,
+	// поэтому наличие и версии товаров проверяются заранее и отслеживаются по ходу пакета
+	versions, err := m.existingVersions(ctx, operations, results)
+	if err != nil {
+		return nil, err
+	}
+
+	// writes операции BulkWrite, indexes - номер операции пакета для каждой из них,
+	// statuses - статус операции пакета в случае успеха, matched - операции upsert и patch,
+	// которые должны найти существующий документ
+	var writes []mongo.WriteModel
+	var indexes []int
+	statuses := make([]int, len(operations))
+	matched := make([]bool, len(operations))
+	for i, operation := range operations {
+		result := &results[i]
+		if result.Status != 0 {
+			continue
+		}
+
+		// failed операция отклонена до выполнения BulkWrite
+		var failed error
+		failedStatus := http.StatusNotFound
+
+		switch operation.Op {
+		case BulkCreate:
+			product := *operation.Product
+			if product.ID == 0 {
+				id, err := m.nextID(ctx)
+				if err != nil {
+					return nil, err
+				}
+				product.ID = id
+			}
+			product.Version = 1
+			result.ID = product.ID
+			versions[product.ID] = 1
+			statuses[i] = http.StatusCreated
+			writes = append(writes, mongo.NewInsertOneModel().SetDocument(product))
+
+		case BulkUpsert:
+			set := bson.M{"id": operation.Product.ID}
+			for _, field := range patchFields {
+				set[mongoFields[field]] = productValue(*operation.Product, field)
+			}
+			writes = append(writes, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"id": operation.Product.ID}).
+				SetUpdate(bson.M{"$set": set, "$inc": bson.M{"version": 1}}).
+				SetUpsert(true))
+			statuses[i] = http.StatusCreated
+			if _, exists := versions[operation.Product.ID]; exists {
+				statuses[i] = http.StatusOK
+				matched[i] = true
+			}
+			versions[operation.Product.ID]++
+
+		case BulkPatch:
+			id := operation.Product.ID
+			current, exists := versions[id]
+			if !exists {
+				failed = fmt.Errorf("продукт с ID %d не найден", id)
+				break
+			}
+			if operation.Version != 0 && current != operation.Version {
+				failed = &VersionConflictError{ID: id, Expected: operation.Version, Current: current}
+				failedStatus = http.StatusPreconditionFailed
+				break
+			}
+			set := bson.M{}
+			for _, field := range operation.Fields {
+				set[mongoFields[field]] = productValue(*operation.Product, field)
+			}
+			// Фильтр по версии не дает записать изменение, если товар изменили после проверки
+			writes = append(writes, mongo.NewUpdateOneModel().
+				SetFilter(versionFilter(id, operation.Version)).
+				SetUpdate(bson.M{"$set": set, "$inc": bson.M{"version": 1}}))
+			statuses[i] = http.StatusOK
+			matched[i] = true
+			versions[id]++
+
+		case BulkDelete:
+			current, exists := versions[operation.ID]
+			if !exists {
+				failed = fmt.Errorf("продукт с ID %d не найден", operation.ID)
+				break
+			}
+			if operation.Version != 0 && current != operation.Version {
+				failed = &VersionConflictError{ID: operation.ID, Expected: operation.Version, Current: current}
+				failedStatus = http.StatusPreconditionFailed
+				break
+			}
+			delete(versions, operation.ID)
+			statuses[i] = http.StatusOK
+			writes = append(writes, mongo.NewDeleteOneModel().SetFilter(versionFilter(operation.ID, operation.Version)))
+		}
+
+		if failed != nil {
+			result.fail(failedStatus, failed)
+			if atomic {
+				abortBulk(operations, results)
+				return results, nil
+			}
+			continue
+		}
+		indexes = append(indexes, i)
+	}
+
+	// expected количество документов, которые должны найти выполненные операции upsert и patch
+	expected := func(indexes []int) int64 {
+		var count int64
+		for _, i := range indexes {
+			if matched[i] {
+				count++
+			}
+		}
+		return count
+	}
+	// deletes количество операций delete, versioned - есть ли среди них операции с версией
+	deletes := func(indexes []int) (count int64, versioned bool) {
+		for _, i := range indexes {
+			if operations[i].Op == BulkDelete {
+				count++
+				versioned = versioned || operations[i].Version != 0
+			}
+		}
+		return count, versioned
+	}
+
+	opts := options.BulkWrite().SetOrdered(true)
+	if atomic {
+		deleted, _ := deletes(indexes)
+		err := m.bulkTransaction(ctx, writes, opts, expected(indexes), deleted)
+		var bulkErr mongo.BulkWriteException
+		switch {
+		case errors.As(err, &bulkErr) && len(bulkErr.WriteErrors) > 0:
+			failMongoWrite(&results[indexes[bulkErr.WriteErrors[0].Index]], bulkErr.WriteErrors[0].WriteError)
+			abortBulk(operations, results)
+			return results, nil
+		case errors.Is(err, errBulkConflict):
+			// Операция patch или delete не нашла товар с ожидаемой версией: его изменили или удалили после проверки
+			found, err := m.failConflicts(ctx, operations, results, indexes, 0)
+			if err != nil {
+				return nil, err
+			}
+			if !found {
+				return nil, errBulkConflict
+			}
+			abortBulk(operations, results)
+			return results, nil
+		case err != nil:
+			return nil, err
+		}
+		for _, i := range indexes {
+			succeedMongoWrite(&results[i], statuses[i])
+		}
+	} else {
+		var found int64
+		for start := 0; start < len(writes); {
+			res, err := m.Collection.BulkWrite(ctx, writes[start:], opts)
+			if res != nil {
+				found += res.MatchedCount
+			}
+
+			// failed - номер первой невыполненной операции в writes
+			failed := len(writes)
+			var bulkErr mongo.BulkWriteException
+			switch {
+			case errors.As(err, &bulkErr) && len(bulkErr.WriteErrors) > 0:
+				failed = start + bulkErr.WriteErrors[0].Index
+				failMongoWrite(&results[indexes[failed]], bulkErr.WriteErrors[0].WriteError)
+			case err != nil:
+				// Ошибка не относится к конкретной операции: оставшиеся операции не выполнены
+				for _, i := range indexes[start:] {
+					results[i].fail(http.StatusInternalServerError, err)
+				}
+			}
+			for _, i := range indexes[start:failed] {
+				succeedMongoWrite(&results[i], statuses[i])
+			}
+			if err != nil && failed == len(writes) {
+				break
+			}
+			start = failed + 1
+		}
+
+		var succeeded []int
+		for _, i := range indexes {
+			if results[i].Succeeded() {
+				succeeded = append(succeeded, i)
+			}
+		}
+		// Операция patch не нашла товар с ожидаемой версией или операция delete с версией его не удалила.
+		// Выполненная операция patch увеличила бы версию на 1, поэтому ищутся операции, после которых
+		// версия товара другая, и операции delete, после которых товар остался
+		_, versionedDeletes := deletes(succeeded)
+		if found < expected(succeeded) || versionedDeletes {
+			if _, err := m.failConflicts(ctx, operations, results, succeeded, 1); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := m.finishBulk(ctx, operations, results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// existingVersions возвращает версии существующих товаров среди удаляемых и изменяемых операциями пакета
+func (m *MongoDBClient) existingVersions(ctx context.Context, operations []BulkOperation, results []BulkResult) (map[int]int64, error) {
+	var ids []int
+	for i, operation := range operations {
+		if results[i].Status == 0 && operation.Op != BulkCreate {
+			ids = append(ids, operation.targetID())
+		}
+	}
+	return m.productVersions(ctx, ids)
+}
+
+// productVersions возвращает версии товаров ids, которые есть в коллекции
+func (m *MongoDBClient) productVersions(ctx context.Context, ids []int) (map[int]int64, error) {
+	versions := make(map[int]int64)
+	if len(ids) == 0 {
+		return versions, nil
+	}
+
+	cursor, err := m.Collection.Find(ctx, bson.M{"id": bson.M{"$in": ids}}, options.Find().SetProjection(bson.M{"id": 1, "version": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var product models.Product
+		if err := cursor.Decode(&product); err != nil {
+			return nil, err
+		}
+		versions[product.ID] = product.Version
+	}
+	return versions, cursor.Err()
+}
+
+// failConflicts отмечает операции из indexes, которые не нашли товар в ожидаемом состоянии,
+// и сообщает, нашлась ли такая операция. shift 0 - пакет отменен и товары остались прежними,
+// shift 1 - операции выполнены. Статус 412 получают операции patch, версия товара которых не равна
+// ожидаемой версии + shift, и операции delete с версией, если товар другой версии остался.
+// После отмены пакета операция delete без версии, товара которой уже нет, получает статус 404
+func (m *MongoDBClient) failConflicts(ctx context.Context, operations []BulkOperation, results []BulkResult, indexes []int, shift int64) (bool, error) {
+	var ids []int
+	for _, i := range indexes {
+		if operations[i].Version != 0 || operations[i].Op == BulkDelete {
+			ids = append(ids, operations[i].targetID())
+		}
+	}
+	versions, err := m.productVersions(ctx, ids)
+	if err != nil {
+		return false, err
+	}
+
+	found := false
+	for _, i := range indexes {
+		operation := operations[i]
+		id := operation.targetID()
+		current, exists := versions[id]
+
+		var conflict error
+		status := http.StatusPreconditionFailed
+		switch {
+		case operation.Op == BulkPatch && operation.Version != 0 && current != operation.Version+shift:
+			conflict = &VersionConflictError{ID: id, Expected: operation.Version, Current: current}
+		case operation.Op == BulkDelete && operation.Version != 0 && exists && (shift == 1 || current != operation.Version):
+			conflict = &VersionConflictError{ID: id, Expected: operation.Version, Current: current}
+		case operation.Op == BulkDelete && shift == 0 && !exists:
+			conflict = fmt.Errorf("продукт с ID %d не найден", id)
+			status = http.StatusNotFound
+		default:
+			continue
+		}
+		results[i] = BulkResult{Index: i, Op: operation.Op, ID: id}
+		results[i].fail(status, conflict)
+		found = true
+	}
+	return found, nil
+}
+
+// errBulkConflict операция patch или delete в транзакции не нашла товар с ожидаемой версией
+var errBulkConflict = errors.New("версия товара изменилась во время выполнения пакета")
+
+// bulkTransaction выполняет BulkWrite в транзакции. Если операции upsert и patch нашли меньше
+// существующих документов, чем matched, или операции delete удалили меньше, чем deleted,
+// транзакция отменяется с ошибкой errBulkConflict
+func (m *MongoDBClient) bulkTransaction(ctx context.Context, writes []mongo.WriteModel, opts *options.BulkWriteOptions, matched, deleted int64) error {
+	if len(writes) == 0 {
+		return nil
+	}
+
+	session, err := m.Client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (any, error) {
+		res, err := m.Collection.BulkWrite(sessCtx, writes, opts)
+		if err == nil && (res.MatchedCount < matched || res.DeletedCount < deleted) {
+			return nil, errBulkConflict
+		}
+		return res, err
+	})
+
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) && serverErr.HasErrorCode(mongoIllegalOperation) {
+		return fmt.Errorf("режим \"все или ничего\" в MongoDB требует набора реплик: %w", err)
+	}
+	return err
+}
+
+// succeedMongoWrite записывает результат выполненной операции. Созданный товар имеет версию 1,
+// версию товара, измененного через upsert или patch, заполняет finishBulk
+func succeedMongoWrite(result *BulkResult, status int) {
+	result.Status = status
+	if status == http.StatusCreated {
+		result.Version = 1
+	}
+}
+
+// failMongoWrite записывает результат операции, отклоненной MongoDB
+func failMongoWrite(result *BulkResult, writeErr mongo.WriteError) {
+	if mongo.IsDuplicateKeyError(writeErr) {
+		result.fail(http.StatusConflict, &DuplicateError{ID: result.ID})
+		return
+	}
+	result.fail(bulkStatus(writeErr), writeErr)
+}
+
+// finishBulk поднимает счетчик ID до явно указанных ID созданных товаров
+// и читает новые версии товаров, измененных через upsert и patch
+func (m *MongoDBClient) finishBulk(ctx context.Context, operations []BulkOperation, results []BulkResult) error {
+	updated := make(map[int]*BulkResult)
+	var ids []int
+	for i, operation := range operations {
+		result := &results[i]
+		switch {
+		case result.Status == http.StatusCreated && operation.Product.ID != 0:
+			// Ошибка не критична: при совпадении назначенного ID вставка повторяется
+			m.raiseCounter(ctx, operation.Product.ID)
+		case result.Status == http.StatusOK && (operation.Op == BulkUpsert || operation.Op == BulkPatch):
+			updated[result.ID] = result
+			ids = append(ids, result.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	cursor, err := m.Collection.Find(ctx, bson.M{"id": bson.M{"$in": ids}}, options.Find().SetProjection(bson.M{"id": 1, "version": 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var product models.Product
+		if err := cursor.Decode(&product); err != nil {
+			return err
+		}
+		updated[product.ID].Version = product.Version
+	}
+	return cursor.Err()
+}
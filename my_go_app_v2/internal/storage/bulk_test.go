@@ -0,0 +1,241 @@
+package storage
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"project/internal/models"
+)
+
+// newBulkTestClient хранилище в памяти с товарами 1-3 и подписчиком на изменения
+func newBulkTestClient(t *testing.T) (*MemoryClient, chan ProductChange) {
+	t.Helper()
+	m := NewMemoryClient()
+	for _, name := range []string{"Чайник", "Термос", "Кружка"} {
+		if _, err := m.AddProduct(models.Product{Name: name, Category: "Кухня", Price: 100, Supplier: "Альфа"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	changes := make(chan ProductChange, 16)
+	m.watchers.channels = map[chan ProductChange]struct{}{changes: {}}
+	return m, changes
+}
+
+// newSQLiteBulkTestStore база SQLite в памяти с товарами 1-3
+func newSQLiteBulkTestStore(t *testing.T) *SQLiteClient {
+	t.Helper()
+	store := newSQLiteTestStore(t)
+	for _, name := range []string{"Чайник", "Термос", "Кружка"} {
+		if _, err := store.AddProduct(models.Product{Name: name, Category: "Кухня", Price: 100, Supplier: "Альфа"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+// bulkRollbackTest пакет, который в атомарном режиме отменяется целиком, и ожидаемые коды результатов
+type bulkRollbackTest struct {
+	name       string
+	operations []BulkOperation
+	want       []int
+}
+
+// bulkRollbackTests пакеты для хранилищ с товарами 1-3
+func bulkRollbackTests() []bulkRollbackTest {
+	product := func(id int, name string) *models.Product {
+		return &models.Product{ID: id, Name: name, Category: "Кухня", Price: 250, Supplier: "Бета"}
+	}
+
+	return []bulkRollbackTest{
+		{
+			name: "ошибка последней операции",
+			operations: []BulkOperation{
+				{Op: BulkCreate, Product: product(0, "Сковорода")},
+				{Op: BulkCreate, Product: product(10, "Кастрюля")},
+				{Op: BulkUpsert, Product: product(1, "Чайник электрический")},
+				{Op: BulkUpsert, Product: product(20, "Ковш")},
+				{Op: BulkPatch, Product: product(2, "Термос 1 л"), Fields: []string{"name"}},
+				{Op: BulkDelete, ID: 3},
+				{Op: BulkDelete, ID: 99},
+			},
+			want: []int{424, 424, 424, 424, 424, 424, 404},
+		},
+		{
+			name: "ID занят",
+			operations: []BulkOperation{
+				{Op: BulkUpsert, Product: product(1, "Чайник электрический")},
+				{Op: BulkCreate, Product: product(2, "Термос")},
+			},
+			want: []int{424, 409},
+		},
+		{
+			name: "повторное изменение одного товара",
+			operations: []BulkOperation{
+				{Op: BulkUpsert, Product: product(1, "Чайник электрический")},
+				{Op: BulkPatch, Product: product(1, "Чайник"), Fields: []string{"price"}},
+				{Op: BulkDelete, ID: 1},
+				{Op: BulkCreate, Product: product(1, "Чайник заварочный")},
+				{Op: BulkCreate, Product: product(0, "Сковорода")},
+			},
+			want: []int{424, 409, 409, 409, 424},
+		},
+		{
+			name: "версия изменяемого товара не совпала",
+			operations: []BulkOperation{
+				{Op: BulkDelete, ID: 3},
+				{Op: BulkPatch, Product: product(2, "Термос 1 л"), Fields: []string{"name"}, Version: 5},
+				{Op: BulkCreate, Product: product(0, "Сковорода")},
+			},
+			want: []int{424, 412, 424},
+		},
+		{
+			name: "версия удаляемого товара не совпала",
+			operations: []BulkOperation{
+				{Op: BulkPatch, Product: product(2, "Термос 1 л"), Fields: []string{"name"}, Version: 1},
+				{Op: BulkDelete, ID: 3, Version: 2},
+			},
+			want: []int{424, 412},
+		},
+		{
+			name: "неверная операция",
+			operations: []BulkOperation{
+				{Op: BulkDelete, ID: 1},
+				{Op: BulkPatch, Product: product(2, "Термос 1 л"), Fields: []string{"color"}},
+				{Op: "merge", Product: product(3, "Кружка")},
+			},
+			want: []int{424, 400, 400},
+		},
+	}
+}
+
+// bulkStatuses коды результатов пакета
+func bulkStatuses(results []BulkResult) []int {
+	statuses := make([]int, len(results))
+	for i, result := range results {
+		statuses[i] = result.Status
+	}
+	return statuses
+}
+
+func TestMemoryBulkWriteAtomicRollback(t *testing.T) {
+	for _, tt := range bulkRollbackTests() {
+		t.Run(tt.name, func(t *testing.T) {
+			m, changes := newBulkTestClient(t)
+			before, _ := m.GetAllProducts()
+
+			results, err := m.BulkWrite(tt.operations, true)
+			if err != nil {
+				t.Fatalf("неожиданная ошибка: %v", err)
+			}
+			if statuses := bulkStatuses(results); !reflect.DeepEqual(statuses, tt.want) {
+				t.Fatalf("коды результатов %v, ожидалось %v", statuses, tt.want)
+			}
+
+			after, _ := m.GetAllProducts()
+			if !reflect.DeepEqual(after, before) {
+				t.Fatalf("пакет не откатился: товары %+v, ожидалось %+v", after, before)
+			}
+			if len(changes) != 0 {
+				t.Fatalf("подписчики получили изменения отмененного пакета: %d", len(changes))
+			}
+			// ID, выданные отмененным пакетом, освобождаются
+			id, err := m.AddProduct(models.Product{Name: "Нож", Category: "Кухня", Supplier: "Альфа"})
+			if err != nil || id != 4 {
+				t.Fatalf("новый товар получил ID %d (%v), ожидался 4", id, err)
+			}
+		})
+	}
+}
+
+// bulkPartialOperations пакет для хранилищ с товарами 1-3, часть операций которого не выполняется,
+// и ожидаемые коды результатов в неатомарном режиме
+var (
+	bulkPartialOperations = []BulkOperation{
+		{Op: BulkPatch, Product: &models.Product{ID: 1, Price: 150}, Fields: []string{"price"}},
+		{Op: BulkDelete, ID: 99},
+		{Op: BulkDelete, ID: 2, Version: 3},
+		{Op: BulkDelete, ID: 3, Version: 1},
+		{Op: BulkDelete, ID: 1},
+	}
+	bulkPartialStatuses = []int{http.StatusOK, http.StatusNotFound, http.StatusPreconditionFailed, http.StatusOK, http.StatusConflict}
+)
+
+func TestMemoryBulkWritePartial(t *testing.T) {
+	m, changes := newBulkTestClient(t)
+
+	results, err := m.BulkWrite(bulkPartialOperations, false)
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if statuses := bulkStatuses(results); !reflect.DeepEqual(statuses, bulkPartialStatuses) {
+		t.Fatalf("коды результатов %v, ожидалось %v", statuses, bulkPartialStatuses)
+	}
+
+	if product, _, _ := m.GetProduct(1); product.Price != 150 || product.Version != 2 {
+		t.Fatalf("товар 1 не изменен: %+v", product)
+	}
+	if _, exists, _ := m.GetProduct(3); exists {
+		t.Fatal("товар 3 не удален")
+	}
+	if len(changes) != 2 {
+		t.Fatalf("подписчики получили %d изменений, ожидалось 2", len(changes))
+	}
+}
+
+func TestSQLiteBulkWriteAtomicRollback(t *testing.T) {
+	for _, tt := range bulkRollbackTests() {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newSQLiteBulkTestStore(t)
+			before, _ := store.GetAllProducts()
+
+			results, err := store.BulkWrite(tt.operations, true)
+			if err != nil {
+				t.Fatalf("неожиданная ошибка: %v", err)
+			}
+			if statuses := bulkStatuses(results); !reflect.DeepEqual(statuses, tt.want) {
+				t.Fatalf("коды результатов %v, ожидалось %v", statuses, tt.want)
+			}
+
+			after, _ := store.GetAllProducts()
+			if !reflect.DeepEqual(after, before) {
+				t.Fatalf("пакет не откатился: товары %+v, ожидалось %+v", after, before)
+			}
+			changes, err := store.readChanges(context.Background(), 0, 100)
+			if err != nil {
+				t.Fatalf("чтение журнала изменений: %v", err)
+			}
+			if len(changes) != 3 {
+				t.Fatalf("в журнале %d изменений, ожидалось 3 (добавление товаров 1-3)", len(changes))
+			}
+			// Счетчик AUTOINCREMENT откатывается вместе с пакетом
+			id, err := store.AddProduct(models.Product{Name: "Нож", Category: "Кухня", Supplier: "Альфа"})
+			if err != nil || id != 4 {
+				t.Fatalf("новый товар получил ID %d (%v), ожидался 4", id, err)
+			}
+		})
+	}
+}
+
+func TestSQLiteBulkWritePartial(t *testing.T) {
+	store := newSQLiteBulkTestStore(t)
+
+	results, err := store.BulkWrite(bulkPartialOperations, false)
+	if err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	if statuses := bulkStatuses(results); !reflect.DeepEqual(statuses, bulkPartialStatuses) {
+		t.Fatalf("коды результатов %v, ожидалось %v", statuses, bulkPartialStatuses)
+	}
+
+	if product, _, _ := store.GetProduct(1); product.Price != 150 || product.Version != 2 {
+		t.Fatalf("товар 1 не изменен: %+v", product)
+	}
+	if product, exists, _ := store.GetProduct(2); !exists || product.Version != 1 {
+		t.Fatalf("товар 2 изменен: %+v", product)
+	}
+	if _, exists, _ := store.GetProduct(3); exists {
+		t.Fatal("товар 3 не удален")
+	}
+}
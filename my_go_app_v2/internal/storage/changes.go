@@ -0,0 +1,641 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"project/internal/models"
+
+	"github.com/go-sql-driver/mysql"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Операции изменения товара
+const (
+	ChangeCreated = "created"
+	ChangeUpdated = "updated"
+	ChangeDeleted = "deleted"
+)
+
+// ProductChange изменение товара, обнаруженное в базе данных (в том числе выполненное в обход API)
+type ProductChange struct {
+	Operation string
+	ProductID int
+	// Product состояние товара после изменения, для удаления - перед удалением.
+	// nil, если удаленный товар неизвестен (например, запись журнала сделана до миграции change_log_rows)
+	Product *models.Product
+	Time    time.Time
+}
+
+// ChangeWatcher хранилище, умеющее отслеживать изменения товаров.
+// WatchChanges продолжает отслеживание с позиции position и сдвигает ее, вызывает started, когда позиция
+// начала отслеживания известна, и emit для каждого изменения. Возвращает управление при отмене ctx или ошибке
+type ChangeWatcher interface {
+	WatchChanges(ctx context.Context, position *WatchPosition, started func(), emit func(ProductChange)) error
+}
+
+// WatchPosition позиция отслеживания изменений одной базы. WatchChanges, перезапущенный после ошибки
+// с той же позицией, передает и изменения, сделанные за время перезапуска. С нулевой позицией
+// отслеживание начинается с текущего момента
+type WatchPosition struct {
+	// cursor позиция в журнале изменений SQL-базы
+	cursor *changeCursor
+	// resumeToken маркер возобновления потока изменений MongoDB
+	resumeToken bson.Raw
+}
+
+// Проверка на этапе компиляции, что клиенты реализуют ChangeWatcher
+var (
+	_ ChangeWatcher = (*MongoDBClient)(nil)
+	_ ChangeWatcher = (*PostgresClient)(nil)
+	_ ChangeWatcher = (*MySQLClient)(nil)
+	_ ChangeWatcher = (*SQLiteClient)(nil)
+	_ ChangeWatcher = (*MemoryClient)(nil)
+)
+
+// ErrWatchNotSupported хранилище не умеет отслеживать изменения
+var ErrWatchNotSupported = errors.New("хранилище не поддерживает отслеживание изменений")
+
+// WatchChanges отслеживает изменения товаров в базе данных name, пока не будет отменен ctx
+// или не произойдет ошибка (см. ChangeWatcher). Для недоступной базы возвращается *UnavailableError
+func (m *DBManager) WatchChanges(ctx context.Context, name string, position *WatchPosition, started func(), emit func(ProductChange)) error {
+	b, ok := m.backends[name]
+	if !ok {
+		return ErrUnknownDatabase
+	}
+	store, err := b.get(false)
+	if err != nil {
+		return err
+	}
+	watcher, ok := store.(ChangeWatcher)
+	if !ok {
+		return ErrWatchNotSupported
+	}
+	return watcher.WatchChanges(ctx, position, started, emit)
+}
+
+// ----- Изменения в памяти -----
+
+// memoryWatchers подписчики на изменения хранилища в памяти
+type memoryWatchers struct {
+	mu       sync.Mutex
+	channels map[chan ProductChange]struct{}
+}
+
+// notify передает изменение подписчикам; подписчик, не успевающий читать, пропускает изменение
+func (w *memoryWatchers) notify(change ProductChange) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for ch := range w.channels {
+		select {
+		case ch <- change:
+		default:
+		}
+	}
+}
+
+// WatchChanges передает изменения хранилища в памяти сразу после их выполнения.
+// Отслеживание прерывается только отменой ctx, поэтому позиция не нужна
+func (m *MemoryClient) WatchChanges(ctx context.Context, position *WatchPosition, started func(), emit func(ProductChange)) error {
+	ch := make(chan ProductChange, 256)
+
+	m.watchers.mu.Lock()
+	if m.watchers.channels == nil {
+		m.watchers.channels = make(map[chan ProductChange]struct{})
+	}
+	m.watchers.channels[ch] = struct{}{}
+	m.watchers.mu.Unlock()
+
+	defer func() {
+		m.watchers.mu.Lock()
+		delete(m.watchers.channels, ch)
+		m.watchers.mu.Unlock()
+	}()
+	started()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case change := <-ch:
+			emit(change)
+		}
+	}
+}
+
+// ----- Изменения в SQL-базах -----
+
+// Журнал изменений SQL-баз: триггеры на таблице products записывают каждое изменение
+// в таблицу product_changes, а WatchChanges периодически читает новые записи
+const (
+	changePollInterval = time.Second
+	changeBatchSize    = 500
+	// changeGapTimeout сколько ждать записи с пропущенным номером: номер мог получить еще не
+	// зафиксированная транзакция. Номера отмененных транзакций не появляются никогда
+	changeGapTimeout = time.Minute
+	// changeLogRetention сколько хранятся записи журнала. Журнал читают все экземпляры сервера,
+	// поэтому записи удаляются по возрасту, а не по позиции одного из читателей
+	changeLogRetention = time.Hour
+	// changePruneInterval как часто удаляются устаревшие записи
+	changePruneInterval = time.Minute
+)
+
+// createChangeLog создает таблицу журнала изменений и триггеры, соответствующие диалекту
+func (s *sqlStore) createChangeLog(q sqlQuerier) error {
+	var statements []string
+	switch s.dialect {
+	case postgresDialect:
+		statements = []string{
+			`CREATE TABLE IF NOT EXISTS product_changes (
+				seq BIGSERIAL PRIMARY KEY,
+				product_id INT NOT NULL,
+				operation VARCHAR(10) NOT NULL,
+				changed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+			)`,
+			postgresLogChangeFunction(false),
+			`DROP TRIGGER IF EXISTS products_changes_trigger ON products`,
+			`CREATE TRIGGER products_changes_trigger AFTER INSERT OR UPDATE OR DELETE ON products
+				FOR EACH ROW EXECUTE FUNCTION products_log_change()`,
+		}
+
+	case mysqlDialect:
+		statements = []string{
+			`CREATE TABLE IF NOT EXISTS product_changes (
+				seq BIGINT AUTO_INCREMENT PRIMARY KEY,
+				product_id INT NOT NULL,
+				operation VARCHAR(10) NOT NULL,
+				changed_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6)
+			)`,
+		}
+		triggers := map[string]string{
+			"products_changes_insert": `CREATE TRIGGER products_changes_insert AFTER INSERT ON products FOR EACH ROW
+				INSERT INTO product_changes (product_id, operation) VALUES (NEW.id, 'created')`,
+			"products_changes_update": `CREATE TRIGGER products_changes_update AFTER UPDATE ON products FOR EACH ROW
+				INSERT INTO product_changes (product_id, operation) VALUES (NEW.id, 'updated')`,
+			"products_changes_delete": mysqlDeleteTrigger(false),
+		}
+		if _, err := q.Exec(statements[0]); err != nil {
+			return err
+		}
+		// CREATE TRIGGER IF NOT EXISTS есть не во всех версиях MySQL, поэтому наличие триггеров проверяется отдельно
+		for name, statement := range triggers {
+			var count int
+			err := q.QueryRow(`SELECT COUNT(*) FROM information_schema.triggers
+				WHERE trigger_schema = DATABASE() AND trigger_name = ?`, name).Scan(&count)
+			if err != nil {
+				return err
+			}
+			if count == 0 {
+				if _, err := q.Exec(statement); err != nil {
+					return mysqlTriggerError(name, err)
+				}
+			}
+		}
+		return nil
+
+	case sqliteDialect:
+		statements = []string{
+			`CREATE TABLE IF NOT EXISTS product_changes (
+				seq INTEGER PRIMARY KEY AUTOINCREMENT,
+				product_id INT NOT NULL,
+				operation TEXT NOT NULL,
+				changed_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+			)`,
+			`CREATE TRIGGER IF NOT EXISTS products_changes_insert AFTER INSERT ON products BEGIN
+				INSERT INTO product_changes (product_id, operation) VALUES (new.id, 'created');
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS products_changes_update AFTER UPDATE ON products BEGIN
+				INSERT INTO product_changes (product_id, operation) VALUES (new.id, 'updated');
+			END`,
+			sqliteDeleteTrigger(false),
+		}
+	}
+
+	for _, statement := range statements {
+		if _, err := q.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mysqlTriggerError поясняет ошибку создания триггера MySQL, вызванную нехваткой прав
+func mysqlTriggerError(name string, err error) error {
+	var mysqlErr *mysql.MySQLError
+	// 1419: при включенном двоичном журнале триггеры может создавать только пользователь с SUPER
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == 1419 {
+		return fmt.Errorf("нет прав на создание триггера %s: включите на сервере MySQL "+
+			"log_bin_trust_function_creators=1 или выдайте пользователю привилегию SUPER: %w", name, err)
+	}
+	return err
+}
+
+// changeLogRowColumns столбцы журнала изменений, в которые триггер удаления записывает строку удаленного товара
+// (миграция change_log_rows). У записей создания и обновления они пустые: текущее состояние товара
+// читается из таблицы products
+var changeLogRowColumns = []struct{ name, definition string }{
+	{"name", "VARCHAR(100)"},
+	{"category", "VARCHAR(50)"},
+	{"price", "DECIMAL(10, 2)"},
+	{"description", "TEXT"},
+	{"in_stock", "BOOLEAN"},
+	{"supplier", "VARCHAR(100)"},
+	{"version", "BIGINT"},
+}
+
+// logDeletedChange оператор триггера, записывающий удаление товара в журнал; withRow - вместе со строкой товара
+func logDeletedChange(withRow bool) string {
+	if !withRow {
+		return `INSERT INTO product_changes (product_id, operation) VALUES (OLD.id, 'deleted')`
+	}
+	return `INSERT INTO product_changes (product_id, operation, name, category, price, description, in_stock, supplier, version)
+		VALUES (OLD.id, 'deleted', OLD.name, OLD.category, OLD.price, OLD.description, OLD.in_stock, OLD.supplier, OLD.version)`
+}
+
+// postgresLogChangeFunction функция триггера журнала изменений PostgreSQL. Изменение ID записывается
+// как удаление товара со старым ID и создание товара с новым
+func postgresLogChangeFunction(withRow bool) string {
+	return `CREATE OR REPLACE FUNCTION products_log_change() RETURNS trigger AS $$
+		BEGIN
+			IF TG_OP = 'DELETE' THEN
+				` + logDeletedChange(withRow) + `;
+				RETURN OLD;
+			END IF;
+			IF TG_OP = 'UPDATE' AND OLD.id <> NEW.id THEN
+				` + logDeletedChange(withRow) + `;
+				INSERT INTO product_changes (product_id, operation) VALUES (NEW.id, 'created');
+				RETURN NEW;
+			END IF;
+			INSERT INTO product_changes (product_id, operation)
+				VALUES (NEW.id, CASE TG_OP WHEN 'INSERT' THEN 'created' ELSE 'updated' END);
+			RETURN NEW;
+		END
+		$$ LANGUAGE plpgsql`
+}
+
+// mysqlDeleteTrigger триггер удаления товара MySQL
+func mysqlDeleteTrigger(withRow bool) string {
+	return `CREATE TRIGGER products_changes_delete AFTER DELETE ON products FOR EACH ROW ` + logDeletedChange(withRow)
+}
+
+// sqliteDeleteTrigger триггер удаления товара SQLite
+func sqliteDeleteTrigger(withRow bool) string {
+	return `CREATE TRIGGER IF NOT EXISTS products_changes_delete AFTER DELETE ON products BEGIN
+		` + logDeletedChange(withRow) + `;
+	END`
+}
+
+// addChangeLogRows добавляет в журнал изменений столбцы строки товара и пересоздает триггер удаления,
+// чтобы он записывал удаленный товар: событие удаления передает товар целиком, как и другие события
+func (s *sqlStore) addChangeLogRows(q sqlQuerier) error {
+	for _, column := range changeLogRowColumns {
+		exists, err := s.hasColumn(q, "product_changes", column.name)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			if _, err := q.Exec(`ALTER TABLE product_changes ADD COLUMN ` + column.name + ` ` + column.definition); err != nil {
+				return err
+			}
+		}
+	}
+	return s.replaceDeleteTrigger(q, true)
+}
+
+// replaceDeleteTrigger пересоздает триггер удаления товара
+func (s *sqlStore) replaceDeleteTrigger(q sqlQuerier, withRow bool) error {
+	var statements []string
+	switch s.dialect {
+	case postgresDialect:
+		statements = []string{postgresLogChangeFunction(withRow)}
+	case mysqlDialect:
+		statements = []string{`DROP TRIGGER IF EXISTS products_changes_delete`, mysqlDeleteTrigger(withRow)}
+	case sqliteDialect:
+		statements = []string{`DROP TRIGGER IF EXISTS products_changes_delete`, sqliteDeleteTrigger(withRow)}
+	}
+	for _, statement := range statements {
+		if _, err := q.Exec(statement); err != nil {
+			return mysqlTriggerError("products_changes_delete", err)
+		}
+	}
+	return nil
+}
+
+// changeRow запись журнала изменений
+type changeRow struct {
+	seq       int64
+	productID int
+	operation string
+	// product строка удаленного товара (nil для создания и обновления или если строка не записана)
+	product *models.Product
+}
+
+// changeCursor позиция чтения журнала изменений. Номера записей выдаются при вставке, а транзакции
+// фиксируются в другом порядке, поэтому запись с меньшим номером может появиться позже записей
+// с большими. Такие пропуски запоминаются и перечитываются, пока не истечет changeGapTimeout
+type changeCursor struct {
+	// low все записи с номером не больше low прочитаны или их ожидание прекращено
+	low int64
+	// high наибольший прочитанный номер
+	high int64
+	// seen прочитанные записи с номером больше low
+	seen map[int64]bool
+	// gaps пропуски между low и high по возрастанию номеров
+	gaps []changeGap
+}
+
+// changeGap пропущенные номера from..to и время, когда пропуск обнаружен. Пропуск хранится диапазоном:
+// номера могут не выдаваться долго (например, кэш последовательности PostgreSQL), и перебирать их нельзя
+type changeGap struct {
+	from, to int64
+	found    time.Time
+}
+
+func newChangeCursor(low int64) *changeCursor {
+	return &changeCursor{low: low, high: low, seen: make(map[int64]bool)}
+}
+
+// pending отбирает из прочитанных записей еще не переданные
+func (c *changeCursor) pending(changes []changeRow) []changeRow {
+	var result []changeRow
+	for _, change := range changes {
+		if change.seq > c.low && !c.seen[change.seq] {
+			result = append(result, change)
+		}
+	}
+	return result
+}
+
+// read отмечает запись переданной. Номер после наибольшего прочитанного открывает пропуск перед собой,
+// номер внутри пропуска делит его на части
+func (c *changeCursor) read(seq int64, now time.Time) {
+	if seq <= c.low || c.seen[seq] {
+		return
+	}
+	c.seen[seq] = true
+
+	if seq > c.high {
+		if seq > c.high+1 {
+			c.gaps = append(c.gaps, changeGap{from: c.high + 1, to: seq - 1, found: now})
+		}
+		c.high = seq
+		return
+	}
+	for i, gap := range c.gaps {
+		if seq < gap.from || seq > gap.to {
+			continue
+		}
+		var parts []changeGap
+		if seq > gap.from {
+			parts = append(parts, changeGap{from: gap.from, to: seq - 1, found: gap.found})
+		}
+		if seq < gap.to {
+			parts = append(parts, changeGap{from: seq + 1, to: gap.to, found: gap.found})
+		}
+		c.gaps = slices.Replace(c.gaps, i, i+1, parts...)
+		return
+	}
+}
+
+// advance сдвигает low через прочитанные записи и пропуски, ожидание которых истекло
+func (c *changeCursor) advance(now time.Time) {
+	for {
+		next := c.low + 1
+		switch {
+		case c.seen[next]:
+			delete(c.seen, next)
+			c.low = next
+		case len(c.gaps) > 0 && c.gaps[0].from == next && now.Sub(c.gaps[0].found) >= changeGapTimeout:
+			c.low = c.gaps[0].to
+			c.gaps = c.gaps[1:]
+		default:
+			return
+		}
+	}
+}
+
+// WatchChanges периодически читает журнал изменений product_changes.
+// Передаются изменения после позиции position, а без нее - сделанные после начала отслеживания;
+// для созданных и обновленных товаров передается их текущее состояние в базе
+func (s *sqlStore) WatchChanges(ctx context.Context, position *WatchPosition, started func(), emit func(ProductChange)) error {
+	var lastSeq int64
+	if err := s.DB.QueryRowContext(ctx, `SELECT COALESCE(MAX(seq), 0) FROM product_changes`).Scan(&lastSeq); err != nil {
+		return err
+	}
+	// Номер меньше прочитанного означает, что журнал создан заново (например, база в памяти после
+	// переподключения) или очищен полностью; тогда чтение начинается с его конца
+	if position.cursor == nil || lastSeq < position.cursor.high {
+		position.cursor = newChangeCursor(lastSeq)
+	}
+	cursor := position.cursor
+	started()
+
+	ticker := time.NewTicker(changePollInterval)
+	defer ticker.Stop()
+	var pruned time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		// Уже прочитанные записи после low читаются повторно, поэтому лимит увеличивается на их количество
+		changes, err := s.readChanges(ctx, cursor.low, changeBatchSize+len(cursor.seen))
+		if err != nil {
+			return err
+		}
+		// Запись отмечается переданной только после передачи: после ошибки чтения товара
+		// перезапуск продолжит с нее
+		for _, change := range cursor.pending(changes) {
+			event := ProductChange{Operation: change.operation, ProductID: change.productID, Product: change.product, Time: time.Now().UTC()}
+			if change.operation != ChangeDeleted {
+				product, exists, err := s.GetProduct(change.productID)
+				if err != nil {
+					return err
+				}
+				// Товар мог быть удален после изменения; об удалении сообщит следующая запись журнала
+				if !exists {
+					cursor.read(change.seq, time.Now())
+					continue
+				}
+				event.Product = &product
+			}
+			emit(event)
+			cursor.read(change.seq, time.Now())
+		}
+		cursor.advance(time.Now())
+
+		// Старые записи журнала удаляются, чтобы таблица не росла бесконечно
+		if time.Since(pruned) >= changePruneInterval {
+			s.DB.ExecContext(ctx, s.pruneChangesQuery())
+			pruned = time.Now()
+		}
+	}
+}
+
+// pruneChangesQuery запрос удаления записей журнала старше changeLogRetention.
+// Время сравнивается средствами базы, в которой оно записано триггерами
+func (s *sqlStore) pruneChangesQuery() string {
+	hours := int(changeLogRetention / time.Hour)
+	switch s.dialect {
+	case postgresDialect:
+		return fmt.Sprintf(`DELETE FROM product_changes WHERE changed_at < now() - interval '%d hours'`, hours)
+	case mysqlDialect:
+		return fmt.Sprintf(`DELETE FROM product_changes WHERE changed_at < CURRENT_TIMESTAMP(6) - INTERVAL %d HOUR`, hours)
+	}
+	return fmt.Sprintf(`DELETE FROM product_changes WHERE changed_at < strftime('%%Y-%%m-%%dT%%H:%%M:%%fZ', 'now', '-%d hours')`, hours)
+}
+
+// readChanges читает до limit записей журнала изменений после seq
+func (s *sqlStore) readChanges(ctx context.Context, seq int64, limit int) ([]changeRow, error) {
+	rows, err := s.DB.QueryContext(ctx, s.dialect.rebind(`SELECT seq, product_id, operation,
+		name, category, price, description, in_stock, supplier, version FROM product_changes
+		WHERE seq > ? ORDER BY seq LIMIT ?`), seq, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []changeRow
+	for rows.Next() {
+		var change changeRow
+		var (
+			name, category, description, supplier sql.NullString
+			price                                 sql.NullFloat64
+			inStock                               sql.NullBool
+			version                               sql.NullInt64
+		)
+		err := rows.Scan(&change.seq, &change.productID, &change.operation,
+			&name, &category, &price, &description, &inStock, &supplier, &version)
+		if err != nil {
+			return nil, err
+		}
+		if version.Valid {
+			change.product = &models.Product{
+				ID:          change.productID,
+				Name:        name.String,
+				Category:    category.String,
+				Price:       price.Float64,
+				Description: description.String,
+				InStock:     inStock.Bool,
+				Supplier:    supplier.String,
+				Version:     version.Int64,
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+// ----- Изменения в MongoDB -----
+
+// WatchChanges читает поток изменений (change stream) коллекции товаров.
+// Потоки изменений доступны только в наборе реплик или шардированном кластере; на отдельном
+// сервере возвращается ErrWatchNotSupported. В событии удаления есть только _id документа,
+// поэтому удаленный товар берется из прообраза документа (MongoDB 6.0 и новее).
+// Позиция - маркер возобновления последнего прочитанного события
+func (m *MongoDBClient) WatchChanges(ctx context.Context, position *WatchPosition, started func(), emit func(ProductChange)) error {
+	supported, err := m.replicated(ctx)
+	if err != nil {
+		return err
+	}
+	if !supported {
+		return fmt.Errorf("%w: потоки изменений MongoDB доступны только в наборе реплик или шардированном кластере", ErrWatchNotSupported)
+	}
+
+	// Прообразы документов сохраняются, только если они включены для коллекции
+	err = m.Database.RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: m.Collection.Name()},
+		{Key: "changeStreamPreAndPostImages", Value: bson.M{"enabled": true}},
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("не удалось включить прообразы документов для потока изменений (нужен MongoDB 6.0 или новее): %w", err)
+	}
+
+	opts := options.ChangeStream().
+		SetFullDocument(options.UpdateLookup).
+		SetFullDocumentBeforeChange(options.WhenAvailable)
+	if position.resumeToken != nil {
+		opts.SetStartAfter(position.resumeToken)
+	}
+	stream, err := m.Collection.Watch(ctx, mongo.Pipeline{}, opts)
+	if err != nil {
+		var serverErr mongo.ServerError
+		// 286 - ChangeStreamHistoryLost: события после маркера уже вытеснены из журнала операций
+		if position.resumeToken != nil && errors.As(err, &serverErr) && serverErr.HasErrorCode(286) {
+			position.resumeToken = nil
+			return fmt.Errorf("поток изменений нельзя продолжить с прерванного места, изменения за время перерыва пропущены: %w", err)
+		}
+		return err
+	}
+	defer stream.Close(context.Background())
+	started()
+
+	for stream.Next(ctx) {
+		var event struct {
+			OperationType  string          `bson:"operationType"`
+			FullDocument   *models.Product `bson:"fullDocument"`
+			BeforeDocument *models.Product `bson:"fullDocumentBeforeChange"`
+		}
+		if err := stream.Decode(&event); err != nil {
+			return err
+		}
+		position.resumeToken = stream.ResumeToken()
+
+		change := ProductChange{Time: time.Now().UTC()}
+		switch event.OperationType {
+		case "insert", "update", "replace":
+			// При обновлении документ мог быть удален до чтения его полной версии
+			if event.FullDocument == nil {
+				continue
+			}
+			change.Operation = ChangeUpdated
+			if event.OperationType == "insert" {
+				change.Operation = ChangeCreated
+			}
+			change.ProductID = event.FullDocument.ID
+			change.Product = event.FullDocument
+		case "delete":
+			// Прообраза нет у документов, удаленных до включения прообразов или после истечения их срока хранения
+			if event.BeforeDocument == nil {
+				continue
+			}
+			change.Operation = ChangeDeleted
+			change.ProductID = event.BeforeDocument.ID
+			change.Product = event.BeforeDocument
+		case "invalidate":
+			// Коллекция удалена или переименована, поток больше не отслеживает ее
+			return fmt.Errorf("поток изменений коллекции %s закрыт", m.Collection.Name())
+		default:
+			continue
+		}
+		emit(change)
+	}
+
+	if err := stream.Err(); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// replicated сообщает, что сервер входит в набор реплик или является маршрутизатором
+// шардированного кластера (mongos): только там доступны потоки изменений и транзакции
+func (m *MongoDBClient) replicated(ctx context.Context) (bool, error) {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	if err := m.Database.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		return false, err
+	}
+	return hello.SetName != "" || hello.Msg == "isdbgrid", nil
+}
@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"project/internal/models"
+)
+
+func TestChangeCursor(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := func(seqs ...int64) []changeRow {
+		changes := make([]changeRow, len(seqs))
+		for i, seq := range seqs {
+			changes[i] = changeRow{seq: seq}
+		}
+		return changes
+	}
+	seqs := func(changes []changeRow) []int64 {
+		var result []int64
+		for _, change := range changes {
+			result = append(result, change.seq)
+		}
+		return result
+	}
+	read := func(c *changeCursor, changes []changeRow, now time.Time) []int64 {
+		pending := c.pending(changes)
+		for _, change := range pending {
+			c.read(change.seq, now)
+		}
+		c.advance(now)
+		return seqs(pending)
+	}
+
+	t.Run("записи по порядку", func(t *testing.T) {
+		c := newChangeCursor(10)
+		if got := read(c, rows(9, 10, 11, 12), start); !reflect.DeepEqual(got, []int64{11, 12}) {
+			t.Fatalf("переданы %v, ожидалось [11 12]", got)
+		}
+		if c.low != 12 || len(c.seen) != 0 || len(c.gaps) != 0 {
+			t.Fatalf("low %d, seen %v, gaps %v", c.low, c.seen, c.gaps)
+		}
+		if got := read(c, rows(11, 12), start); got != nil {
+			t.Fatalf("повторно переданы %v", got)
+		}
+	})
+
+	t.Run("запись из пропуска передается позже", func(t *testing.T) {
+		c := newChangeCursor(0)
+		read(c, rows(1, 3, 5), start)
+		if c.low != 1 || !reflect.DeepEqual(c.gaps, []changeGap{{2, 2, start}, {4, 4, start}}) {
+			t.Fatalf("low %d, gaps %v", c.low, c.gaps)
+		}
+		// Записи после low читаются повторно, переданные пропускаются
+		if got := read(c, rows(3, 4, 5), start.Add(time.Second)); !reflect.DeepEqual(got, []int64{4}) {
+			t.Fatalf("переданы %v, ожидалось [4]", got)
+		}
+		if c.low != 1 || !reflect.DeepEqual(c.gaps, []changeGap{{2, 2, start}}) {
+			t.Fatalf("low %d, gaps %v", c.low, c.gaps)
+		}
+		if got := read(c, rows(2, 3, 4, 5), start.Add(2*time.Second)); !reflect.DeepEqual(got, []int64{2}) {
+			t.Fatalf("переданы %v, ожидалось [2]", got)
+		}
+		if c.low != 5 || len(c.seen) != 0 || len(c.gaps) != 0 {
+			t.Fatalf("low %d, seen %v, gaps %v", c.low, c.seen, c.gaps)
+		}
+	})
+
+	t.Run("ожидание пропуска истекает", func(t *testing.T) {
+		c := newChangeCursor(0)
+		read(c, rows(2), start)
+		read(c, rows(2), start.Add(changeGapTimeout-time.Second))
+		if c.low != 0 {
+			t.Fatalf("low %d до истечения ожидания", c.low)
+		}
+		read(c, rows(2), start.Add(changeGapTimeout))
+		if c.low != 2 || len(c.gaps) != 0 {
+			t.Fatalf("low %d, gaps %v после истечения ожидания", c.low, c.gaps)
+		}
+		// Запись, появившаяся после истечения ожидания, не передается
+		if got := read(c, rows(1, 2), start.Add(changeGapTimeout)); got != nil {
+			t.Fatalf("переданы %v", got)
+		}
+	})
+
+	t.Run("большой пропуск хранится одним диапазоном", func(t *testing.T) {
+		c := newChangeCursor(1)
+		read(c, rows(1<<40), start)
+		if !reflect.DeepEqual(c.gaps, []changeGap{{2, 1<<40 - 1, start}}) {
+			t.Fatalf("gaps %v", c.gaps)
+		}
+		read(c, rows(1000, 1<<40), start)
+		want := []changeGap{{2, 999, start}, {1001, 1<<40 - 1, start}}
+		if !reflect.DeepEqual(c.gaps, want) {
+			t.Fatalf("gaps %v, ожидалось %v", c.gaps, want)
+		}
+		read(c, nil, start.Add(changeGapTimeout))
+		if c.low != 1<<40 || len(c.gaps) != 0 || len(c.seen) != 0 {
+			t.Fatalf("low %d, seen %v, gaps %v", c.low, c.seen, c.gaps)
+		}
+	})
+}
+
+// watchSQLite запускает отслеживание изменений и ждет, пока позиция начала станет известна.
+// Возвращает канал изменений и функцию остановки, которая ждет завершения отслеживания
+func watchSQLite(t *testing.T, store *SQLiteClient, position *WatchPosition) (<-chan ProductChange, func()) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	changes := make(chan ProductChange, 16)
+	done := make(chan error, 1)
+	go func() {
+		done <- store.WatchChanges(ctx, position, func() { close(started) }, func(change ProductChange) { changes <- change })
+	}()
+
+	select {
+	case <-started:
+	case err := <-done:
+		t.Fatalf("отслеживание не запустилось: %v", err)
+	}
+	return changes, func() {
+		cancel()
+		<-done
+	}
+}
+
+// nextChange ждет следующее изменение
+func nextChange(t *testing.T, changes <-chan ProductChange) ProductChange {
+	t.Helper()
+	select {
+	case change := <-changes:
+		return change
+	case <-time.After(5 * changePollInterval):
+		t.Fatal("изменение не получено")
+	}
+	return ProductChange{}
+}
+
+func TestSQLiteWatchChanges(t *testing.T) {
+	store := newSQLiteTestStore(t)
+	product := models.Product{Name: "Чайник", Category: "Кухня", Price: 1500, Description: "Стеклянный", InStock: true, Supplier: "Альфа"}
+
+	// Изменение до начала отслеживания не передается
+	if _, err := store.AddProduct(product); err != nil {
+		t.Fatal(err)
+	}
+
+	var position WatchPosition
+	changes, stop := watchSQLite(t, store, &position)
+	id, err := store.AddProduct(product)
+	if err != nil {
+		t.Fatal(err)
+	}
+	product.ID, product.Version = id, 1
+	if change := nextChange(t, changes); change.Operation != ChangeCreated || change.Product == nil || *change.Product != product {
+		t.Fatalf("получено %+v, ожидалось создание %+v", change, product)
+	}
+	stop()
+
+	// Изменения, сделанные, пока отслеживание остановлено, передаются после перезапуска с той же позицией
+	if err := store.DeleteProduct(id, 0); err != nil {
+		t.Fatal(err)
+	}
+	changes, stop = watchSQLite(t, store, &position)
+	defer stop()
+
+	change := nextChange(t, changes)
+	if change.Operation != ChangeDeleted || change.ProductID != id {
+		t.Fatalf("получено %+v, ожидалось удаление товара %d", change, id)
+	}
+	// Событие удаления передает товар, каким он был перед удалением
+	if change.Product == nil || *change.Product != product {
+		t.Fatalf("удаленный товар %+v, ожидался %+v", change.Product, product)
+	}
+	select {
+	case change := <-changes:
+		t.Fatalf("лишнее изменение %+v", change)
+	case <-time.After(2 * changePollInterval):
+	}
+}
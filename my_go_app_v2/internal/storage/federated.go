@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"project/internal/models"
+)
+
+// SourcedProduct товар с указанием базы данных, из которой он получен
+type SourcedProduct struct {
+	models.Product
+	Database string `json:"database"`
+}
+
+// SourceError ошибка запроса к одной из баз данных при федеративном запросе
+type SourceError struct {
+	Database string `json:"database"`
+	Error    string `json:"error"`
+}
+
+// FederatedPage страница товаров, собранная из всех баз данных.
+// Total - сумма количества подходящих товаров в базах, ответивших без ошибок
+type FederatedPage struct {
+	Products []SourcedProduct
+	Total    int64
+	Errors   []SourceError
+}
+
+// sourcePage ответ одной базы данных на федеративный запрос
+type sourcePage struct {
+	page ProductPage
+	err  error
+}
+
+// ListAllProducts параллельно выполняет запрос списка товаров во всех зарегистрированных базах
+// и объединяет результаты в одну страницу с общей сортировкой. Строки сравниваются по байтам UTF-8,
+// а не по правилам сортировки баз, поэтому при сортировке по строковому полю базы возвращают
+// все подходящие товары.
+// Базы, которые недоступны или не ответили за timeout, попадают в Errors, а остальные результаты возвращаются
+func (m *DBManager) ListAllProducts(ctx context.Context, query ProductQuery, timeout time.Duration) FederatedPage {
+	// Каждая база возвращает первые offset+limit товаров, чтобы после слияния можно было вырезать нужную страницу
+	sourceQuery := query
+	sourceQuery.Offset = 0
+	if query.Limit > 0 && !query.sortsByString() {
+		sourceQuery.Limit = query.Offset + query.Limit
+	} else {
+		sourceQuery.Limit = 0
+	}
+
+	results := make([]sourcePage, len(m.names))
+
+	var wg sync.WaitGroup
+	for i, name := range m.names {
+		wg.Add(1)
+		go func(result *sourcePage, b *backend) {
+			defer wg.Done()
+			*result = listWithTimeout(ctx, b, sourceQuery, timeout)
+		}(&results[i], m.backends[name])
+	}
+	wg.Wait()
+
+	federated := FederatedPage{Products: []SourcedProduct{}, Errors: []SourceError{}}
+	for i, name := range m.names {
+		if results[i].err != nil {
+			federated.Errors = append(federated.Errors, SourceError{Database: name, Error: results[i].err.Error()})
+			continue
+		}
+		federated.Total += results[i].page.Total
+		for _, product := range results[i].page.Products {
+			federated.Products = append(federated.Products, SourcedProduct{Product: product, Database: name})
+		}
+	}
+
+	// Общая сортировка; при равенстве товары упорядочены по базе в порядке регистрации
+	order := query.withIDTiebreak()
+	sort.SliceStable(federated.Products, func(i, j int) bool {
+		return lessProducts(order, federated.Products[i].Product, federated.Products[j].Product)
+	})
+
+	if query.Offset >= len(federated.Products) {
+		federated.Products = []SourcedProduct{}
+		return federated
+	}
+	federated.Products = federated.Products[query.Offset:]
+	if query.Limit > 0 && len(federated.Products) > query.Limit {
+		federated.Products = federated.Products[:query.Limit]
+	}
+
+	return federated
+}
+
+// listWithTimeout запрашивает список товаров одной базы, не дожидаясь ответа дольше timeout.
+// Запрос к зависшей базе продолжает выполняться в фоне, но его результат отбрасывается
+func listWithTimeout(ctx context.Context, b *backend, query ProductQuery, timeout time.Duration) sourcePage {
+	store, err := b.get(true)
+	if err != nil {
+		return sourcePage{err: err}
+	}
+
+	done := make(chan sourcePage, 1)
+	go func() {
+		page, err := store.ListProducts(query)
+		done <- sourcePage{page: page, err: err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case result := <-done:
+		return result
+	case <-timer.C:
+		return sourcePage{err: fmt.Errorf("база данных %s не ответила за %v", b.name, timeout)}
+	case <-ctx.Done():
+		return sourcePage{err: ctx.Err()}
+	}
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Состояния базы данных в отчете о доступности
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// BackendStatus результат проверки доступности логической базы данных
+type BackendStatus struct {
+	Name      string  `json:"name"`
+	Driver    string  `json:"driver,omitempty"`
+	Required  bool    `json:"required"`
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// CheckHealth параллельно проверяет доступность всех баз данных.
+// Каждая проверка ограничена таймаутом; результаты возвращаются в порядке регистрации баз
+func (m *DBManager) CheckHealth(ctx context.Context, timeout time.Duration) []BackendStatus {
+	statuses := make([]BackendStatus, len(m.names))
+
+	var wg sync.WaitGroup
+	for i, name := range m.names {
+		b := m.backends[name]
+		statuses[i] = BackendStatus{Name: name, Driver: b.driver, Required: b.required, Status: StatusDown}
+
+		wg.Add(1)
+		go func(status *BackendStatus, b *backend) {
+			defer wg.Done()
+
+			store, err := b.get(false)
+			if err != nil {
+				status.Error = err.Error()
+				return
+			}
+
+			pinger, ok := store.(Pinger)
+			if !ok {
+				status.Status = StatusUp
+				return
+			}
+
+			pingCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			start := time.Now()
+			err = pinger.Ping(pingCtx)
+			status.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+			if err != nil {
+				status.Error = err.Error()
+				return
+			}
+			status.Status = StatusUp
+		}(&statuses[i], b)
+	}
+	wg.Wait()
+
+	return statuses
+}
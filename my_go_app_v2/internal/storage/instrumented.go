@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"project/internal/metrics"
+	"project/internal/models"
+)
+
+// instrumentedStore обертка над ProductStore, собирающая метрики каждой операции
+type instrumentedStore struct {
+	name   string
+	driver string
+	next   ProductStore
+}
+
+// observe учитывает длительность и ошибку операции
+func (s *instrumentedStore) observe(operation string, start time.Time, err error) {
+	metrics.ObserveStorage(s.name, s.driver, operation, start, err)
+}
+
+func (s *instrumentedStore) GetProduct(id int) (models.Product, bool, error) {
+	start := time.Now()
+	product, exists, err := s.next.GetProduct(id)
+	s.observe("get", start, err)
+	return product, exists, err
+}
+
+func (s *instrumentedStore) GetAllProducts() ([]models.Product, error) {
+	start := time.Now()
+	products, err := s.next.GetAllProducts()
+	s.observe("get_all", start, err)
+	return products, err
+}
+
+func (s *instrumentedStore) ListProducts(query ProductQuery) (ProductPage, error) {
+	start := time.Now()
+	page, err := s.next.ListProducts(query)
+	s.observe("list", start, err)
+	return page, err
+}
+
+func (s *instrumentedStore) StreamProducts(ctx context.Context, query ProductQuery, fn func(models.Product) error) error {
+	start := time.Now()
+	err := s.next.StreamProducts(ctx, query, fn)
+	s.observe("stream", start, err)
+	return err
+}
+
+func (s *instrumentedStore) SearchProducts(text string, limit int) ([]SearchResult, error) {
+	start := time.Now()
+	results, err := s.next.SearchProducts(text, limit)
+	s.observe("search", start, err)
+	return results, err
+}
+
+func (s *instrumentedStore) AddProduct(product models.Product) (int, error) {
+	start := time.Now()
+	id, err := s.next.AddProduct(product)
+	s.observe("add", start, err)
+	return id, err
+}
+
+func (s *instrumentedStore) UpdateProduct(product models.Product, version int64) (int64, error) {
+	start := time.Now()
+	newVersion, err := s.next.UpdateProduct(product, version)
+	s.observe("update", start, err)
+	return newVersion, err
+}
+
+func (s *instrumentedStore) PatchProduct(product models.Product, fields []string, version int64) (int64, error) {
+	start := time.Now()
+	newVersion, err := s.next.PatchProduct(product, fields, version)
+	s.observe("patch", start, err)
+	return newVersion, err
+}
+
+func (s *instrumentedStore) DeleteProduct(id int, version int64) error {
+	start := time.Now()
+	err := s.next.DeleteProduct(id, version)
+	s.observe("delete", start, err)
+	return err
+}
+
+func (s *instrumentedStore) BulkWrite(operations []BulkOperation, atomic bool) ([]BulkResult, error) {
+	start := time.Now()
+	results, err := s.next.BulkWrite(operations, atomic)
+	s.observe("bulk", start, err)
+	return results, err
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"project/internal/models"
+)
+
+// MemoryClient хранилище товаров в памяти процесса (для локальной разработки и тестов)
+type MemoryClient struct {
+	mu       sync.RWMutex
+	products map[int]models.Product
+	// lastID наибольший выданный или занятый ID: новые товары без ID получают следующий
+	lastID int
+
+	// watchers подписчики на изменения (см. WatchChanges)
+	watchers memoryWatchers
+}
+
+// NewMemoryClient создает пустое хранилище в памяти
+func NewMemoryClient() *MemoryClient {
+	return &MemoryClient{products: make(map[int]models.Product)}
+}
+
+// Ping всегда успешен: хранилище в памяти процесса доступно, пока работает сервер
+func (m *MemoryClient) Ping(ctx context.Context) error {
+	return nil
+}
+
+// GetProduct получает продукт из памяти по ID
+func (m *MemoryClient) GetProduct(id int) (models.Product, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	product, exists := m.products[id]
+	return product, exists, nil
+}
+
+// GetAllProducts получает все продукты из памяти, упорядоченные по ID
+func (m *MemoryClient) GetAllProducts() ([]models.Product, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	products := make([]models.Product, 0, len(m.products))
+	for _, product := range m.products {
+		products = append(products, product)
+	}
+	sort.Slice(products, func(i, j int) bool { return products[i].ID < products[j].ID })
+
+	return products, nil
+}
+
+// ListProducts получает страницу продуктов из памяти с учетом фильтров и сортировки
+func (m *MemoryClient) ListProducts(query ProductQuery) (ProductPage, error) {
+	if len(query.IDs) > 0 {
+		// Выборка по ID не требует перебора всех товаров
+		m.mu.RLock()
+		products := make([]models.Product, 0, len(query.IDs))
+		for _, id := range query.IDs {
+			if product, exists := m.products[id]; exists {
+				products = append(products, product)
+			}
+		}
+		m.mu.RUnlock()
+		return query.Apply(products), nil
+	}
+
+	products, err := m.GetAllProducts()
+	if err != nil {
+		return ProductPage{}, err
+	}
+	return query.Apply(products), nil
+}
+
+// AddProduct добавляет продукт в память; продукт без ID получает следующий свободный ID
+func (m *MemoryClient) AddProduct(product models.Product) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if product.ID == 0 {
+		product.ID = m.lastID + 1
+	}
+	if _, exists := m.products[product.ID]; exists {
+		return 0, &DuplicateError{ID: product.ID}
+	}
+	m.lastID = max(m.lastID, product.ID)
+
+	product.Version = 1
+	m.products[product.ID] = product
+	m.watchers.notify(ProductChange{Operation: ChangeCreated, ProductID: product.ID, Product: &product, Time: time.Now().UTC()})
+	return product.ID, nil
+}
+
+// UpdateProduct обновляет продукт в памяти, проверяя версию (0 - без проверки)
+func (m *MemoryClient) UpdateProduct(product models.Product, version int64) (int64, error) {
+	return m.PatchProduct(product, patchFields, version)
+}
+
+// PatchProduct изменяет поля fields продукта в памяти, проверяя версию (0 - без проверки)
+func (m *MemoryClient) PatchProduct(product models.Product, fields []string, version int64) (int64, error) {
+	if err := checkFields(fields); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, exists := m.products[product.ID]
+	if !exists {
+		return 0, fmt.Errorf("продукт с ID %d не найден", product.ID)
+	}
+	if version != 0 && current.Version != version {
+		return 0, &VersionConflictError{ID: product.ID, Expected: version, Current: current.Version}
+	}
+
+	CopyFields(&current, product, fields)
+	current.Version++
+	m.products[product.ID] = current
+	m.watchers.notify(ProductChange{Operation: ChangeUpdated, ProductID: current.ID, Product: &current, Time: time.Now().UTC()})
+	return current.Version, nil
+}
+
+// DeleteProduct удаляет продукт из памяти, проверяя версию (0 - без проверки)
+func (m *MemoryClient) DeleteProduct(id int, version int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, exists := m.products[id]
+	if !exists {
+		return fmt.Errorf("продукт с ID %d не найден", id)
+	}
+	if version != 0 && current.Version != version {
+		return &VersionConflictError{ID: id, Expected: version, Current: current.Version}
+	}
+
+	delete(m.products, id)
+	m.watchers.notify(ProductChange{Operation: ChangeDeleted, ProductID: id, Product: &current, Time: time.Now().UTC()})
+	return nil
+}
@@ -0,0 +1,713 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"project/internal/config"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migration миграция схемы базы данных и ее состояние
+type Migration struct {
+	Version int
+	Name    string
+	// AppliedAt время применения; нулевое у непримененной миграции
+	AppliedAt time.Time
+	// Unknown миграция применена более новой версией сервера и этой версии неизвестна
+	Unknown bool
+}
+
+// Applied сообщает, что миграция применена
+func (m Migration) Applied() bool {
+	return !m.AppliedAt.IsZero()
+}
+
+// Migrator управляет версиями схемы базы данных
+type Migrator interface {
+	// Migrations возвращает известные и примененные миграции по возрастанию версии
+	Migrations(ctx context.Context) ([]Migration, error)
+	// MigrateUp применяет непримененные миграции по порядку и возвращает примененные
+	MigrateUp(ctx context.Context) ([]Migration, error)
+	// MigrateDown отменяет steps последних примененных миграций и возвращает отмененные
+	MigrateDown(ctx context.Context, steps int) ([]Migration, error)
+	Close() error
+}
+
+// ErrMigrationsNotSupported хранилище не имеет схемы, которой нужно управлять
+var ErrMigrationsNotSupported = errors.New("хранилище не поддерживает миграции схемы")
+
+// errMigrationLocked миграции выполняет другой экземпляр сервера
+var errMigrationLocked = errors.New("миграции схемы выполняет другой процесс: блокировка не освобождена")
+
+const (
+	// migrationLockTimeout время ожидания блокировки миграций, которую держит другой процесс
+	migrationLockTimeout = time.Minute
+	// migrationLockPoll интервал повторных попыток взять блокировку
+	migrationLockPoll = 500 * time.Millisecond
+)
+
+// Режимы применения миграций при подключении (опция migrate)
+const (
+	// migrateAuto миграции применяются при каждом подключении к базе (по умолчанию)
+	migrateAuto = "auto"
+	// migrateManual миграции применяются только подкомандой migrate up
+	migrateManual = "manual"
+)
+
+// OpenMigrator подключается к базе для управления ее схемой, не применяя миграции при подключении
+func OpenMigrator(cfg config.DatabaseConfig) (Migrator, error) {
+	switch cfg.Driver {
+	case config.DriverPostgres, config.DriverMySQL, config.DriverSQLite:
+		store, err := connectSQL(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return &store, nil
+	case config.DriverMongoDB:
+		return connectMongo(cfg)
+	}
+	return nil, ErrMigrationsNotSupported
+}
+
+// migrateOnOpen приводит схему базы к текущей версии при подключении. С опцией migrate=manual
+// схема не изменяется, а подключение к базе с непримененными миграциями завершается ошибкой
+func migrateOnOpen(cfg config.DatabaseConfig, migrator Migrator) error {
+	ctx := context.Background()
+
+	switch mode := cfg.Option("migrate", migrateAuto); mode {
+	case migrateAuto:
+		applied, err := migrator.MigrateUp(ctx)
+		for _, migration := range applied {
+			log.Printf("База %s: применена миграция %d %s", cfg.Name, migration.Version, migration.Name)
+		}
+		return err
+
+	case migrateManual:
+		migrations, err := migrator.Migrations(ctx)
+		if err != nil {
+			return err
+		}
+		var pending []string
+		for _, migration := range migrations {
+			if !migration.Applied() {
+				pending = append(pending, fmt.Sprintf("%d %s", migration.Version, migration.Name))
+			}
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("схема базы устарела, не применены миграции: %s; выполните server migrate up -db %s",
+				strings.Join(pending, ", "), cfg.Name)
+		}
+		return nil
+	}
+	return fmt.Errorf("недопустимое значение опции migrate %q: ожидается auto или manual", cfg.Option("migrate", ""))
+}
+
+// migrationStatus объединяет известные миграции с примененными. Примененные миграции,
+// которых нет среди известных, отмечаются как неизвестные
+func migrationStatus(known []Migration, applied map[int]Migration) []Migration {
+	migrations := make([]Migration, 0, len(known))
+	for _, migration := range known {
+		if record, ok := applied[migration.Version]; ok {
+			migration.AppliedAt = record.AppliedAt
+			delete(applied, migration.Version)
+		}
+		migrations = append(migrations, migration)
+	}
+	for _, record := range applied {
+		record.Unknown = true
+		migrations = append(migrations, record)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations
+}
+
+// rollbackPlan выбирает steps последних примененных миграций в порядке отмены и проверяет,
+// что каждую из них можно отменить (reversible)
+func rollbackPlan(migrations []Migration, steps int, reversible func(version int) bool) ([]Migration, error) {
+	if steps < 1 {
+		return nil, fmt.Errorf("количество отменяемых миграций должно быть положительным, получено %d", steps)
+	}
+
+	var plan []Migration
+	for i := len(migrations) - 1; i >= 0 && len(plan) < steps; i-- {
+		migration := migrations[i]
+		if !migration.Applied() {
+			continue
+		}
+		switch {
+		case migration.Unknown:
+			return nil, fmt.Errorf("миграция %d %s применена более новой версией сервера и не может быть отменена этой версией",
+				migration.Version, migration.Name)
+		case !reversible(migration.Version):
+			return nil, fmt.Errorf("миграцию %d %s нельзя отменить", migration.Version, migration.Name)
+		}
+		plan = append(plan, migration)
+	}
+	return plan, nil
+}
+
+// ----- Миграции SQL-баз -----
+
+// sqlMigration шаг схемы SQL-базы. Шаги идемпотентны: схема, созданная до появления миграций,
+// принимается как есть, и журнал миграций просто заполняется
+type sqlMigration struct {
+	version int
+	name    string
+	up      func(s *sqlStore, q sqlQuerier) error
+	// down отменяет миграцию; nil - миграцию нельзя отменить
+	down func(s *sqlStore, q sqlQuerier) error
+}
+
+// sqlMigrations миграции SQL-баз по возрастанию версии. Новые миграции добавляются только в конец
+var sqlMigrations = []sqlMigration{
+	{1, "create_products", (*sqlStore).createProductsTable, nil},
+	{2, "add_version", (*sqlStore).addVersionColumn, (*sqlStore).dropVersionColumn},
+	{3, "generated_ids", (*sqlStore).addGeneratedIDs, (*sqlStore).dropGeneratedIDs},
+	{4, "search_index", (*sqlStore).createSearchIndex, (*sqlStore).dropSearchIndex},
+	{5, "change_log", (*sqlStore).createChangeLog, (*sqlStore).dropChangeLog},
+	// Пересозданная таблица остается и после отмены: AUTOINCREMENT не мешает старым версиям сервера
+	{6, "autoincrement_ids", (*sqlStore).addSQLiteAutoincrement, func(*sqlStore, sqlQuerier) error { return nil }},
+	{7, "change_log_rows", (*sqlStore).addChangeLogRows, (*sqlStore).dropChangeLogRows},
+}
+
+// findSQLMigration ищет миграцию по версии
+func findSQLMigration(version int) (sqlMigration, bool) {
+	for _, migration := range sqlMigrations {
+		if migration.version == version {
+			return migration, true
+		}
+	}
+	return sqlMigration{}, false
+}
+
+// schemaMigrationsTable журнал примененных миграций. Время применения хранится строкой RFC 3339,
+// одинаковой во всех диалектах
+const schemaMigrationsTable = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INT NOT NULL PRIMARY KEY,
+		name VARCHAR(100) NOT NULL,
+		applied_at VARCHAR(40) NOT NULL
+	)
+`
+
+// postgresMigrationLock ключ рекомендательной блокировки миграций PostgreSQL
+const postgresMigrationLock = 0x70726f64
+
+// sqlContextQuerier общие методы пула и выделенного соединения
+type sqlContextQuerier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Migrations возвращает состояние миграций SQL-базы
+func (s *sqlStore) Migrations(ctx context.Context) ([]Migration, error) {
+	return s.migrationStatus(ctx, s.DB)
+}
+
+// migrationStatus читает журнал миграций; база без журнала считается базой без примененных миграций
+func (s *sqlStore) migrationStatus(ctx context.Context, q sqlContextQuerier) ([]Migration, error) {
+	known := make([]Migration, len(sqlMigrations))
+	for i, migration := range sqlMigrations {
+		known[i] = Migration{Version: migration.version, Name: migration.name}
+	}
+
+	var query string
+	switch s.dialect {
+	case postgresDialect:
+		query = `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = 'schema_migrations'`
+	case mysqlDialect:
+		query = `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = 'schema_migrations'`
+	case sqliteDialect:
+		query = `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'`
+	}
+	var count int
+	if err := q.QueryRowContext(ctx, query).Scan(&count); err != nil {
+		return nil, err
+	}
+	applied := make(map[int]Migration)
+	if count == 0 {
+		return migrationStatus(known, applied), nil
+	}
+
+	rows, err := q.QueryContext(ctx, `SELECT version, name, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var record Migration
+		var appliedAt string
+		if err := rows.Scan(&record.Version, &record.Name, &appliedAt); err != nil {
+			return nil, err
+		}
+		if record.AppliedAt, err = time.Parse(time.RFC3339, appliedAt); err != nil {
+			return nil, fmt.Errorf("миграция %d: некорректное время применения %q", record.Version, appliedAt)
+		}
+		applied[record.Version] = record
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return migrationStatus(known, applied), nil
+}
+
+// MigrateUp применяет непримененные миграции SQL-базы, каждую в своей транзакции.
+// В MySQL DDL-операторы фиксируются сразу, поэтому прерванная миграция может быть применена
+// частично; шаги идемпотентны, и повторный запуск ее завершает
+func (s *sqlStore) MigrateUp(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := s.withMigrationLock(ctx, func(conn *sql.Conn) error {
+		if _, err := conn.ExecContext(ctx, schemaMigrationsTable); err != nil {
+			return err
+		}
+		migrations, err := s.migrationStatus(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range migrations {
+			if migration.Applied() {
+				continue
+			}
+			step, _ := findSQLMigration(migration.Version)
+			if migration.AppliedAt, err = s.runMigration(ctx, conn, step, true); err != nil {
+				return fmt.Errorf("миграция %d %s: %w", step.version, step.name, err)
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// MigrateDown отменяет steps последних примененных миграций SQL-базы
+func (s *sqlStore) MigrateDown(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := s.withMigrationLock(ctx, func(conn *sql.Conn) error {
+		migrations, err := s.migrationStatus(ctx, conn)
+		if err != nil {
+			return err
+		}
+		plan, err := rollbackPlan(migrations, steps, func(version int) bool {
+			step, _ := findSQLMigration(version)
+			return step.down != nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, migration := range plan {
+			step, _ := findSQLMigration(migration.Version)
+			if _, err := s.runMigration(ctx, conn, step, false); err != nil {
+				return fmt.Errorf("отмена миграции %d %s: %w", step.version, step.name, err)
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// runMigration применяет (up) или отменяет миграцию и отмечает это в журнале одной транзакцией
+func (s *sqlStore) runMigration(ctx context.Context, conn *sql.Conn, step sqlMigration, up bool) (time.Time, error) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer tx.Rollback()
+
+	appliedAt := time.Now().UTC().Truncate(time.Second)
+	if up {
+		if err := step.up(s, tx); err != nil {
+			return time.Time{}, err
+		}
+		_, err = tx.Exec(s.dialect.rebind(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`),
+			step.version, step.name, appliedAt.Format(time.RFC3339))
+	} else {
+		if err := step.down(s, tx); err != nil {
+			return time.Time{}, err
+		}
+		_, err = tx.Exec(s.dialect.rebind(`DELETE FROM schema_migrations WHERE version = ?`), step.version)
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return appliedAt, tx.Commit()
+}
+
+// withMigrationLock выполняет fn на выделенном соединении, удерживая блокировку миграций, чтобы
+// несколько экземпляров сервера не применяли миграции одновременно: рекомендательную блокировку
+// в PostgreSQL, именованную блокировку GET_LOCK в MySQL. В SQLite писатель один, и миграции
+// упорядочивает сама транзакция. Блокировка освобождается и при обрыве соединения
+func (s *sqlStore) withMigrationLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := s.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	switch s.dialect {
+	case postgresDialect:
+		lockCtx, cancel := context.WithTimeout(ctx, migrationLockTimeout)
+		defer cancel()
+		for {
+			var locked bool
+			if err := conn.QueryRowContext(lockCtx, `SELECT pg_try_advisory_lock($1)`, postgresMigrationLock).Scan(&locked); err != nil {
+				return err
+			}
+			if locked {
+				break
+			}
+			select {
+			case <-lockCtx.Done():
+				return errMigrationLocked
+			case <-time.After(migrationLockPoll):
+			}
+		}
+		defer conn.QueryRowContext(context.Background(), `SELECT pg_advisory_unlock($1)`, postgresMigrationLock).Scan(new(bool))
+
+	case mysqlDialect:
+		// Именованные блокировки MySQL общие для сервера, поэтому имя включает имя базы
+		const lockName = `CONCAT(DATABASE(), '.schema_migrations')`
+		var locked sql.NullInt64
+		err := conn.QueryRowContext(ctx, `SELECT GET_LOCK(`+lockName+`, ?)`, int(migrationLockTimeout.Seconds())).Scan(&locked)
+		if err != nil {
+			return err
+		}
+		if locked.Int64 != 1 {
+			return errMigrationLocked
+		}
+		defer conn.QueryRowContext(context.Background(), `SELECT RELEASE_LOCK(`+lockName+`)`).Scan(new(sql.NullInt64))
+	}
+
+	return fn(conn)
+}
+
+// dropVersionColumn удаляет столбец version
+func (s *sqlStore) dropVersionColumn(q sqlQuerier) error {
+	_, err := q.Exec(`ALTER TABLE products DROP COLUMN version`)
+	return err
+}
+
+// dropGeneratedIDs отключает генерацию ID. В SQLite столбец INTEGER PRIMARY KEY назначает ID всегда
+func (s *sqlStore) dropGeneratedIDs(q sqlQuerier) error {
+	var err error
+	switch s.dialect {
+	case postgresDialect:
+		_, err = q.Exec(`ALTER TABLE products ALTER COLUMN id DROP IDENTITY IF EXISTS`)
+	case mysqlDialect:
+		_, err = q.Exec(`ALTER TABLE products MODIFY id INT NOT NULL`)
+	}
+	return err
+}
+
+// dropSearchIndex удаляет индекс полнотекстового поиска
+func (s *sqlStore) dropSearchIndex(q sqlQuerier) error {
+	var statements []string
+	switch s.dialect {
+	case postgresDialect:
+		statements = []string{`DROP INDEX IF EXISTS products_search_idx`}
+
+	case mysqlDialect:
+		var count int
+		err := q.QueryRow(`SELECT COUNT(*) FROM information_schema.statistics
+			WHERE table_schema = DATABASE() AND table_name = 'products' AND index_name = 'products_search_idx'`).Scan(&count)
+		if err != nil || count == 0 {
+			return err
+		}
+		statements = []string{`ALTER TABLE products DROP INDEX products_search_idx`}
+
+	case sqliteDialect:
+		statements = []string{
+			`DROP TRIGGER IF EXISTS products_fts_insert`,
+			`DROP TRIGGER IF EXISTS products_fts_delete`,
+			`DROP TRIGGER IF EXISTS products_fts_update`,
+			`DROP TABLE IF EXISTS products_fts`,
+		}
+	}
+
+	for _, statement := range statements {
+		if _, err := q.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropChangeLog удаляет триггеры и таблицу журнала изменений
+func (s *sqlStore) dropChangeLog(q sqlQuerier) error {
+	var statements []string
+	switch s.dialect {
+	case postgresDialect:
+		statements = []string{
+			`DROP TRIGGER IF EXISTS products_changes_trigger ON products`,
+			`DROP FUNCTION IF EXISTS products_log_change()`,
+		}
+	case mysqlDialect, sqliteDialect:
+		statements = []string{
+			`DROP TRIGGER IF EXISTS products_changes_insert`,
+			`DROP TRIGGER IF EXISTS products_changes_update`,
+			`DROP TRIGGER IF EXISTS products_changes_delete`,
+		}
+	}
+	statements = append(statements, `DROP TABLE IF EXISTS product_changes`)
+
+	for _, statement := range statements {
+		if _, err := q.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropChangeLogRows возвращает прежний триггер удаления и удаляет столбцы строки товара из журнала изменений
+func (s *sqlStore) dropChangeLogRows(q sqlQuerier) error {
+	if err := s.replaceDeleteTrigger(q, false); err != nil {
+		return err
+	}
+	for _, column := range changeLogRowColumns {
+		exists, err := s.hasColumn(q, "product_changes", column.name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			if _, err := q.Exec(`ALTER TABLE product_changes DROP COLUMN ` + column.name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ----- Миграции MongoDB -----
+
+// mongoMigration шаг схемы коллекции MongoDB: индексы, валидаторы, преобразования документов.
+// Шаги идемпотентны, как и миграции SQL-баз
+type mongoMigration struct {
+	version int
+	name    string
+	up      func(m *MongoDBClient, ctx context.Context) error
+	// down отменяет миграцию; nil - миграцию нельзя отменить
+	down func(m *MongoDBClient, ctx context.Context) error
+}
+
+// mongoMigrations миграции коллекции товаров по возрастанию версии. Новые миграции добавляются только в конец
+var mongoMigrations = []mongoMigration{
+	{1, "id_index", (*MongoDBClient).createIDIndex, (*MongoDBClient).dropIDIndex},
+	{2, "search_index", (*MongoDBClient).createSearchIndex, (*MongoDBClient).dropSearchIndex},
+	// Версии остаются в документах и после отмены: их не читают только старые версии сервера
+	{3, "version_backfill", (*MongoDBClient).backfillVersions, func(*MongoDBClient, context.Context) error { return nil }},
+}
+
+// findMongoMigration ищет миграцию по версии
+func findMongoMigration(version int) (mongoMigration, bool) {
+	for _, migration := range mongoMigrations {
+		if migration.version == version {
+			return migration, true
+		}
+	}
+	return mongoMigration{}, false
+}
+
+// mongoLockExpiry блокировка миграций старше этого срока считается оставшейся от аварийно
+// завершенного процесса и снимается
+const mongoLockExpiry = 10 * time.Minute
+
+// migrationRecord запись журнала миграций MongoDB. Журнал общий для коллекций базы, поэтому
+// _id состоит из имени коллекции и версии
+type migrationRecord struct {
+	ID         string    `bson:"_id"`
+	Collection string    `bson:"collection"`
+	Version    int       `bson:"version"`
+	Name       string    `bson:"name"`
+	AppliedAt  time.Time `bson:"applied_at"`
+}
+
+// migrations коллекция журнала миграций
+func (m *MongoDBClient) migrations() *mongo.Collection {
+	return m.Database.Collection("schema_migrations")
+}
+
+// migrationID ключ записи журнала миграций коллекции товаров
+func (m *MongoDBClient) migrationID(suffix string) string {
+	return m.Collection.Name() + ":" + suffix
+}
+
+// Migrations возвращает состояние миграций коллекции товаров
+func (m *MongoDBClient) Migrations(ctx context.Context) ([]Migration, error) {
+	known := make([]Migration, len(mongoMigrations))
+	for i, migration := range mongoMigrations {
+		known[i] = Migration{Version: migration.version, Name: migration.name}
+	}
+
+	cursor, err := m.migrations().Find(ctx, bson.M{"collection": m.Collection.Name()})
+	if err != nil {
+		return nil, err
+	}
+	var records []migrationRecord
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]Migration, len(records))
+	for _, record := range records {
+		applied[record.Version] = Migration{Version: record.Version, Name: record.Name, AppliedAt: record.AppliedAt.UTC()}
+	}
+	return migrationStatus(known, applied), nil
+}
+
+// MigrateUp применяет непримененные миграции коллекции товаров и обновляет ее валидатор.
+// Запись в журнал делается после успешного шага: прерванная миграция повторяется целиком
+func (m *MongoDBClient) MigrateUp(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.withMigrationLock(ctx, func() error {
+		migrations, err := m.Migrations(ctx)
+		if err != nil {
+			return err
+		}
+		for _, migration := range migrations {
+			if migration.Applied() {
+				continue
+			}
+			step, _ := findMongoMigration(migration.Version)
+			if err := step.up(m, ctx); err != nil {
+				return fmt.Errorf("миграция %d %s: %w", step.version, step.name, err)
+			}
+			migration.AppliedAt = time.Now().UTC().Truncate(time.Millisecond)
+			_, err := m.migrations().InsertOne(ctx, migrationRecord{
+				ID:         m.migrationID(strconv.Itoa(step.version)),
+				Collection: m.Collection.Name(),
+				Version:    step.version,
+				Name:       step.name,
+				AppliedAt:  migration.AppliedAt,
+			})
+			if err != nil {
+				return fmt.Errorf("миграция %d %s: %w", step.version, step.name, err)
+			}
+			applied = append(applied, migration)
+		}
+		// Валидатор не версионируется: он строится по модели товара и устанавливается заново
+		if err := m.applyValidator(ctx); err != nil {
+			return fmt.Errorf("валидатор коллекции: %w", err)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// MigrateDown отменяет steps последних примененных миграций коллекции товаров
+func (m *MongoDBClient) MigrateDown(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.withMigrationLock(ctx, func() error {
+		migrations, err := m.Migrations(ctx)
+		if err != nil {
+			return err
+		}
+		plan, err := rollbackPlan(migrations, steps, func(version int) bool {
+			step, _ := findMongoMigration(version)
+			return step.down != nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, migration := range plan {
+			step, _ := findMongoMigration(migration.Version)
+			if err := step.down(m, ctx); err != nil {
+				return fmt.Errorf("отмена миграции %d %s: %w", step.version, step.name, err)
+			}
+			if _, err := m.migrations().DeleteOne(ctx, bson.M{"_id": m.migrationID(strconv.Itoa(step.version))}); err != nil {
+				return fmt.Errorf("отмена миграции %d %s: %w", step.version, step.name, err)
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// withMigrationLock выполняет fn, удерживая блокировку миграций коллекции: документ журнала
+// с уникальным _id, который может вставить только один процесс
+func (m *MongoDBClient) withMigrationLock(ctx context.Context, fn func() error) error {
+	lockID := m.migrationID("lock")
+	deadline := time.Now().Add(migrationLockTimeout)
+	for {
+		_, err := m.migrations().InsertOne(ctx, bson.M{"_id": lockID, "locked_at": time.Now()})
+		if err == nil {
+			break
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+		_, err = m.migrations().DeleteOne(ctx, bson.M{"_id": lockID, "locked_at": bson.M{"$lt": time.Now().Add(-mongoLockExpiry)}})
+		if err != nil {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return errMigrationLocked
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(migrationLockPoll):
+		}
+	}
+	defer m.migrations().DeleteOne(context.Background(), bson.M{"_id": lockID})
+
+	return fn()
+}
+
+// createIDIndex создает уникальный индекс по полю id
+func (m *MongoDBClient) createIDIndex(ctx context.Context) error {
+	_, err := m.Collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+func (m *MongoDBClient) dropIDIndex(ctx context.Context) error {
+	return m.dropIndex(ctx, "id_1")
+}
+
+// createSearchIndex создает текстовый индекс для полнотекстового поиска: совпадения в названии весомее, чем в описании
+func (m *MongoDBClient) createSearchIndex(ctx context.Context) error {
+	_, err := m.Collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "name", Value: "text"}, {Key: "description", Value: "text"}},
+		Options: options.Index().
+			SetName("products_search_idx").
+			SetWeights(bson.D{{Key: "name", Value: 3}, {Key: "description", Value: 1}}).
+			SetDefaultLanguage("russian"),
+	})
+	return err
+}
+
+func (m *MongoDBClient) dropSearchIndex(ctx context.Context) error {
+	return m.dropIndex(ctx, "products_search_idx")
+}
+
+// backfillVersions назначает версию 1 документам, сохраненным до появления версий
+func (m *MongoDBClient) backfillVersions(ctx context.Context) error {
+	_, err := m.Collection.UpdateMany(ctx, bson.M{"version": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"version": 1}})
+	return err
+}
+
+// dropIndex удаляет индекс коллекции товаров; отсутствие индекса или коллекции ошибкой не считается
+func (m *MongoDBClient) dropIndex(ctx context.Context, name string) error {
+	_, err := m.Collection.Indexes().DropOne(ctx, name)
+	var commandErr mongo.CommandError
+	// 26 - NamespaceNotFound, 27 - IndexNotFound
+	if errors.As(err, &commandErr) && (commandErr.Code == 26 || commandErr.Code == 27) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"project/internal/config"
+	"project/internal/models"
+)
+
+func TestRollbackPlan(t *testing.T) {
+	applied := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	migrations := []Migration{
+		{Version: 1, Name: "create_products", AppliedAt: applied},
+		{Version: 2, Name: "add_version", AppliedAt: applied},
+		{Version: 3, Name: "generated_ids", AppliedAt: applied},
+		{Version: 4, Name: "search_index"},
+	}
+	reversible := func(version int) bool { return version != 1 }
+
+	tests := []struct {
+		name       string
+		migrations []Migration
+		steps      int
+		// want версии миграций в порядке отмены; nil - ожидается ошибка
+		want []int
+	}{
+		{name: "последняя примененная", migrations: migrations, steps: 1, want: []int{3}},
+		{name: "несколько по убыванию версии", migrations: migrations, steps: 2, want: []int{3, 2}},
+		{name: "неотменяемая миграция", migrations: migrations, steps: 3},
+		{name: "неположительное количество", migrations: migrations, steps: 0},
+		{
+			name:       "миграция более новой версии сервера",
+			migrations: append(migrations[:3:3], Migration{Version: 9, Name: "future", AppliedAt: applied, Unknown: true}),
+			steps:      1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := rollbackPlan(tt.migrations, tt.steps, reversible)
+			if tt.want == nil {
+				if err == nil {
+					t.Fatalf("ожидалась ошибка, получен план %+v", plan)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("неожиданная ошибка: %v", err)
+			}
+			var versions []int
+			for _, migration := range plan {
+				versions = append(versions, migration.Version)
+			}
+			if !reflect.DeepEqual(versions, tt.want) {
+				t.Fatalf("получено %v, ожидалось %v", versions, tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLiteMigrateUpDown(t *testing.T) {
+	ctx := context.Background()
+	migrator, err := OpenMigrator(config.DatabaseConfig{Name: "test_db", Driver: config.DriverSQLite, DSN: ":memory:"})
+	if err != nil {
+		t.Fatalf("открытие SQLite: %v", err)
+	}
+	defer migrator.Close()
+	store := migrator.(*sqlStore)
+
+	// appliedVersions версии примененных миграций
+	appliedVersions := func() []int {
+		t.Helper()
+		migrations, err := migrator.Migrations(ctx)
+		if err != nil {
+			t.Fatalf("состояние миграций: %v", err)
+		}
+		var versions []int
+		for _, migration := range migrations {
+			if migration.Applied() {
+				versions = append(versions, migration.Version)
+			}
+		}
+		return versions
+	}
+	all := make([]int, len(sqlMigrations))
+	for i, migration := range sqlMigrations {
+		all[i] = migration.version
+	}
+
+	if versions := appliedVersions(); len(versions) != 0 {
+		t.Fatalf("до применения миграций примененными отмечены %v", versions)
+	}
+	if applied, err := migrator.MigrateUp(ctx); err != nil || len(applied) != len(sqlMigrations) {
+		t.Fatalf("применено %d миграций (%v), ожидалось %d", len(applied), err, len(sqlMigrations))
+	}
+	if applied, err := migrator.MigrateUp(ctx); err != nil || len(applied) != 0 {
+		t.Fatalf("повторно применено %d миграций (%v)", len(applied), err)
+	}
+
+	product := models.Product{Name: "Цемент", Category: "Смеси", Price: 450, InStock: true, Supplier: "Альфа"}
+	id, err := store.AddProduct(product)
+	if err != nil {
+		t.Fatalf("добавление товара: %v", err)
+	}
+
+	// Отмена всех отменяемых миграций сохраняет товары
+	rolledBack, err := migrator.MigrateDown(ctx, len(sqlMigrations)-1)
+	if err != nil {
+		t.Fatalf("отмена миграций: %v", err)
+	}
+	if len(rolledBack) != len(sqlMigrations)-1 || rolledBack[0].Version != all[len(all)-1] {
+		t.Fatalf("отменены миграции %+v", rolledBack)
+	}
+	if versions := appliedVersions(); !reflect.DeepEqual(versions, []int{1}) {
+		t.Fatalf("после отмены применены %v, ожидалась только 1", versions)
+	}
+	if hasVersion, err := store.hasColumn(store.DB, "products", "version"); err != nil || hasVersion {
+		t.Fatalf("столбец version остался после отмены (%v)", err)
+	}
+	var count int
+	if err := store.DB.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'product_changes'`).Scan(&count); err != nil || count != 0 {
+		t.Fatalf("журнал изменений остался после отмены (%v)", err)
+	}
+	if _, err := migrator.MigrateDown(ctx, 1); err == nil {
+		t.Fatal("отменена неотменяемая миграция create_products")
+	}
+
+	// Повторное применение восстанавливает схему, версия существующих товаров - 1
+	if _, err := migrator.MigrateUp(ctx); err != nil {
+		t.Fatalf("повторное применение миграций: %v", err)
+	}
+	if versions := appliedVersions(); !reflect.DeepEqual(versions, all) {
+		t.Fatalf("применены %v, ожидалось %v", versions, all)
+	}
+	got, exists, err := store.GetProduct(id)
+	if err != nil || !exists {
+		t.Fatalf("товар %d потерян при отмене миграций (%v)", id, err)
+	}
+	if got.Name != product.Name || got.Version != 1 {
+		t.Fatalf("получено %+v", got)
+	}
+}
@@ -0,0 +1,440 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	"project/internal/config"
+	"project/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoDBClient клиент для MongoDB
+type MongoDBClient struct {
+	Client     *mongo.Client
+	Database   *mongo.Database
+	Collection *mongo.Collection
+
+	// validation режим валидатора коллекции (опция validation, см. applyValidator)
+	validation string
+}
+
+// mongoURI собирает строку подключения к MongoDB из параметров конфигурации.
+// Параметры: auth_source - база аутентификации (по умолчанию совпадает с database),
+// auth_mechanism - механизм аутентификации
+func mongoURI(cfg config.DatabaseConfig) string {
+	if cfg.DSN != "" {
+		return cfg.DSN
+	}
+
+	port := cfg.Port
+	if port == 0 {
+		port = 27017
+	}
+
+	query := url.Values{}
+	query.Set("authSource", cfg.Option("auth_source", cfg.Database))
+	if mechanism := cfg.Option("auth_mechanism", ""); mechanism != "" {
+		query.Set("authMechanism", mechanism)
+	}
+
+	uri := url.URL{
+		Scheme:   "mongodb",
+		User:     url.UserPassword(cfg.User, cfg.Password),
+		Host:     net.JoinHostPort(cfg.Host, strconv.Itoa(port)),
+		Path:     "/",
+		RawQuery: query.Encode(),
+	}
+	return uri.String()
+}
+
+// Инициализация MongoDB.
+// Имя базы берется из параметра database (по умолчанию совпадает с именем логической базы),
+// имя коллекции - из опции collection (по умолчанию products)
+func initMongoDB(cfg config.DatabaseConfig) (*MongoDBClient, error) {
+	mongoClient, err := connectMongo(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Индексы и преобразования документов (см. mongoMigrations)
+	if err := migrateOnOpen(cfg, mongoClient); err != nil {
+		mongoClient.Close()
+		return nil, err
+	}
+
+	// Счетчик ID должен быть не меньше наибольшего ID в коллекции
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := mongoClient.syncCounter(ctx); err != nil {
+		mongoClient.Close()
+		return nil, err
+	}
+
+	return mongoClient, nil
+}
+
+// connectMongo подключается к MongoDB и проверяет соединение, не изменяя коллекцию товаров
+func connectMongo(cfg config.DatabaseConfig) (*MongoDBClient, error) {
+	validation := cfg.Option("validation", validationWarn)
+	if err := checkValidation(validation); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	clientOptions := options.Client().ApplyURI(mongoURI(cfg))
+	client, err := mongo.Connect(ctx, clientOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	// Проверка соединения
+	err = client.Ping(ctx, nil)
+	if err != nil {
+		client.Disconnect(context.Background())
+		return nil, err
+	}
+
+	databaseName := cfg.Database
+	if databaseName == "" {
+		databaseName = cfg.Name
+	}
+	database := client.Database(databaseName)
+	collection := database.Collection(cfg.Option("collection", "products"))
+
+	return &MongoDBClient{Client: client, Database: database, Collection: collection, validation: validation}, nil
+}
+
+// ----- Назначение ID -----
+
+// counters коллекция счетчиков ID: документ {_id: имя коллекции товаров, seq: последний выданный ID}
+func (m *MongoDBClient) counters() *mongo.Collection {
+	return m.Database.Collection("counters")
+}
+
+// nextID атомарно увеличивает счетчик и возвращает новый ID
+func (m *MongoDBClient) nextID(ctx context.Context) (int, error) {
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var counter struct {
+		Seq int `bson:"seq"`
+	}
+	err := m.counters().FindOneAndUpdate(ctx, bson.M{"_id": m.Collection.Name()}, bson.M{"$inc": bson.M{"seq": 1}}, opts).Decode(&counter)
+	return counter.Seq, err
+}
+
+// raiseCounter поднимает счетчик до id, если он меньше, чтобы назначаемые ID не совпадали с занятыми
+func (m *MongoDBClient) raiseCounter(ctx context.Context, id int) error {
+	_, err := m.counters().UpdateOne(ctx, bson.M{"_id": m.Collection.Name()},
+		bson.M{"$max": bson.M{"seq": id}}, options.Update().SetUpsert(true))
+	return err
+}
+
+// syncCounter поднимает счетчик до наибольшего ID в коллекции
+func (m *MongoDBClient) syncCounter(ctx context.Context) error {
+	var last models.Product
+	err := m.Collection.FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.D{{Key: "id", Value: -1}})).Decode(&last)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return m.raiseCounter(ctx, last.ID)
+}
+
+// Close закрывает соединение с MongoDB
+func (m *MongoDBClient) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return m.Client.Disconnect(ctx)
+}
+
+// Ping проверяет доступность MongoDB
+func (m *MongoDBClient) Ping(ctx context.Context) error {
+	return m.Client.Ping(ctx, nil)
+}
+
+// ----- Операции с товарами -----
+
+// GetProduct получает продукт из MongoDB по ID
+func (m *MongoDBClient) GetProduct(id int) (models.Product, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var product models.Product
+	filter := bson.M{"id": id}
+	err := m.Collection.FindOne(ctx, filter).Decode(&product)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.Product{}, false, nil
+		}
+		return models.Product{}, false, err
+	}
+
+	return product, true, nil
+}
+
+// GetAllProducts получает все продукты из MongoDB
+func (m *MongoDBClient) GetAllProducts() ([]models.Product, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := m.Collection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var products []models.Product
+	if err = cursor.All(ctx, &products); err != nil {
+		return nil, err
+	}
+
+	return products, nil
+}
+
+// mongoFields соответствие полей товара полям документов.
+// Документы сохраняются без bson-тегов, поэтому драйвер приводит имена полей к нижнему регистру
+var mongoFields = map[string]string{
+	"id":          "id",
+	"name":        "name",
+	"category":    "category",
+	"price":       "price",
+	"description": "description",
+	"in_stock":    "instock",
+	"supplier":    "supplier",
+}
+
+// mongoFilter формирует фильтр MongoDB по параметрам запроса
+func mongoFilter(query ProductQuery) bson.M {
+	filter := bson.M{}
+	if query.Category != "" {
+		filter[mongoFields["category"]] = query.Category
+	}
+	if query.Supplier != "" {
+		filter[mongoFields["supplier"]] = query.Supplier
+	}
+	if query.InStock != nil {
+		filter[mongoFields["in_stock"]] = *query.InStock
+	}
+
+	price := bson.M{}
+	if query.MinPrice != nil {
+		price["$gte"] = *query.MinPrice
+	}
+	if query.MaxPrice != nil {
+		price["$lte"] = *query.MaxPrice
+	}
+	if len(price) > 0 {
+		filter[mongoFields["price"]] = price
+	}
+	if len(query.IDs) > 0 {
+		filter["id"] = bson.M{"$in": query.IDs}
+	}
+
+	return filter
+}
+
+// mongoFindOptions сортировка и страница выборки товаров
+func mongoFindOptions(query ProductQuery) *options.FindOptions {
+	sort := bson.D{}
+	for _, field := range query.withIDTiebreak() {
+		direction := 1
+		if field.Desc {
+			direction = -1
+		}
+		sort = append(sort, bson.E{Key: mongoFields[field.Field], Value: direction})
+	}
+
+	findOptions := options.Find().SetSort(sort).SetSkip(int64(query.Offset))
+	if query.Limit > 0 {
+		findOptions.SetLimit(int64(query.Limit))
+	}
+	return findOptions
+}
+
+// ListProducts получает страницу продуктов из MongoDB; фильтр, сортировка и пропуск выполняются на сервере
+func (m *MongoDBClient) ListProducts(query ProductQuery) (ProductPage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := mongoFilter(query)
+
+	total, err := m.Collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return ProductPage{}, err
+	}
+
+	cursor, err := m.Collection.Find(ctx, filter, mongoFindOptions(query))
+	if err != nil {
+		return ProductPage{}, err
+	}
+	defer cursor.Close(ctx)
+
+	products := []models.Product{}
+	if err = cursor.All(ctx, &products); err != nil {
+		return ProductPage{}, err
+	}
+
+	return ProductPage{Products: products, Total: total}, nil
+}
+
+// SearchProducts выполняет полнотекстовый поиск по текстовому индексу MongoDB.
+// Результаты упорядочены по убыванию релевантности (textScore)
+func (m *MongoDBClient) SearchProducts(text string, limit int) ([]SearchResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	score := bson.M{"$meta": "textScore"}
+	opts := options.Find().
+		SetProjection(bson.M{"score": score}).
+		SetSort(bson.D{{Key: "score", Value: score}, {Key: "id", Value: 1}}).
+		SetLimit(int64(limit))
+
+	cursor, err := m.Collection.Find(ctx, bson.M{"$text": bson.M{"$search": text}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	results := []SearchResult{}
+	for cursor.Next(ctx) {
+		var document struct {
+			models.Product `bson:",inline"`
+			Score          float64 `bson:"score"`
+		}
+		if err := cursor.Decode(&document); err != nil {
+			return nil, err
+		}
+		results = append(results, SearchResult{Product: document.Product, Score: document.Score})
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// AddProduct добавляет продукт в MongoDB. Продукт без ID получает следующее значение счетчика,
+// занятость ID проверяет уникальный индекс по полю id
+func (m *MongoDBClient) AddProduct(product models.Product) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	generated := product.ID == 0
+	product.Version = 1
+
+	for attempt := 1; ; attempt++ {
+		if generated {
+			id, err := m.nextID(ctx)
+			if err != nil {
+				return 0, err
+			}
+			product.ID = id
+		}
+
+		_, err := m.Collection.InsertOne(ctx, product)
+		if mongo.IsDuplicateKeyError(err) {
+			if generated && attempt < generatedIDAttempts {
+				continue
+			}
+			return 0, &DuplicateError{ID: product.ID}
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		if !generated {
+			// Ошибка не критична: при совпадении назначенного ID вставка повторяется
+			m.raiseCounter(ctx, product.ID)
+		}
+		return product.ID, nil
+	}
+}
+
+// versionFilter фильтр документа товара с учетом ожидаемой версии (0 - без проверки)
+func versionFilter(id int, version int64) bson.M {
+	filter := bson.M{"id": id}
+	if version != 0 {
+		filter["version"] = version
+	}
+	return filter
+}
+
+// missingOrConflict определяет, почему фильтр с версией не нашел документ: товара нет или версия другая
+func (m *MongoDBClient) missingOrConflict(id int, version int64) error {
+	current, exists, err := m.GetProduct(id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("продукт с ID %d не найден", id)
+	}
+	return &VersionConflictError{ID: id, Expected: version, Current: current.Version}
+}
+
+// UpdateProduct обновляет продукт в MongoDB, проверяя версию (0 - без проверки)
+func (m *MongoDBClient) UpdateProduct(product models.Product, version int64) (int64, error) {
+	return m.PatchProduct(product, patchFields, version)
+}
+
+// PatchProduct изменяет поля fields продукта в MongoDB, проверяя версию (0 - без проверки).
+// Проверка и увеличение версии выполняются одной атомарной операцией над документом
+func (m *MongoDBClient) PatchProduct(product models.Product, fields []string, version int64) (int64, error) {
+	if err := checkFields(fields); err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	set := bson.M{}
+	for _, field := range fields {
+		set[mongoFields[field]] = productValue(product, field)
+	}
+	update := bson.M{"$set": set, "$inc": bson.M{"version": 1}}
+	opts := options.FindOneAndUpdate().
+		SetReturnDocument(options.After).
+		SetProjection(bson.M{"version": 1})
+
+	var updated struct {
+		Version int64 `bson:"version"`
+	}
+	err := m.Collection.FindOneAndUpdate(ctx, versionFilter(product.ID, version), update, opts).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		return 0, m.missingOrConflict(product.ID, version)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return updated.Version, nil
+}
+
+// DeleteProduct удаляет продукт из MongoDB, проверяя версию (0 - без проверки)
+func (m *MongoDBClient) DeleteProduct(id int, version int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := m.Collection.DeleteOne(ctx, versionFilter(id, version))
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return m.missingOrConflict(id, version)
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"fmt"
+
+	"project/internal/models"
+)
+
+// patchFields поля товара, которые можно изменить частично. Имена совпадают с JSON-полями товара
+// и со столбцами таблицы products. id и version не изменяются
+var patchFields = []string{"name", "category", "price", "description", "in_stock", "supplier"}
+
+// productValue значение поля товара
+func productValue(product models.Product, field string) any {
+	switch field {
+	case "name":
+		return product.Name
+	case "category":
+		return product.Category
+	case "price":
+		return product.Price
+	case "description":
+		return product.Description
+	case "in_stock":
+		return product.InStock
+	case "supplier":
+		return product.Supplier
+	}
+	return nil
+}
+
+// CopyFields переносит значения полей fields из src в dst
+func CopyFields(dst *models.Product, src models.Product, fields []string) {
+	for _, field := range fields {
+		switch field {
+		case "name":
+			dst.Name = src.Name
+		case "category":
+			dst.Category = src.Category
+		case "price":
+			dst.Price = src.Price
+		case "description":
+			dst.Description = src.Description
+		case "in_stock":
+			dst.InStock = src.InStock
+		case "supplier":
+			dst.Supplier = src.Supplier
+		}
+	}
+}
+
+// checkFields проверяет, что все поля можно изменить частично
+func checkFields(fields []string) error {
+	if len(fields) == 0 {
+		return fmt.Errorf("не указаны изменяемые поля")
+	}
+	for _, field := range fields {
+		if productValue(models.Product{}, field) == nil {
+			return fmt.Errorf("поле %q нельзя изменить", field)
+		}
+	}
+	return nil
+}
+
+// ChangedFields возвращает поля, значения которых у товаров a и b различаются
+func ChangedFields(a, b models.Product) []string {
+	var fields []string
+	for _, field := range patchFields {
+		if productValue(a, field) != productValue(b, field) {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"project/internal/models"
+)
+
+// SortField поле сортировки списка товаров
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// ProductQuery параметры выборки списка товаров: фильтры, сортировка и страница
+type ProductQuery struct {
+	// Фильтры (пустое значение или nil - фильтр не применяется)
+	Category string
+	Supplier string
+	InStock  *bool
+	MinPrice *float64
+	MaxPrice *float64
+	// IDs только товары с этими ID (используется импортом для поиска существующих товаров)
+	IDs []int
+
+	// Sort порядок сортировки; при равенстве значений товары упорядочиваются по ID
+	Sort []SortField
+
+	// Limit размер страницы (0 - без ограничения), Offset - количество пропускаемых товаров
+	Limit  int
+	Offset int
+}
+
+// ProductPage страница списка товаров
+type ProductPage struct {
+	Products []models.Product
+	// Total количество товаров, удовлетворяющих фильтрам, без учета страницы
+	Total int64
+}
+
+// sortFields поля, по которым допускается сортировка
+var sortFields = map[string]bool{
+	"id":       true,
+	"name":     true,
+	"category": true,
+	"price":    true,
+	"in_stock": true,
+	"supplier": true,
+}
+
+// ParseSort разбирает порядок сортировки вида "price,-name" ("-" - по убыванию)
+func ParseSort(spec string) ([]SortField, error) {
+	var fields []SortField
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		field := SortField{Field: part}
+		if strings.HasPrefix(part, "-") {
+			field = SortField{Field: part[1:], Desc: true}
+		}
+		if !sortFields[field.Field] {
+			return nil, fmt.Errorf("сортировка по полю %q не поддерживается", field.Field)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// withIDTiebreak дополняет сортировку полем id, чтобы порядок страниц был детерминированным
+func (q ProductQuery) withIDTiebreak() []SortField {
+	for _, field := range q.Sort {
+		if field.Field == "id" {
+			return q.Sort
+		}
+	}
+	return append(append([]SortField(nil), q.Sort...), SortField{Field: "id"})
+}
+
+// sortsByString проверяет, есть ли в сортировке строковые поля: базы сравнивают строки по своим правилам (collation)
+func (q ProductQuery) sortsByString() bool {
+	return slices.ContainsFunc(q.Sort, func(field SortField) bool {
+		return field.Field == "name" || field.Field == "category" || field.Field == "supplier"
+	})
+}
+
+// Matches проверяет, удовлетворяет ли товар фильтрам запроса
+func (q ProductQuery) Matches(product models.Product) bool {
+	if q.Category != "" && product.Category != q.Category {
+		return false
+	}
+	if q.Supplier != "" && product.Supplier != q.Supplier {
+		return false
+	}
+	if q.InStock != nil && product.InStock != *q.InStock {
+		return false
+	}
+	if q.MinPrice != nil && product.Price < *q.MinPrice {
+		return false
+	}
+	if q.MaxPrice != nil && product.Price > *q.MaxPrice {
+		return false
+	}
+	if len(q.IDs) > 0 && !slices.Contains(q.IDs, product.ID) {
+		return false
+	}
+	return true
+}
+
+// compareProducts сравнивает два товара по одному полю сортировки
+func compareProducts(a, b models.Product, field string) int {
+	switch field {
+	case "name":
+		return strings.Compare(a.Name, b.Name)
+	case "category":
+		return strings.Compare(a.Category, b.Category)
+	case "supplier":
+		return strings.Compare(a.Supplier, b.Supplier)
+	case "price":
+		switch {
+		case a.Price < b.Price:
+			return -1
+		case a.Price > b.Price:
+			return 1
+		}
+		return 0
+	case "in_stock":
+		switch {
+		case a.InStock == b.InStock:
+			return 0
+		case !a.InStock:
+			return -1
+		}
+		return 1
+	}
+	return a.ID - b.ID
+}
+
+// lessProducts сообщает, должен ли товар a идти раньше товара b при заданном порядке сортировки
+func lessProducts(order []SortField, a, b models.Product) bool {
+	for _, field := range order {
+		c := compareProducts(a, b, field.Field)
+		if c == 0 {
+			continue
+		}
+		if field.Desc {
+			return c > 0
+		}
+		return c < 0
+	}
+	return false
+}
+
+// Apply фильтрует, сортирует и разбивает на страницы список товаров в памяти.
+// Используется хранилищами, которые не умеют выполнять запрос на стороне базы
+func (q ProductQuery) Apply(products []models.Product) ProductPage {
+	filtered := make([]models.Product, 0, len(products))
+	for _, product := range products {
+		if q.Matches(product) {
+			filtered = append(filtered, product)
+		}
+	}
+
+	order := q.withIDTiebreak()
+	sort.SliceStable(filtered, func(i, j int) bool {
+		return lessProducts(order, filtered[i], filtered[j])
+	})
+
+	page := ProductPage{Total: int64(len(filtered))}
+	if q.Offset >= len(filtered) {
+		page.Products = []models.Product{}
+		return page
+	}
+	filtered = filtered[q.Offset:]
+	if q.Limit > 0 && q.Limit < len(filtered) {
+		filtered = filtered[:q.Limit]
+	}
+	page.Products = filtered
+	return page
+}
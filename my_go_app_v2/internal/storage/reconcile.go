@@ -0,0 +1,300 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"project/internal/models"
+)
+
+// Ключи сопоставления товаров при сверке баз данных
+const (
+	ReconcileByID           = "id"
+	ReconcileByNameSupplier = "name_supplier"
+)
+
+// Типы расхождений в отчете сверки
+const (
+	// IssueMissing запись есть не во всех базах
+	IssueMissing = "missing"
+	// IssueDifference запись описывает тот же товар, но значения отдельных полей различаются
+	IssueDifference = "difference"
+	// IssueConflict под одним ключом хранятся разные товары: при сверке по id различаются
+	// название или поставщик, при сверке по названию и поставщику - идентификаторы
+	IssueConflict = "conflict"
+)
+
+// reconcileTimeout максимальное время получения товаров из одной базы при сверке
+const reconcileTimeout = 30 * time.Second
+
+// ReconcileOptions параметры сверки
+type ReconcileOptions struct {
+	// Key ключ сопоставления: id (по умолчанию) или name_supplier
+	Key string
+	// Databases базы, участвующие в сверке; по умолчанию все зарегистрированные
+	Databases []string
+}
+
+// FieldDifference значения одного поля товара в разных базах (имя базы -> значение)
+type FieldDifference struct {
+	Field  string            `json:"field"`
+	Values map[string]string `json:"values"`
+}
+
+// ReconcileIssue расхождение между базами для одного ключа сопоставления
+type ReconcileIssue struct {
+	Key         string            `json:"key"`
+	Type        string            `json:"type"`
+	PresentIn   []string          `json:"present_in"`
+	MissingIn   []string          `json:"missing_in,omitempty"`
+	Differences []FieldDifference `json:"differences,omitempty"`
+}
+
+// ReconcileReport результат сверки товаров между базами данных
+type ReconcileReport struct {
+	GeneratedAt time.Time        `json:"generated_at"`
+	Key         string           `json:"key"`
+	Databases   []string         `json:"databases"`
+	Checked     int              `json:"checked"`
+	Issues      []ReconcileIssue `json:"issues"`
+	Errors      []SourceError    `json:"errors"`
+}
+
+// reconcileFields поля товара, сравниваемые при сверке, в порядке вывода
+var reconcileFields = []struct {
+	name  string
+	value func(models.Product) string
+}{
+	{"id", func(p models.Product) string { return strconv.Itoa(p.ID) }},
+	{"name", func(p models.Product) string { return p.Name }},
+	{"category", func(p models.Product) string { return p.Category }},
+	{"price", func(p models.Product) string { return strconv.FormatFloat(p.Price, 'f', -1, 64) }},
+	{"description", func(p models.Product) string { return p.Description }},
+	{"in_stock", func(p models.Product) string { return strconv.FormatBool(p.InStock) }},
+	{"supplier", func(p models.Product) string { return p.Supplier }},
+}
+
+// reconcileKey ключ сопоставления товара
+func reconcileKey(key string, product models.Product) string {
+	if key == ReconcileByNameSupplier {
+		return strings.ToLower(strings.TrimSpace(product.Name)) + " / " + strings.ToLower(strings.TrimSpace(product.Supplier))
+	}
+	return strconv.Itoa(product.ID)
+}
+
+// identityFields поля, различие в которых означает конфликт, а не расхождение данных
+func identityFields(key string) map[string]bool {
+	if key == ReconcileByNameSupplier {
+		return map[string]bool{"id": true}
+	}
+	return map[string]bool{"name": true, "supplier": true}
+}
+
+// Reconcile сравнивает товары в базах данных по ключу сопоставления и сообщает
+// об отсутствующих записях, различиях в полях и конфликтах.
+// Недоступные базы исключаются из сравнения и перечисляются в Errors
+func (m *DBManager) Reconcile(ctx context.Context, opts ReconcileOptions) (ReconcileReport, error) {
+	if opts.Key == "" {
+		opts.Key = ReconcileByID
+	}
+	if opts.Key != ReconcileByID && opts.Key != ReconcileByNameSupplier {
+		return ReconcileReport{}, fmt.Errorf("неизвестный ключ сверки %q (допустимо: %s, %s)", opts.Key, ReconcileByID, ReconcileByNameSupplier)
+	}
+
+	names := opts.Databases
+	if len(names) == 0 {
+		names = m.names
+	}
+	for _, name := range names {
+		if _, ok := m.backends[name]; !ok {
+			return ReconcileReport{}, fmt.Errorf("база данных %s не найдена", name)
+		}
+	}
+
+	// Получаем все товары из каждой базы параллельно
+	results := make([]sourcePage, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(result *sourcePage, b *backend) {
+			defer wg.Done()
+			*result = listWithTimeout(ctx, b, ProductQuery{}, reconcileTimeout)
+		}(&results[i], m.backends[name])
+	}
+	wg.Wait()
+
+	report := ReconcileReport{
+		GeneratedAt: time.Now().UTC(),
+		Key:         opts.Key,
+		Databases:   []string{},
+		Issues:      []ReconcileIssue{},
+		Errors:      []SourceError{},
+	}
+
+	// Товары по ключу и базе; в одной базе под одним ключом может оказаться несколько товаров
+	records := make(map[string]map[string][]models.Product)
+	for i, name := range names {
+		if results[i].err != nil {
+			report.Errors = append(report.Errors, SourceError{Database: name, Error: results[i].err.Error()})
+			continue
+		}
+		report.Databases = append(report.Databases, name)
+		for _, product := range results[i].page.Products {
+			key := reconcileKey(opts.Key, product)
+			if records[key] == nil {
+				records[key] = make(map[string][]models.Product)
+			}
+			records[key][name] = append(records[key][name], product)
+		}
+	}
+
+	keys := make([]string, 0, len(records))
+	for key := range records {
+		keys = append(keys, key)
+	}
+	sortReconcileKeys(opts.Key, keys)
+
+	report.Checked = len(keys)
+	identity := identityFields(opts.Key)
+	for _, key := range keys {
+		report.Issues = append(report.Issues, compareRecords(key, report.Databases, records[key], identity)...)
+	}
+
+	return report, nil
+}
+
+// sortReconcileKeys упорядочивает ключи: идентификаторы - численно, остальные - лексикографически
+func sortReconcileKeys(key string, keys []string) {
+	sort.Slice(keys, func(i, j int) bool {
+		if key == ReconcileByID {
+			a, _ := strconv.Atoi(keys[i])
+			b, _ := strconv.Atoi(keys[j])
+			return a < b
+		}
+		return keys[i] < keys[j]
+	})
+}
+
+// compareRecords сравнивает записи одного ключа в разных базах
+func compareRecords(key string, databases []string, byDatabase map[string][]models.Product, identity map[string]bool) []ReconcileIssue {
+	var issues []ReconcileIssue
+
+	present := []string{}
+	missing := []string{}
+	for _, name := range databases {
+		if len(byDatabase[name]) > 0 {
+			present = append(present, name)
+		} else {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) > 0 {
+		issues = append(issues, ReconcileIssue{Key: key, Type: IssueMissing, PresentIn: present, MissingIn: missing})
+	}
+
+	// Сравниваем значения полей; несколько товаров одной базы под одним ключом
+	// различаются по суффиксу #n в имени базы
+	values := make(map[string]map[string]string)
+	for _, field := range reconcileFields {
+		values[field.name] = make(map[string]string)
+	}
+	for _, name := range present {
+		products := byDatabase[name]
+		for i, product := range products {
+			source := name
+			if len(products) > 1 {
+				source = fmt.Sprintf("%s#%d", name, i+1)
+			}
+			for _, field := range reconcileFields {
+				values[field.name][source] = field.value(product)
+			}
+		}
+	}
+
+	var conflicts, differences []FieldDifference
+	for _, field := range reconcileFields {
+		if !differ(values[field.name]) {
+			continue
+		}
+		difference := FieldDifference{Field: field.name, Values: values[field.name]}
+		if identity[field.name] {
+			conflicts = append(conflicts, difference)
+		} else {
+			differences = append(differences, difference)
+		}
+	}
+
+	switch {
+	case len(conflicts) > 0:
+		issues = append(issues, ReconcileIssue{
+			Key:         key,
+			Type:        IssueConflict,
+			PresentIn:   present,
+			Differences: append(conflicts, differences...),
+		})
+	case len(differences) > 0:
+		issues = append(issues, ReconcileIssue{Key: key, Type: IssueDifference, PresentIn: present, Differences: differences})
+	}
+
+	return issues
+}
+
+// differ сообщает, есть ли среди значений различающиеся
+func differ(values map[string]string) bool {
+	first := true
+	var value string
+	for _, v := range values {
+		if first {
+			value, first = v, false
+			continue
+		}
+		if v != value {
+			return true
+		}
+	}
+	return false
+}
+
+// StartReconciliation запускает периодическую сверку баз данных в фоне.
+// Последний отчет доступен через LastReconcileReport; фоновая сверка останавливается в Close
+func (m *DBManager) StartReconciliation(interval time.Duration, opts ReconcileOptions) {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			report, err := m.Reconcile(m.ctx, opts)
+			if err != nil {
+				log.Printf("Ошибка сверки баз данных: %v", err)
+			} else {
+				log.Printf("Сверка баз данных завершена: проверено ключей %d, расхождений %d", report.Checked, len(report.Issues))
+				m.reconcileMu.Lock()
+				m.lastReport = &report
+				m.reconcileMu.Unlock()
+			}
+
+			select {
+			case <-m.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// LastReconcileReport возвращает отчет последней фоновой сверки (nil, если сверка еще не выполнялась)
+func (m *DBManager) LastReconcileReport() *ReconcileReport {
+	m.reconcileMu.Lock()
+	defer m.reconcileMu.Unlock()
+	return m.lastReport
+}
@@ -0,0 +1,326 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"project/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Режимы проверки документов валидатором коллекции MongoDB (опция validation)
+const (
+	// validationOff валидатор не устанавливается, установленный ранее снимается
+	validationOff = "off"
+	// validationWarn MongoDB принимает документы с нарушениями схемы и записывает их в свой журнал (по умолчанию)
+	validationWarn = "warn"
+	// validationStrict MongoDB отклоняет документы с нарушениями схемы
+	validationStrict = "strict"
+)
+
+// checkValidation проверяет значение опции validation
+func checkValidation(mode string) error {
+	if mode != validationOff && mode != validationWarn && mode != validationStrict {
+		return fmt.Errorf("недопустимое значение опции validation %q: ожидается off, warn или strict", mode)
+	}
+	return nil
+}
+
+// violationLogLimit количество документов с нарушениями, которые перечисляются в журнале при запуске
+const violationLogLimit = 10
+
+// schemaConstraint ограничение поля из тега schema: ключевое слово $jsonSchema и значение
+type schemaConstraint struct {
+	keyword string
+	value   float64
+}
+
+// schemaField поле документа товара и его ограничения
+type schemaField struct {
+	// name имя поля документа
+	name        string
+	bsonTypes   []string
+	required    bool
+	constraints []schemaConstraint
+}
+
+// productSchema поля документа товара, построенные по модели models.Product
+var productSchema = mustSchemaFields(reflect.TypeOf(models.Product{}))
+
+// mustSchemaFields строит описание полей документа по полям структуры и их тегам schema.
+// Ошибка в теге - ошибка программы, поэтому она приводит к панике при запуске
+func mustSchemaFields(t reflect.Type) []schemaField {
+	fields := make([]schemaField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+
+		// Документы сохраняются без bson-тегов, и драйвер приводит имена полей к нижнему регистру (см. mongoFields)
+		field := schemaField{name: strings.ToLower(structField.Name)}
+		switch structField.Type.Kind() {
+		case reflect.Int, reflect.Int32, reflect.Int64:
+			// Драйвер записывает int как int32, если значение помещается в него
+			field.bsonTypes = []string{"int", "long"}
+		case reflect.Float32, reflect.Float64:
+			field.bsonTypes = []string{"double", "int", "long"}
+		case reflect.String:
+			field.bsonTypes = []string{"string"}
+		case reflect.Bool:
+			field.bsonTypes = []string{"bool"}
+		default:
+			panic(fmt.Sprintf("поле %s: тип %s не поддерживается схемой", structField.Name, structField.Type))
+		}
+
+		tag := structField.Tag.Get("schema")
+		for _, option := range strings.Split(tag, ",") {
+			keyword, value, hasValue := strings.Cut(option, "=")
+			switch {
+			case option == "":
+			case keyword == "required" && !hasValue:
+				field.required = true
+			case keyword == "minLength" || keyword == "maxLength" || keyword == "minimum" || keyword == "maximum":
+				number, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					panic(fmt.Sprintf("поле %s: неверное значение %s в теге schema", structField.Name, option))
+				}
+				field.constraints = append(field.constraints, schemaConstraint{keyword: keyword, value: number})
+			default:
+				panic(fmt.Sprintf("поле %s: неизвестное ограничение %q в теге schema", structField.Name, option))
+			}
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// productJSONSchema валидатор $jsonSchema документа товара. Поля, которых нет в модели (например, _id),
+// не ограничиваются
+func productJSONSchema() bson.M {
+	properties := bson.M{}
+	required := bson.A{}
+	for _, field := range productSchema {
+		property := bson.M{"bsonType": field.bsonTypes}
+		for _, constraint := range field.constraints {
+			if constraint.keyword == "minLength" || constraint.keyword == "maxLength" {
+				property[constraint.keyword] = int64(constraint.value)
+			} else {
+				property[constraint.keyword] = constraint.value
+			}
+		}
+		properties[field.name] = property
+		if field.required {
+			required = append(required, field.name)
+		}
+	}
+	return bson.M{
+		"bsonType":   "object",
+		"title":      "product",
+		"required":   required,
+		"properties": properties,
+	}
+}
+
+// bsonTypeName имя типа BSON для значения, прочитанного драйвером в bson.M
+func bsonTypeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case int32:
+		return "int"
+	case int64:
+		return "long"
+	case float64:
+		return "double"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case primitive.Decimal128:
+		return "decimal"
+	case primitive.ObjectID:
+		return "objectId"
+	case primitive.DateTime:
+		return "date"
+	case bson.M, bson.D:
+		return "object"
+	case bson.A:
+		return "array"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// schemaProblems перечисляет нарушения схемы товара в документе так же, как их проверяет $jsonSchema
+func schemaProblems(document bson.M) []string {
+	var problems []string
+	for _, field := range productSchema {
+		value, ok := document[field.name]
+		if !ok {
+			if field.required {
+				problems = append(problems, field.name+": поле отсутствует")
+			}
+			continue
+		}
+
+		typeName := bsonTypeName(value)
+		if !slices.Contains(field.bsonTypes, typeName) {
+			problems = append(problems, fmt.Sprintf("%s: тип %s, ожидается %s", field.name, typeName, strings.Join(field.bsonTypes, " или ")))
+			continue
+		}
+
+		for _, constraint := range field.constraints {
+			var actual float64
+			switch constraint.keyword {
+			case "minLength", "maxLength":
+				actual = float64(utf8.RuneCountInString(value.(string)))
+			default:
+				actual = numberValue(value)
+			}
+
+			limit := strconv.FormatFloat(constraint.value, 'f', -1, 64)
+			switch {
+			case constraint.keyword == "minLength" && actual < constraint.value:
+				problems = append(problems, fmt.Sprintf("%s: длина %v меньше %s", field.name, actual, limit))
+			case constraint.keyword == "maxLength" && actual > constraint.value:
+				problems = append(problems, fmt.Sprintf("%s: длина %v больше %s", field.name, actual, limit))
+			case constraint.keyword == "minimum" && actual < constraint.value:
+				problems = append(problems, fmt.Sprintf("%s: значение %v меньше %s", field.name, value, limit))
+			case constraint.keyword == "maximum" && actual > constraint.value:
+				problems = append(problems, fmt.Sprintf("%s: значение %v больше %s", field.name, value, limit))
+			}
+		}
+	}
+	return problems
+}
+
+// numberValue числовое значение BSON
+func numberValue(value any) float64 {
+	switch number := value.(type) {
+	case int32:
+		return float64(number)
+	case int64:
+		return float64(number)
+	case float64:
+		return number
+	}
+	return 0
+}
+
+// SchemaViolation документ коллекции, который не соответствует схеме товара
+type SchemaViolation struct {
+	// Document идентификатор документа: ID товара или, если его нет, _id
+	Document string
+	Problems []string
+}
+
+func (v SchemaViolation) String() string {
+	return v.Document + ": " + strings.Join(v.Problems, "; ")
+}
+
+// SchemaChecker хранилище, которое проверяет сохраненные документы по схеме товара
+type SchemaChecker interface {
+	// SchemaViolations возвращает до limit (0 - без ограничения) документов с нарушениями схемы
+	// и общее количество таких документов
+	SchemaViolations(ctx context.Context, limit int) ([]SchemaViolation, int64, error)
+}
+
+var _ SchemaChecker = (*MongoDBClient)(nil)
+
+// SchemaViolations находит документы коллекции товаров, которые не прошли бы проверку валидатором
+func (m *MongoDBClient) SchemaViolations(ctx context.Context, limit int) ([]SchemaViolation, int64, error) {
+	filter := bson.M{"$nor": bson.A{bson.M{"$jsonSchema": productJSONSchema()}}}
+	total, err := m.Collection.CountDocuments(ctx, filter)
+	if err != nil || total == 0 {
+		return nil, total, err
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "id", Value: 1}}).SetLimit(int64(limit))
+	cursor, err := m.Collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var violations []SchemaViolation
+	for cursor.Next(ctx) {
+		var document bson.M
+		if err := cursor.Decode(&document); err != nil {
+			return nil, 0, err
+		}
+
+		violation := SchemaViolation{Problems: schemaProblems(document)}
+		if id, ok := document["id"]; ok {
+			violation.Document = fmt.Sprintf("id %v", id)
+		} else if objectID, ok := document["_id"].(primitive.ObjectID); ok {
+			violation.Document = "_id " + objectID.Hex()
+		} else {
+			violation.Document = fmt.Sprintf("_id %v", document["_id"])
+		}
+		if len(violation.Problems) == 0 {
+			violation.Problems = []string{"документ не соответствует схеме"}
+		}
+		violations = append(violations, violation)
+	}
+	return violations, total, cursor.Err()
+}
+
+// applyValidator устанавливает валидатор коллекции товаров командой collMod. Валидатор строится
+// по модели заново при каждом применении миграций, поэтому изменения модели попадают в него сами.
+// Строгий режим включается, только если все документы коллекции соответствуют схеме; иначе
+// валидатор остается в режиме предупреждений, а документы с нарушениями перечисляются в журнале
+func (m *MongoDBClient) applyValidator(ctx context.Context) error {
+	command := bson.D{{Key: "collMod", Value: m.Collection.Name()}}
+
+	if m.validation == validationOff {
+		command = append(command, bson.E{Key: "validator", Value: bson.M{}}, bson.E{Key: "validationLevel", Value: "off"})
+	} else {
+		action := "warn"
+		if m.validation == validationStrict {
+			violations, total, err := m.SchemaViolations(ctx, violationLogLimit)
+			if err != nil {
+				return err
+			}
+			if total == 0 {
+				action = "error"
+			} else {
+				log.Printf("Коллекция %s.%s: документов, не соответствующих схеме товара, - %d. Строгая проверка не включена, "+
+					"валидатор работает в режиме предупреждений (полный список: server migrate validate)",
+					m.Database.Name(), m.Collection.Name(), total)
+				for _, violation := range violations {
+					log.Printf("  %s", violation)
+				}
+			}
+		}
+		command = append(command,
+			bson.E{Key: "validator", Value: bson.M{"$jsonSchema": productJSONSchema()}},
+			bson.E{Key: "validationLevel", Value: "strict"},
+			bson.E{Key: "validationAction", Value: action})
+	}
+
+	err := m.Database.RunCommand(ctx, command).Err()
+	var commandErr mongo.CommandError
+	// 26 - NamespaceNotFound: коллекция еще не создана
+	if errors.As(err, &commandErr) && commandErr.Code == 26 {
+		if err := m.Database.CreateCollection(ctx, m.Collection.Name()); err != nil {
+			return err
+		}
+		err = m.Database.RunCommand(ctx, command).Err()
+	}
+	return err
+}
+
+// IsSchemaViolation сообщает, что MongoDB отклонила документ, не соответствующий валидатору коллекции
+func IsSchemaViolation(err error) bool {
+	var serverErr mongo.ServerError
+	// 121 - DocumentValidationFailure
+	return errors.As(err, &serverErr) && serverErr.HasErrorCode(121)
+}
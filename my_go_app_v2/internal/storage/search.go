@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"project/internal/models"
+)
+
+// SearchResult товар, найденный полнотекстовым поиском, с оценкой релевантности
+type SearchResult struct {
+	models.Product
+	Score float64 `json:"score"`
+}
+
+// searchTerms разбивает поисковый запрос на слова в нижнем регистре
+func searchTerms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// ----- Поиск в памяти -----
+
+// Веса совпадений в названии и описании товара
+const (
+	nameWeight        = 3
+	descriptionWeight = 1
+)
+
+// termScore оценивает совпадение слова запроса со словами поля (совпадение по префиксу слова)
+func termScore(term string, words []string) float64 {
+	var score float64
+	for _, word := range words {
+		if strings.HasPrefix(word, term) {
+			score++
+		}
+	}
+	return score
+}
+
+// SearchProducts ищет продукты в памяти по словам названия и описания.
+// Релевантность - взвешенное количество слов товара, начинающихся со слов запроса
+func (m *MemoryClient) SearchProducts(text string, limit int) ([]SearchResult, error) {
+	terms := searchTerms(text)
+
+	m.mu.RLock()
+	results := []SearchResult{}
+	for _, product := range m.products {
+		nameWords := searchTerms(product.Name)
+		descriptionWords := searchTerms(product.Description)
+
+		var score float64
+		for _, term := range terms {
+			score += nameWeight*termScore(term, nameWords) + descriptionWeight*termScore(term, descriptionWords)
+		}
+		if score > 0 {
+			results = append(results, SearchResult{Product: product, Score: score})
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID < results[j].ID
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+// ----- Поиск в SQL-базах -----
+
+// postgresSearchVector документ полнотекстового поиска PostgreSQL с русской конфигурацией:
+// название весомее описания. Выражение совпадает с выражением индекса products_search_idx
+const postgresSearchVector = `(setweight(to_tsvector('russian', name), 'A') || setweight(to_tsvector('russian', coalesce(description, '')), 'B'))`
+
+// createSearchIndex создает индекс полнотекстового поиска, соответствующий диалекту
+func (s *sqlStore) createSearchIndex(q sqlQuerier) error {
+	switch s.dialect {
+	case postgresDialect:
+		_, err := q.Exec(`CREATE INDEX IF NOT EXISTS products_search_idx ON products USING GIN (` + postgresSearchVector + `)`)
+		return err
+
+	case mysqlDialect:
+		// В MySQL нет CREATE INDEX IF NOT EXISTS, поэтому наличие индекса проверяется отдельно
+		var count int
+		err := q.QueryRow(`SELECT COUNT(*) FROM information_schema.statistics
+			WHERE table_schema = DATABASE() AND table_name = 'products' AND index_name = 'products_search_idx'`).Scan(&count)
+		if err != nil || count > 0 {
+			return err
+		}
+		_, err = q.Exec(`ALTER TABLE products ADD FULLTEXT INDEX products_search_idx (name, description)`)
+		return err
+
+	case sqliteDialect:
+		// Внешняя FTS5-таблица над products, синхронизируемая триггерами
+		statements := []string{
+			`CREATE VIRTUAL TABLE IF NOT EXISTS products_fts USING fts5(
+				name, description, content='products', content_rowid='id', tokenize='unicode61 remove_diacritics 2'
+			)`,
+			`CREATE TRIGGER IF NOT EXISTS products_fts_insert AFTER INSERT ON products BEGIN
+				INSERT INTO products_fts(rowid, name, description) VALUES (new.id, new.name, new.description);
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS products_fts_delete AFTER DELETE ON products BEGIN
+				INSERT INTO products_fts(products_fts, rowid, name, description) VALUES ('delete', old.id, old.name, old.description);
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS products_fts_update AFTER UPDATE ON products BEGIN
+				INSERT INTO products_fts(products_fts, rowid, name, description) VALUES ('delete', old.id, old.name, old.description);
+				INSERT INTO products_fts(rowid, name, description) VALUES (new.id, new.name, new.description);
+			END`,
+			// Перестраиваем индекс, чтобы учесть строки, добавленные до появления триггеров
+			`INSERT INTO products_fts(products_fts) VALUES ('rebuild')`,
+		}
+		for _, statement := range statements {
+			if _, err := q.Exec(statement); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sqliteMatchQuery преобразует запрос в выражение FTS5: каждое слово в кавычках с поиском по префиксу,
+// чтобы служебные символы FTS5 во вводе пользователя не нарушали синтаксис
+func sqliteMatchQuery(terms []string) string {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = `"` + term + `"*`
+	}
+	return strings.Join(quoted, " OR ")
+}
+
+// SearchProducts выполняет полнотекстовый поиск средствами базы данных:
+// tsvector с русской конфигурацией в PostgreSQL, FULLTEXT в MySQL, FTS5 в SQLite.
+// Результаты упорядочены по убыванию релевантности
+func (s *sqlStore) SearchProducts(text string, limit int) ([]SearchResult, error) {
+	var query string
+	var args []any
+
+	switch s.dialect {
+	case postgresDialect:
+		query = `SELECT ` + productColumns + `, ts_rank(` + postgresSearchVector + `, websearch_to_tsquery('russian', ?)) AS score
+			FROM products WHERE ` + postgresSearchVector + ` @@ websearch_to_tsquery('russian', ?)
+			ORDER BY score DESC, id LIMIT ?`
+		args = []any{text, text, limit}
+
+	case mysqlDialect:
+		query = `SELECT ` + productColumns + `, MATCH(name, description) AGAINST (? IN NATURAL LANGUAGE MODE) AS score
+			FROM products WHERE MATCH(name, description) AGAINST (? IN NATURAL LANGUAGE MODE)
+			ORDER BY score DESC, id LIMIT ?`
+		args = []any{text, text, limit}
+
+	case sqliteDialect:
+		terms := searchTerms(text)
+		if len(terms) == 0 {
+			return []SearchResult{}, nil
+		}
+		// bm25 возвращает тем меньшее значение, чем выше релевантность
+		query = `SELECT p.id, p.name, p.category, p.price, p.description, p.in_stock, p.supplier, p.version,
+				-bm25(products_fts, 3.0, 1.0) AS score
+			FROM products_fts JOIN products p ON p.id = products_fts.rowid
+			WHERE products_fts MATCH ?
+			ORDER BY score DESC, p.id LIMIT ?`
+		args = []any{sqliteMatchQuery(terms), limit}
+	}
+
+	rows, err := s.DB.Query(s.dialect.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+	for rows.Next() {
+		var result SearchResult
+		err := rows.Scan(&result.ID, &result.Name, &result.Category, &result.Price,
+			&result.Description, &result.InStock, &result.Supplier, &result.Version, &result.Score)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
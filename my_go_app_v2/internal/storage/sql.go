@@ -0,0 +1,716 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"project/internal/config"
+	"project/internal/models"
+
+	// Драйвера для баз данных
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// sqlDialect описывает различия SQL-диалектов, с которыми работает sqlStore
+type sqlDialect struct {
+	name string
+	// numbered - параметры запроса нумеруются ($1, $2, ...), а не задаются знаком "?"
+	numbered bool
+	// idColumn определение столбца id: база назначает ID товарам, добавленным без него
+	idColumn string
+}
+
+var (
+	postgresDialect = sqlDialect{name: "postgres", numbered: true, idColumn: "id INT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY"}
+	mysqlDialect    = sqlDialect{name: "mysql", idColumn: "id INT AUTO_INCREMENT PRIMARY KEY"}
+	// AUTOINCREMENT не назначает повторно ID удаленных товаров (наибольший выданный ID хранится в sqlite_sequence)
+	sqliteDialect = sqlDialect{name: "sqlite", idColumn: "id INTEGER PRIMARY KEY AUTOINCREMENT"}
+)
+
+// rebind заменяет параметры "?" в запросе на формат, принятый в диалекте
+func (d sqlDialect) rebind(query string) string {
+	if !d.numbered {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// productsTableSchema общая схема таблицы products для всех SQL-баз (%s - определение столбца id)
+const productsTableSchema = `
+	CREATE TABLE IF NOT EXISTS products (
+		%s,
+		name VARCHAR(100) NOT NULL,
+		category VARCHAR(50) NOT NULL,
+		price DECIMAL(10, 2) NOT NULL,
+		description TEXT,
+		in_stock BOOLEAN NOT NULL DEFAULT FALSE,
+		supplier VARCHAR(100) NOT NULL,
+		version BIGINT NOT NULL DEFAULT 1
+	)
+`
+
+// productColumns список столбцов таблицы products в порядке полей models.Product
+const productColumns = `id, name, category, price, description, in_stock, supplier, version`
+
+// sqlStore общая реализация ProductStore для SQL-баз данных
+type sqlStore struct {
+	DB      *sql.DB
+	dialect sqlDialect
+}
+
+// PostgresClient клиент для PostgreSQL
+type PostgresClient struct {
+	sqlStore
+}
+
+// MySQLClient клиент для MySQL
+type MySQLClient struct {
+	sqlStore
+}
+
+// SQLiteClient клиент для встроенной базы SQLite
+type SQLiteClient struct {
+	sqlStore
+}
+
+// postgresDSN собирает строку подключения к PostgreSQL из параметров конфигурации.
+// Параметр sslmode задается опцией sslmode (по умолчанию disable)
+func postgresDSN(cfg config.DatabaseConfig) string {
+	if cfg.DSN != "" {
+		return cfg.DSN
+	}
+
+	port := cfg.Port
+	if port == 0 {
+		port = 5432
+	}
+
+	// Значения экранируются и заключаются в кавычки, чтобы пароль мог содержать пробелы и кавычки
+	quote := func(value string) string {
+		value = strings.ReplaceAll(value, `\`, `\\`)
+		return "'" + strings.ReplaceAll(value, "'", `\'`) + "'"
+	}
+
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		quote(cfg.Host), port, quote(cfg.User), quote(cfg.Password), quote(cfg.Database),
+		quote(cfg.Option("sslmode", "disable")))
+}
+
+// mysqlDSN собирает строку подключения к MySQL из параметров конфигурации
+func mysqlDSN(cfg config.DatabaseConfig) string {
+	if cfg.DSN != "" {
+		return cfg.DSN
+	}
+
+	port := cfg.Port
+	if port == 0 {
+		port = 3306
+	}
+
+	mysqlCfg := mysql.Config{
+		User:                 cfg.User,
+		Passwd:               cfg.Password,
+		Net:                  "tcp",
+		Addr:                 net.JoinHostPort(cfg.Host, strconv.Itoa(port)),
+		DBName:               cfg.Database,
+		AllowNativePasswords: true,
+		ParseTime:            true,
+	}
+	return mysqlCfg.FormatDSN()
+}
+
+// Инициализация PostgreSQL
+func initPostgresDB(cfg config.DatabaseConfig) (*PostgresClient, error) {
+	store, err := openSQLStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &PostgresClient{store}, nil
+}
+
+// Инициализация MySQL
+func initMySQLDB(cfg config.DatabaseConfig) (*MySQLClient, error) {
+	store, err := openSQLStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &MySQLClient{store}, nil
+}
+
+// Инициализация SQLite (dsn - путь к файлу базы или ":memory:")
+func initSQLiteDB(cfg config.DatabaseConfig) (*SQLiteClient, error) {
+	store, err := openSQLStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLiteClient{store}, nil
+}
+
+// openSQLStore подключается к базе и приводит ее схему к текущей версии (см. migrateOnOpen)
+func openSQLStore(cfg config.DatabaseConfig) (sqlStore, error) {
+	store, err := connectSQL(cfg)
+	if err != nil {
+		return sqlStore{}, err
+	}
+	if err := migrateOnOpen(cfg, &store); err != nil {
+		store.Close()
+		return sqlStore{}, err
+	}
+	return store, nil
+}
+
+// connectSQL открывает пул соединений и проверяет подключение, не изменяя схему базы.
+// Параметры пула: max_open_conns, max_idle_conns, conn_max_lifetime
+func connectSQL(cfg config.DatabaseConfig) (sqlStore, error) {
+	var driverName, dsn string
+	var dialect sqlDialect
+	switch cfg.Driver {
+	case config.DriverPostgres:
+		driverName, dsn, dialect = "postgres", postgresDSN(cfg), postgresDialect
+	case config.DriverMySQL:
+		driverName, dsn, dialect = "mysql", mysqlDSN(cfg), mysqlDialect
+	case config.DriverSQLite:
+		// Для файловой базы создаем каталог и включаем ожидание блокировок и WAL-журнал
+		dsn = cfg.DSN
+		if dsn != ":memory:" {
+			if err := os.MkdirAll(filepath.Dir(dsn), 0o755); err != nil {
+				return sqlStore{}, err
+			}
+			dsn = "file:" + dsn + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+		}
+		driverName, dialect = "sqlite", sqliteDialect
+	default:
+		return sqlStore{}, fmt.Errorf("драйвер %s не является SQL-драйвером", cfg.Driver)
+	}
+
+	maxOpen, err := cfg.IntOption("max_open_conns", 0)
+	if err != nil {
+		return sqlStore{}, err
+	}
+	maxIdle, err := cfg.IntOption("max_idle_conns", 2)
+	if err != nil {
+		return sqlStore{}, err
+	}
+	lifetime, err := cfg.DurationOption("conn_max_lifetime", 0)
+	if err != nil {
+		return sqlStore{}, err
+	}
+
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return sqlStore{}, err
+	}
+	db.SetMaxOpenConns(maxOpen)
+	db.SetMaxIdleConns(maxIdle)
+	db.SetConnMaxLifetime(lifetime)
+
+	// SQLite допускает только одного писателя, а база ":memory:" существует в рамках одного соединения
+	if dialect == sqliteDialect {
+		db.SetMaxOpenConns(1)
+	}
+
+	// Проверка соединения
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return sqlStore{}, err
+	}
+
+	return sqlStore{DB: db, dialect: dialect}, nil
+}
+
+// Close закрывает пул соединений с базой
+func (s *sqlStore) Close() error {
+	return s.DB.Close()
+}
+
+// pool возвращает пул соединений для публикации его статистики в метриках
+func (s *sqlStore) pool() *sql.DB {
+	return s.DB
+}
+
+// Ping проверяет доступность базы данных
+func (s *sqlStore) Ping(ctx context.Context) error {
+	return s.DB.PingContext(ctx)
+}
+
+// createProductsTable создает таблицу products, если она не существует
+func (s *sqlStore) createProductsTable(q sqlQuerier) error {
+	_, err := q.Exec(fmt.Sprintf(productsTableSchema, s.dialect.idColumn))
+	return err
+}
+
+// postgresSyncSequence сдвигает последовательность ID PostgreSQL за наибольший занятый ID (но не назад)
+const postgresSyncSequence = `SELECT setval(pg_get_serial_sequence('products', 'id'), GREATEST(
+	(SELECT MAX(id) FROM products),
+	pg_sequence_last_value(pg_get_serial_sequence('products', 'id')::regclass)))`
+
+// addGeneratedIDs включает генерацию ID в таблице, созданной без нее: IDENTITY в PostgreSQL,
+// AUTO_INCREMENT в MySQL. В SQLite генерацию включает миграция autoincrement_ids
+func (s *sqlStore) addGeneratedIDs(q sqlQuerier) error {
+	switch s.dialect {
+	case postgresDialect:
+		var identity string
+		err := q.QueryRow(`SELECT is_identity FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = 'products' AND column_name = 'id'`).Scan(&identity)
+		if err != nil {
+			return err
+		}
+		if identity != "YES" {
+			if _, err := q.Exec(`ALTER TABLE products ALTER COLUMN id ADD GENERATED BY DEFAULT AS IDENTITY`); err != nil {
+				return err
+			}
+		}
+		// Товары с явно заданным ID не продвигают последовательность
+		_, err = q.Exec(postgresSyncSequence)
+		return err
+
+	case mysqlDialect:
+		var count int
+		err := q.QueryRow(`SELECT COUNT(*) FROM information_schema.columns
+			WHERE table_schema = DATABASE() AND table_name = 'products' AND column_name = 'id' AND extra LIKE '%auto_increment%'`).Scan(&count)
+		if err != nil || count > 0 {
+			return err
+		}
+		_, err = q.Exec(`ALTER TABLE products MODIFY id INT NOT NULL AUTO_INCREMENT`)
+		return err
+	}
+	return nil
+}
+
+// addSQLiteAutoincrement пересоздает таблицу products SQLite со столбцом id INTEGER PRIMARY KEY AUTOINCREMENT.
+// В таблицах, созданных раньше, id либо не является псевдонимом rowid (id INT), либо ID удаленных товаров
+// с наибольшими номерами назначаются повторно. Триггеры поиска и журнала изменений удаляются вместе
+// со старой таблицей и создаются заново. В остальных базах миграция ничего не делает
+func (s *sqlStore) addSQLiteAutoincrement(q sqlQuerier) error {
+	if s.dialect != sqliteDialect {
+		return nil
+	}
+
+	var schema string
+	if err := q.QueryRow(`SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'products'`).Scan(&schema); err != nil {
+		return err
+	}
+	if strings.Contains(strings.ToUpper(schema), "AUTOINCREMENT") {
+		return nil
+	}
+
+	statements := []string{
+		strings.Replace(fmt.Sprintf(productsTableSchema, s.dialect.idColumn), "products", "products_autoincrement", 1),
+		`INSERT INTO products_autoincrement (` + productColumns + `) SELECT ` + productColumns + ` FROM products`,
+		`DROP TABLE products`,
+		`ALTER TABLE products_autoincrement RENAME TO products`,
+	}
+	for _, statement := range statements {
+		if _, err := q.Exec(statement); err != nil {
+			return err
+		}
+	}
+	if err := s.createSearchIndex(q); err != nil {
+		return err
+	}
+	return s.createChangeLog(q)
+}
+
+// isUniqueViolation сообщает, что вставка нарушила уникальность ключа
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1062
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+	}
+	return false
+}
+
+// hasColumn сообщает, что в таблице table есть столбец column
+func (s *sqlStore) hasColumn(q sqlQuerier, table, column string) (bool, error) {
+	var query string
+	switch s.dialect {
+	case postgresDialect:
+		query = `SELECT COUNT(*) FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?`
+	case mysqlDialect:
+		query = `SELECT COUNT(*) FROM information_schema.columns
+			WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?`
+	case sqliteDialect:
+		query = `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`
+	}
+
+	var count int
+	err := q.QueryRow(s.dialect.rebind(query), table, column).Scan(&count)
+	return count > 0, err
+}
+
+// addVersionColumn добавляет столбец version, если его нет; существующие товары получают версию 1
+func (s *sqlStore) addVersionColumn(q sqlQuerier) error {
+	exists, err := s.hasColumn(q, "products", "version")
+	if err != nil || exists {
+		return err
+	}
+	_, err = q.Exec(`ALTER TABLE products ADD COLUMN version BIGINT NOT NULL DEFAULT 1`)
+	return err
+}
+
+// scanProduct считывает строку результата в models.Product
+func scanProduct(row interface{ Scan(dest ...any) error }) (models.Product, error) {
+	var product models.Product
+	err := row.Scan(&product.ID, &product.Name, &product.Category, &product.Price,
+		&product.Description, &product.InStock, &product.Supplier, &product.Version)
+	return product, err
+}
+
+// GetProduct получает продукт по ID
+func (s *sqlStore) GetProduct(id int) (models.Product, bool, error) {
+	query := `SELECT ` + productColumns + ` FROM products WHERE id = ?`
+
+	product, err := scanProduct(s.DB.QueryRow(s.dialect.rebind(query), id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.Product{}, false, nil
+		}
+		return models.Product{}, false, err
+	}
+
+	return product, true, nil
+}
+
+// GetAllProducts получает все продукты
+func (s *sqlStore) GetAllProducts() ([]models.Product, error) {
+	query := `SELECT ` + productColumns + ` FROM products`
+
+	rows, err := s.DB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []models.Product
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, product)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return products, nil
+}
+
+// sqlColumns соответствие полей сортировки столбцам таблицы products
+var sqlColumns = map[string]string{
+	"id":       "id",
+	"name":     "name",
+	"category": "category",
+	"price":    "price",
+	"in_stock": "in_stock",
+	"supplier": "supplier",
+}
+
+// whereClause формирует условие WHERE и его параметры по фильтрам запроса
+func whereClause(query ProductQuery) (string, []any) {
+	var conditions []string
+	var args []any
+
+	if query.Category != "" {
+		conditions = append(conditions, "category = ?")
+		args = append(args, query.Category)
+	}
+	if query.Supplier != "" {
+		conditions = append(conditions, "supplier = ?")
+		args = append(args, query.Supplier)
+	}
+	if query.InStock != nil {
+		conditions = append(conditions, "in_stock = ?")
+		args = append(args, *query.InStock)
+	}
+	if query.MinPrice != nil {
+		conditions = append(conditions, "price >= ?")
+		args = append(args, *query.MinPrice)
+	}
+	if query.MaxPrice != nil {
+		conditions = append(conditions, "price <= ?")
+		args = append(args, *query.MaxPrice)
+	}
+	if len(query.IDs) > 0 {
+		conditions = append(conditions, "id IN (?"+strings.Repeat(", ?", len(query.IDs)-1)+")")
+		for _, id := range query.IDs {
+			args = append(args, id)
+		}
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// orderClause формирует ORDER BY по полям сортировки (имена столбцов берутся из белого списка)
+func orderClause(query ProductQuery) string {
+	var order []string
+	for _, field := range query.withIDTiebreak() {
+		column := sqlColumns[field.Field]
+		if field.Desc {
+			column += " DESC"
+		}
+		order = append(order, column)
+	}
+	return " ORDER BY " + strings.Join(order, ", ")
+}
+
+// selectProducts запрос товаров с фильтрами, сортировкой и страницей
+func selectProducts(query ProductQuery) (string, []any) {
+	where, args := whereClause(query)
+
+	selectQuery := `SELECT ` + productColumns + ` FROM products` + where + orderClause(query)
+	if query.Limit > 0 {
+		selectQuery += ` LIMIT ?`
+		args = append(args, query.Limit)
+	} else if query.Offset > 0 {
+		// OFFSET без LIMIT в MySQL и SQLite не допускается, поэтому задается заведомо большой предел
+		selectQuery += ` LIMIT ?`
+		args = append(args, int64(1<<62))
+	}
+	if query.Offset > 0 {
+		selectQuery += ` OFFSET ?`
+		args = append(args, query.Offset)
+	}
+	return selectQuery, args
+}
+
+// ListProducts получает страницу продуктов; фильтры, сортировка и страница выполняются на стороне базы
+func (s *sqlStore) ListProducts(query ProductQuery) (ProductPage, error) {
+	where, args := whereClause(query)
+
+	var page ProductPage
+	err := s.DB.QueryRow(s.dialect.rebind(`SELECT COUNT(*) FROM products`+where), args...).Scan(&page.Total)
+	if err != nil {
+		return ProductPage{}, err
+	}
+
+	selectQuery, args := selectProducts(query)
+	rows, err := s.DB.Query(s.dialect.rebind(selectQuery), args...)
+	if err != nil {
+		return ProductPage{}, err
+	}
+	defer rows.Close()
+
+	page.Products = []models.Product{}
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return ProductPage{}, err
+		}
+		page.Products = append(page.Products, product)
+	}
+
+	if err = rows.Err(); err != nil {
+		return ProductPage{}, err
+	}
+
+	return page, nil
+}
+
+// AddProduct добавляет продукт с версией 1. Занятость ID проверяет первичный ключ таблицы
+func (s *sqlStore) AddProduct(product models.Product) (int, error) {
+	if product.ID != 0 {
+		if err := s.insertProduct(s.DB, product); err != nil {
+			return 0, err
+		}
+		s.syncSequence()
+		return product.ID, nil
+	}
+
+	for attempt := 1; ; attempt++ {
+		id, err := s.insertGenerated(s.DB, product)
+		if isUniqueViolation(err) && attempt < generatedIDAttempts {
+			continue
+		}
+		return id, err
+	}
+}
+
+// sqlQuerier общие методы пула соединений и транзакции
+type sqlQuerier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// insertProduct добавляет продукт с заданным ID и версией 1
+func (s *sqlStore) insertProduct(q sqlQuerier, product models.Product) error {
+	query := `INSERT INTO products (` + productColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, 1)`
+
+	_, err := q.Exec(s.dialect.rebind(query), product.ID, product.Name, product.Category, product.Price,
+		product.Description, product.InStock, product.Supplier)
+	if isUniqueViolation(err) {
+		return &DuplicateError{ID: product.ID}
+	}
+	return err
+}
+
+// syncSequence сдвигает последовательность PostgreSQL после вставки с явно заданным ID, который ее не продвигает.
+// Ошибка сдвига не критична: при совпадении назначенного ID вставка повторяется, поэтому она только записывается в журнал
+func (s *sqlStore) syncSequence() {
+	if s.dialect != postgresDialect {
+		return
+	}
+	if _, err := s.DB.Exec(postgresSyncSequence); err != nil {
+		log.Printf("Предупреждение: не удалось сдвинуть последовательность ID товаров после вставки с явным ID: %v", err)
+	}
+}
+
+// insertGenerated добавляет продукт с ID, назначенным базой данных
+func (s *sqlStore) insertGenerated(q sqlQuerier, product models.Product) (int, error) {
+	const columns = `name, category, price, description, in_stock, supplier, version`
+	const values = `?, ?, ?, ?, ?, ?, 1`
+	args := []any{product.Name, product.Category, product.Price, product.Description, product.InStock, product.Supplier}
+
+	var id int64
+	var err error
+
+	switch s.dialect {
+	case postgresDialect:
+		query := `INSERT INTO products (` + columns + `) VALUES (` + values + `) RETURNING id`
+		err = q.QueryRow(s.dialect.rebind(query), args...).Scan(&id)
+
+	case mysqlDialect:
+		var result sql.Result
+		result, err = q.Exec(`INSERT INTO products (`+columns+`) VALUES (`+values+`)`, args...)
+		if err == nil {
+			id, err = result.LastInsertId()
+		}
+
+	case sqliteDialect:
+		query := `INSERT INTO products (` + columns + `) VALUES (` + values + `) RETURNING id`
+		err = q.QueryRow(query, args...).Scan(&id)
+	}
+
+	return int(id), err
+}
+
+// selectVersion читает текущую версию продукта в транзакции и блокирует строку до ее завершения.
+// В SQLite блокировки строк нет: запись в транзакции, прочитавшей устаревшие данные, завершается ошибкой
+func (s *sqlStore) selectVersion(tx *sql.Tx, id int) (int64, bool, error) {
+	query := `SELECT version FROM products WHERE id = ?`
+	if s.dialect != sqliteDialect {
+		query += ` FOR UPDATE`
+	}
+
+	var current int64
+	err := tx.QueryRow(s.dialect.rebind(query), id).Scan(&current)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	return current, err == nil, err
+}
+
+// lockVersion блокирует строку продукта и проверяет ее версию (0 - без проверки)
+func (s *sqlStore) lockVersion(tx *sql.Tx, id int, version int64) (int64, error) {
+	current, exists, err := s.selectVersion(tx, id)
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, fmt.Errorf("продукт с ID %d не найден", id)
+	}
+
+	if version != 0 && current != version {
+		return 0, &VersionConflictError{ID: id, Expected: version, Current: current}
+	}
+	return current, nil
+}
+
+// UpdateProduct обновляет продукт, проверяя версию (0 - без проверки), и увеличивает версию
+func (s *sqlStore) UpdateProduct(product models.Product, version int64) (int64, error) {
+	return s.PatchProduct(product, patchFields, version)
+}
+
+// PatchProduct обновляет только столбцы fields продукта, проверяя версию (0 - без проверки), и увеличивает версию
+func (s *sqlStore) PatchProduct(product models.Product, fields []string, version int64) (int64, error) {
+	if err := checkFields(fields); err != nil {
+		return 0, err
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	current, err := s.lockVersion(tx, product.ID, version)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := s.updateFields(tx, product, fields); err != nil {
+		return 0, err
+	}
+
+	return current + 1, tx.Commit()
+}
+
+// updateFields обновляет столбцы fields продукта и увеличивает версию
+func (s *sqlStore) updateFields(q sqlQuerier, product models.Product, fields []string) error {
+	// Имена полей проверены по белому списку и совпадают с именами столбцов
+	var set []string
+	var args []any
+	for _, field := range fields {
+		set = append(set, field+" = ?")
+		args = append(args, productValue(product, field))
+	}
+	query := `UPDATE products SET ` + strings.Join(set, ", ") + `, version = version + 1 WHERE id = ?`
+
+	_, err := q.Exec(s.dialect.rebind(query), append(args, product.ID)...)
+	return err
+}
+
+// DeleteProduct удаляет продукт, проверяя версию (0 - без проверки)
+func (s *sqlStore) DeleteProduct(id int, version int64) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := s.lockVersion(tx, id, version); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(s.dialect.rebind(`DELETE FROM products WHERE id = ?`), id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
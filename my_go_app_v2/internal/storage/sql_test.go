@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"project/internal/config"
+	"project/internal/models"
+)
+
+// newSQLiteTestStore открывает базу SQLite в памяти со всеми миграциями
+func newSQLiteTestStore(t *testing.T) *SQLiteClient {
+	t.Helper()
+	store, err := initSQLiteDB(config.DatabaseConfig{Name: "test_db", Driver: config.DriverSQLite, DSN: ":memory:"})
+	if err != nil {
+		t.Fatalf("открытие SQLite: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestSQLiteGeneratedIDs(t *testing.T) {
+	product := models.Product{Name: "Цемент", Category: "Смеси", Price: 450, InStock: true, Supplier: "Альфа"}
+
+	add := func(t *testing.T, store *SQLiteClient, id int) int {
+		t.Helper()
+		product := product
+		product.ID = id
+		got, err := store.AddProduct(product)
+		if err != nil {
+			t.Fatalf("добавление товара с ID %d: %v", id, err)
+		}
+		return got
+	}
+
+	t.Run("ID удаленного товара не назначается повторно", func(t *testing.T) {
+		store := newSQLiteTestStore(t)
+		add(t, store, 0)
+		last := add(t, store, 0)
+		if err := store.DeleteProduct(last, 0); err != nil {
+			t.Fatalf("удаление товара: %v", err)
+		}
+		if got := add(t, store, 0); got <= last {
+			t.Fatalf("получен ID %d, ожидался больше %d", got, last)
+		}
+	})
+
+	t.Run("назначенный ID после явного", func(t *testing.T) {
+		store := newSQLiteTestStore(t)
+		add(t, store, 50)
+		if got := add(t, store, 0); got != 51 {
+			t.Fatalf("получен ID %d, ожидался 51", got)
+		}
+	})
+
+	t.Run("явный ID занят", func(t *testing.T) {
+		store := newSQLiteTestStore(t)
+		id := add(t, store, 0)
+		product := product
+		product.ID = id
+		_, err := store.AddProduct(product)
+		var duplicate *DuplicateError
+		if !errors.As(err, &duplicate) {
+			t.Fatalf("получено %v, ожидалась DuplicateError", err)
+		}
+	})
+}
@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"project/internal/metrics"
+	"project/internal/models"
+)
+
+// ProductStore общий интерфейс хранилища товаров, который реализуют клиенты всех баз данных
+type ProductStore interface {
+	GetProduct(id int) (models.Product, bool, error)
+	GetAllProducts() ([]models.Product, error)
+	ListProducts(query ProductQuery) (ProductPage, error)
+	// StreamProducts передает в fn по одному все товары, удовлетворяющие фильтрам запроса, в порядке сортировки,
+	// не собирая выборку в память. Используется для выгрузки каталога целиком
+	StreamProducts(ctx context.Context, query ProductQuery, fn func(models.Product) error) error
+	SearchProducts(text string, limit int) ([]SearchResult, error)
+	// AddProduct добавляет товар и возвращает его ID. Если product.ID равен 0, ID назначает база данных.
+	// Если товар с таким ID уже есть, возвращается *DuplicateError
+	AddProduct(product models.Product) (int, error)
+	// UpdateProduct и DeleteProduct выполняются, только если текущая версия товара равна version
+	// (0 - без проверки), иначе возвращается *VersionConflictError. UpdateProduct и PatchProduct возвращают новую версию
+	UpdateProduct(product models.Product, version int64) (int64, error)
+	// PatchProduct изменяет только поля fields товара product.ID, остальные поля не затрагиваются
+	PatchProduct(product models.Product, fields []string, version int64) (int64, error)
+	DeleteProduct(id int, version int64) error
+	// BulkWrite применяет пакет операций по порядку и возвращает результат каждой.
+	// В режиме atomic пакет применяется целиком или не применяется совсем.
+	// Ошибка возвращается, только если пакет не удалось выполнить
+	BulkWrite(operations []BulkOperation, atomic bool) ([]BulkResult, error)
+}
+
+// Проверка на этапе компиляции, что клиенты реализуют ProductStore
+var (
+	_ ProductStore = (*MongoDBClient)(nil)
+	_ ProductStore = (*PostgresClient)(nil)
+	_ ProductStore = (*MySQLClient)(nil)
+	_ ProductStore = (*SQLiteClient)(nil)
+	_ ProductStore = (*MemoryClient)(nil)
+
+	_ Pinger = (*MongoDBClient)(nil)
+	_ Pinger = (*PostgresClient)(nil)
+	_ Pinger = (*MySQLClient)(nil)
+	_ Pinger = (*SQLiteClient)(nil)
+	_ Pinger = (*MemoryClient)(nil)
+)
+
+// ErrUnknownDatabase база данных не зарегистрирована в менеджере
+var ErrUnknownDatabase = errors.New("база данных не найдена")
+
+// UnavailableError база данных описана в конфигурации, но подключиться к ней пока не удалось
+type UnavailableError struct {
+	Name string
+	// Err последняя ошибка подключения
+	Err error
+	// RetryAt время следующей попытки подключения
+	RetryAt time.Time
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("база данных %s недоступна: %v", e.Name, e.Err)
+}
+
+func (e *UnavailableError) Unwrap() error {
+	return e.Err
+}
+
+// DuplicateError товар с таким ID уже существует
+type DuplicateError struct {
+	ID int
+}
+
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("продукт с ID %d уже существует", e.ID)
+}
+
+// generatedIDAttempts количество попыток вставки с назначенным базой ID. Назначенный ID может совпасть
+// с ID товара, добавленного в обход генератора (например, напрямую в СУБД); следующая попытка получает новый ID
+const generatedIDAttempts = 5
+
+// VersionConflictError товар изменен после того, как клиент прочитал его версию
+type VersionConflictError struct {
+	ID       int
+	Expected int64
+	Current  int64
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("версия продукта с ID %d изменилась: ожидалась %d, текущая %d", e.ID, e.Expected, e.Current)
+}
+
+// Pinger хранилище, умеющее проверять доступность базы данных
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// backend запись реестра: хранилище логической базы данных и состояние подключения к ней
+type backend struct {
+	name     string
+	driver   string
+	required bool
+
+	mu    sync.RWMutex
+	store ProductStore
+	// instrumented - та же база, обернутая сбором метрик; ее получают обработчики API
+	instrumented ProductStore
+	err          error
+	retryAt      time.Time
+}
+
+// get возвращает хранилище или ошибку недоступности.
+// Если instrumented, хранилище обернуто сбором метрик (для обработчиков API)
+func (b *backend) get(instrumented bool) (ProductStore, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.store == nil {
+		return nil, &UnavailableError{Name: b.name, Err: b.err, RetryAt: b.retryAt}
+	}
+	if instrumented {
+		return b.instrumented, nil
+	}
+	return b.store, nil
+}
+
+// setStore отмечает базу данных доступной
+func (b *backend) setStore(store ProductStore) {
+	// Статистика пула соединений database/sql публикуется в метриках
+	if pool, ok := store.(interface{ pool() *sql.DB }); ok {
+		metrics.RegisterDBStats(b.name, pool.pool())
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.store = store
+	b.instrumented = &instrumentedStore{name: b.name, driver: b.driver, next: store}
+	b.err = nil
+	b.retryAt = time.Time{}
+}
+
+// setFailure отмечает базу данных недоступной до следующей попытки подключения
+func (b *backend) setFailure(err error, retryAt time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.err = err
+	b.retryAt = retryAt
+}
+
+// Register регистрирует хранилище под именем логической базы данных
+func (m *DBManager) Register(name string, store ProductStore) {
+	m.register(name).setStore(store)
+}
+
+// register добавляет в реестр запись для базы данных (пока без хранилища)
+func (m *DBManager) register(name string) *backend {
+	if m.backends == nil {
+		m.backends = make(map[string]*backend)
+	}
+	b, exists := m.backends[name]
+	if !exists {
+		b = &backend{name: name, required: true}
+		m.backends[name] = b
+		m.names = append(m.names, name)
+	}
+	return b
+}
+
+// Store возвращает хранилище по имени логической базы данных.
+// Для незарегистрированной базы возвращается ErrUnknownDatabase,
+// для базы, к которой еще не удалось подключиться, - *UnavailableError
+func (m *DBManager) Store(name string) (ProductStore, error) {
+	b, ok := m.backends[name]
+	if !ok {
+		return nil, ErrUnknownDatabase
+	}
+	return b.get(true)
+}
+
+// Names возвращает имена зарегистрированных баз данных в порядке регистрации
+func (m *DBManager) Names() []string {
+	names := make([]string, len(m.names))
+	copy(names, m.names)
+	return names
+}
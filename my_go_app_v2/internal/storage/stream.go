@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+
+	"project/internal/models"
+)
+
+// streamBatchSize сколько документов MongoDB возвращает за одно обращение курсора при выгрузке
+const streamBatchSize = 1000
+
+// StreamProducts передает в fn товары по одному, не собирая их в память: строки результата SQL-запроса
+// читаются по мере вызова fn. Ошибка fn прекращает выборку и возвращается
+func (s *sqlStore) StreamProducts(ctx context.Context, query ProductQuery, fn func(models.Product) error) error {
+	selectQuery, args := selectProducts(query)
+	rows, err := s.DB.QueryContext(ctx, s.dialect.rebind(selectQuery), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(product); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// StreamProducts передает в fn товары из курсора MongoDB по одному
+func (m *MongoDBClient) StreamProducts(ctx context.Context, query ProductQuery, fn func(models.Product) error) error {
+	cursor, err := m.Collection.Find(ctx, mongoFilter(query), mongoFindOptions(query).SetBatchSize(streamBatchSize))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var product models.Product
+		if err := cursor.Decode(&product); err != nil {
+			return err
+		}
+		if err := fn(product); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// StreamProducts передает в fn товары из памяти. Товары уже находятся в памяти процесса,
+// поэтому выборка строится целиком, а fn вызывается без блокировки хранилища
+func (m *MemoryClient) StreamProducts(ctx context.Context, query ProductQuery, fn func(models.Product) error) error {
+	page, err := m.ListProducts(query)
+	if err != nil {
+		return err
+	}
+
+	for _, product := range page.Products {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(product); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,379 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"project/internal/config"
+	"project/internal/models"
+)
+
+// Заголовки уведомления
+const (
+	HeaderSignature = "X-Webhook-Signature"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+)
+
+// Параметры доставки
+const (
+	retryInitialDelay = time.Second
+	retryMaxDelay     = 10 * time.Minute
+	pollInterval      = time.Second
+	batchSize         = 20
+	workers           = 4
+	// retention срок хранения доставленных уведомлений и их журнала
+	retention       = 7 * 24 * time.Hour
+	cleanupInterval = time.Hour
+)
+
+// operations допустимые операции в подписке
+var operations = map[string]bool{"created": true, "updated": true, "deleted": true}
+
+// Payload тело уведомления
+type Payload struct {
+	// ID идентификатор доставки; не меняется при повторных попытках
+	ID       int64           `json:"id"`
+	Event    string          `json:"event"`
+	Database string          `json:"database"`
+	Product  json.RawMessage `json:"product"`
+	Time     time.Time       `json:"time"`
+}
+
+// Sign вычисляет подпись уведомления: HMAC-SHA256 от строки "<timestamp>.<тело>" в шестнадцатеричном виде.
+// Метка времени входит в подпись, чтобы перехваченное уведомление нельзя было повторить позже
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatcher хранит подписки и доставляет уведомления об изменениях товаров с повторными попытками
+type Dispatcher struct {
+	store       *store
+	client      *http.Client
+	maxAttempts int
+	// allowed внутренние подсети, в которые разрешена доставка
+	allowed []*net.IPNet
+
+	wake chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New открывает хранилище подписок и запускает доставку уведомлений
+func New(cfg config.WebhooksConfig) (*Dispatcher, error) {
+	timeout, err := cfg.TimeoutOrDefault()
+	if err != nil {
+		return nil, err
+	}
+	allowed, err := cfg.Networks()
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := openStore(cfg.StorePathOrDefault())
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть хранилище подписок %s: %v", cfg.StorePathOrDefault(), err)
+	}
+
+	d := &Dispatcher{
+		store:       s,
+		client:      newClient(timeout, allowed),
+		maxAttempts: cfg.MaxAttemptsOrDefault(),
+		allowed:     allowed,
+		wake:        make(chan struct{}, 1),
+	}
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+
+	d.wg.Add(1)
+	go d.run()
+	return d, nil
+}
+
+// Close останавливает доставку; недоставленные уведомления остаются в очереди
+func (d *Dispatcher) Close() {
+	d.cancel()
+	d.wg.Wait()
+	if err := d.store.close(); err != nil {
+		log.Printf("Ошибка закрытия хранилища подписок: %v", err)
+	}
+}
+
+// Subscribe создает подписку базы данных. Если ключ подписи не задан, он генерируется
+func (d *Dispatcher) Subscribe(database, target, secret string, events []string) (Subscription, error) {
+	parsed, err := url.Parse(target)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return Subscription{}, fmt.Errorf("адрес подписки должен быть абсолютным URL http или https")
+	}
+	// Адрес, заданный именем, проверяется при каждой доставке после разрешения имени
+	if ip := net.ParseIP(parsed.Hostname()); ip != nil {
+		if err := checkIP(ip, d.allowed); err != nil {
+			return Subscription{}, err
+		}
+	}
+	for _, event := range events {
+		if !operations[event] {
+			return Subscription{}, fmt.Errorf("неизвестная операция %q (допустимо: created, updated, deleted)", event)
+		}
+	}
+	if secret == "" {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return Subscription{}, err
+		}
+		secret = hex.EncodeToString(key)
+	}
+	if events == nil {
+		events = []string{}
+	}
+
+	subscription := Subscription{
+		Database:  database,
+		URL:       target,
+		Secret:    secret,
+		Events:    events,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := d.store.addSubscription(&subscription); err != nil {
+		return Subscription{}, err
+	}
+	return subscription, nil
+}
+
+// Subscriptions возвращает подписки базы данных без ключей подписи
+func (d *Dispatcher) Subscriptions(database string) ([]Subscription, error) {
+	subscriptions, err := d.store.subscriptions(database)
+	if err != nil {
+		return nil, err
+	}
+	for i := range subscriptions {
+		subscriptions[i].Secret = ""
+	}
+	return subscriptions, nil
+}
+
+// Unsubscribe удаляет подписку базы данных вместе с очередью ее уведомлений
+func (d *Dispatcher) Unsubscribe(database string, id int64) error {
+	return d.store.deleteSubscription(database, id)
+}
+
+// Deliveries возвращает журнал последних доставок подписки (limit записей) с попытками;
+// status фильтрует доставки по состоянию
+func (d *Dispatcher) Deliveries(database string, subscriptionID int64, status string, limit int) ([]Delivery, error) {
+	subscription, err := d.store.subscription(subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	if subscription.Database != database {
+		return nil, ErrNotFound
+	}
+
+	deliveries, err := d.store.queryDeliveries(`SELECT `+deliveryColumns+` FROM webhook_deliveries
+		WHERE subscription_id = ? AND (? = '' OR status = ?) ORDER BY id DESC LIMIT ?`, subscriptionID, status, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, d.store.attempts(deliveries)
+}
+
+// DeadLetters возвращает недоставленные уведомления базы данных с журналом попыток
+func (d *Dispatcher) DeadLetters(database string) ([]Delivery, error) {
+	deliveries, err := d.store.queryDeliveries(`SELECT `+deliveryColumns+` FROM webhook_deliveries
+		WHERE database = ? AND status = ? ORDER BY id`, database, StatusDead)
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, d.store.attempts(deliveries)
+}
+
+// RetryDeadLetters возвращает недоставленные уведомления базы в очередь (все, если id равен 0)
+func (d *Dispatcher) RetryDeadLetters(database string, id int64) (int64, error) {
+	count, err := d.store.requeueDead(database, id)
+	if err == nil && count > 0 {
+		d.notify()
+	}
+	return count, err
+}
+
+// Enqueue ставит уведомление об изменении товара в очередь всех подписок базы данных
+func (d *Dispatcher) Enqueue(database, operation string, product models.Product) error {
+	subscriptions, err := d.store.subscriptions(database)
+	if err != nil {
+		return err
+	}
+
+	var targets []Subscription
+	for _, subscription := range subscriptions {
+		if subscription.wants(operation) {
+			targets = append(targets, subscription)
+		}
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(product)
+	if err != nil {
+		return err
+	}
+	if err := d.store.enqueue(targets, database, "product."+operation, string(data)); err != nil {
+		return err
+	}
+	d.notify()
+	return nil
+}
+
+// notify будит обработчик очереди
+func (d *Dispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run доставляет уведомления, время отправки которых наступило
+func (d *Dispatcher) run() {
+	defer d.wg.Done()
+
+	lastCleanup := time.Time{}
+	for {
+		if time.Since(lastCleanup) > cleanupInterval {
+			if err := d.store.cleanup(time.Now().Add(-retention)); err != nil {
+				log.Printf("Вебхуки: ошибка очистки журнала доставок: %v", err)
+			}
+			lastCleanup = time.Now()
+		}
+
+		deliveries, err := d.store.due(batchSize)
+		if err != nil {
+			log.Printf("Вебхуки: ошибка чтения очереди доставок: %v", err)
+		}
+
+		// Доставляем пачку параллельно несколькими обработчиками
+		semaphore := make(chan struct{}, workers)
+		var wg sync.WaitGroup
+		for _, delivery := range deliveries {
+			wg.Add(1)
+			semaphore <- struct{}{}
+			go func(delivery Delivery) {
+				defer wg.Done()
+				defer func() { <-semaphore }()
+				d.deliver(delivery)
+			}(delivery)
+		}
+		wg.Wait()
+
+		// Если пачка заполнена, в очереди могут остаться готовые доставки
+		if len(deliveries) == batchSize && d.ctx.Err() == nil {
+			continue
+		}
+
+		timer := time.NewTimer(pollInterval)
+		select {
+		case <-d.ctx.Done():
+			timer.Stop()
+			return
+		case <-d.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// deliver выполняет одну попытку доставки и записывает ее результат
+func (d *Dispatcher) deliver(delivery Delivery) {
+	subscription, err := d.store.subscription(delivery.SubscriptionID)
+	if err != nil {
+		// Подписка удалена вместе с очередью, пока доставка ждала отправки
+		return
+	}
+
+	attempt := d.send(subscription, delivery)
+	// Запрос прерван остановкой сервера: это не попытка доставки, уведомление будет отправлено после запуска
+	if attempt.Error != "" && d.ctx.Err() != nil {
+		return
+	}
+
+	status := StatusDelivered
+	var nextAttempt time.Time
+	if attempt.Error != "" {
+		if delivery.Attempts+1 >= d.maxAttempts {
+			status = StatusDead
+			log.Printf("Вебхуки: уведомление %d для %s не доставлено после %d попыток: %s",
+				delivery.ID, subscription.URL, delivery.Attempts+1, attempt.Error)
+		} else {
+			status = StatusPending
+			delay := min(retryInitialDelay<<min(delivery.Attempts, 16), retryMaxDelay)
+			nextAttempt = time.Now().Add(delay)
+		}
+	}
+
+	if err := d.store.record(delivery, attempt, status, nextAttempt); err != nil {
+		log.Printf("Вебхуки: ошибка записи результата доставки %d: %v", delivery.ID, err)
+	}
+}
+
+// send отправляет подписанное уведомление. Успешной считается доставка с ответом 2xx
+func (d *Dispatcher) send(subscription Subscription, delivery Delivery) Attempt {
+	attempt := Attempt{AttemptedAt: time.Now().UTC()}
+
+	body, err := json.Marshal(Payload{
+		ID:       delivery.ID,
+		Event:    delivery.Event,
+		Database: delivery.Database,
+		Product:  json.RawMessage(delivery.product),
+		Time:     delivery.CreatedAt,
+	})
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+
+	request, err := http.NewRequestWithContext(d.ctx, http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	timestamp := strconv.FormatInt(attempt.AttemptedAt.Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "product-catalog-webhooks")
+	request.Header.Set(HeaderEvent, delivery.Event)
+	request.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	request.Header.Set(HeaderTimestamp, timestamp)
+	request.Header.Set(HeaderSignature, Sign(subscription.Secret, timestamp, body))
+
+	response, err := d.client.Do(request)
+	attempt.DurationMS = float64(time.Since(attempt.AttemptedAt).Microseconds()) / 1000
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+
+	attempt.StatusCode = response.StatusCode
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		attempt.Error = fmt.Sprintf("получатель ответил %s", response.Status)
+	}
+	return attempt
+}
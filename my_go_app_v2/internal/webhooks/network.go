@@ -0,0 +1,72 @@
+package webhooks
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// blockedNetworks внутренние подсети, которые не покрываются методами net.IP:
+// "этот" сетевой сегмент и общее адресное пространство операторов (CGNAT)
+var blockedNetworks = []*net.IPNet{
+	mustCIDR("0.0.0.0/8"),
+	mustCIDR("100.64.0.0/10"),
+}
+
+func mustCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// newClient создает HTTP-клиент доставки. Адрес получателя проверяется при каждом соединении после
+// разрешения имени, поэтому подписка не может обратиться к сервисам внутренней сети и к метаданным
+// облака (169.254.169.254) ни напрямую, ни через имя или перенаправление на внутренний адрес
+func newClient(timeout time.Duration, allowed []*net.IPNet) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil {
+				return fmt.Errorf("неверный адрес получателя %s", address)
+			}
+			return checkIP(ip, allowed)
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// Через прокси соединение устанавливалось бы с прокси, и адрес получателя не проверялся бы
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// checkIP запрещает доставку на внутренние адреса: loopback, частные и локальные сети,
+// если адрес не входит в разрешенные подсети allowed
+func checkIP(ip net.IP, allowed []*net.IPNet) error {
+	for _, network := range allowed {
+		if network.Contains(ip) {
+			return nil
+		}
+	}
+
+	internal := ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast()
+	for _, network := range blockedNetworks {
+		internal = internal || network.Contains(ip)
+	}
+	if internal {
+		return fmt.Errorf("доставка на адрес %s запрещена: он находится во внутренней сети "+
+			"(разрешить подсеть можно параметром webhooks.allowed_networks)", ip)
+	}
+	return nil
+}
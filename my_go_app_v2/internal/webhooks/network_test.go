@@ -0,0 +1,77 @@
+package webhooks
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckIP(t *testing.T) {
+	allowed := []*net.IPNet{mustCIDR("10.20.0.0/16")}
+
+	tests := []struct {
+		name    string
+		ip      string
+		blocked bool
+	}{
+		{name: "внешний IPv4", ip: "93.184.216.34"},
+		{name: "внешний IPv6", ip: "2606:2800:220:1:248:1893:25c8:1946"},
+		{name: "loopback", ip: "127.0.0.1", blocked: true},
+		{name: "loopback IPv6", ip: "::1", blocked: true},
+		{name: "loopback в IPv4-mapped IPv6", ip: "::ffff:127.0.0.1", blocked: true},
+		{name: "метаданные облака", ip: "169.254.169.254", blocked: true},
+		{name: "метаданные облака в IPv4-mapped IPv6", ip: "::ffff:169.254.169.254", blocked: true},
+		{name: "частная сеть 10/8", ip: "10.1.2.3", blocked: true},
+		{name: "частная сеть 172.16/12", ip: "172.31.255.255", blocked: true},
+		{name: "частная сеть 192.168/16", ip: "192.168.0.10", blocked: true},
+		{name: "частная сеть IPv6", ip: "fd00::1", blocked: true},
+		{name: "локальный IPv6", ip: "fe80::1", blocked: true},
+		{name: "неопределенный адрес", ip: "0.0.0.0", blocked: true},
+		{name: "сеть 0/8", ip: "0.1.2.3", blocked: true},
+		{name: "CGNAT", ip: "100.64.1.1", blocked: true},
+		{name: "multicast", ip: "224.0.0.1", blocked: true},
+		{name: "разрешенная подсеть", ip: "10.20.1.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkIP(net.ParseIP(tt.ip), allowed)
+			if blocked := err != nil; blocked != tt.blocked {
+				t.Fatalf("адрес %s: получено %v, ожидался запрет: %v", tt.ip, err, tt.blocked)
+			}
+		})
+	}
+}
+
+func TestClientBlocksInternalAddress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	if _, err := newClient(time.Second, nil).Get(server.URL); err == nil || !strings.Contains(err.Error(), "запрещена") {
+		t.Fatalf("доставка на loopback не запрещена: %v", err)
+	}
+	allowed := []*net.IPNet{mustCIDR("127.0.0.1/32")}
+	if _, err := newClient(time.Second, allowed).Get(server.URL); err != nil {
+		t.Fatalf("доставка в разрешенную подсеть: %v", err)
+	}
+
+	// Перенаправление на запрещенный адрес проверяется так же, как исходный адрес
+	listener, err := net.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		t.Skipf("адрес 127.0.0.2 недоступен: %v", err)
+	}
+	internal := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	internal.Listener.Close()
+	internal.Listener = listener
+	internal.Start()
+	defer internal.Close()
+
+	redirect := httptest.NewServer(http.RedirectHandler(internal.URL, http.StatusFound))
+	defer redirect.Close()
+	if _, err := newClient(time.Second, allowed).Get(redirect.URL); err == nil || !strings.Contains(err.Error(), "запрещена") {
+		t.Fatalf("перенаправление на запрещенный адрес не запрещено: %v", err)
+	}
+}
@@ -0,0 +1,372 @@
+package webhooks
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// Состояния доставки
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	// StatusDead доставка не удалась после всех попыток и попала в список недоставленных
+	StatusDead = "dead"
+)
+
+// ErrNotFound подписка или доставка не найдена
+var ErrNotFound = errors.New("не найдено")
+
+// storeSchema подписки, очередь доставок и журнал попыток
+const storeSchema = `
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	database   TEXT    NOT NULL,
+	url        TEXT    NOT NULL,
+	secret     TEXT    NOT NULL,
+	events     TEXT    NOT NULL DEFAULT '',
+	created_at INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id               INTEGER PRIMARY KEY AUTOINCREMENT,
+	subscription_id  INTEGER NOT NULL,
+	database         TEXT    NOT NULL,
+	event            TEXT    NOT NULL,
+	product          TEXT    NOT NULL,
+	created_at       INTEGER NOT NULL,
+	attempts         INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at  INTEGER NOT NULL,
+	status           TEXT    NOT NULL DEFAULT 'pending',
+	last_status_code INTEGER NOT NULL DEFAULT 0,
+	last_error       TEXT    NOT NULL DEFAULT '',
+	delivered_at     INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_subscription_idx ON webhook_deliveries (subscription_id, id);
+CREATE TABLE IF NOT EXISTS webhook_attempts (
+	id           INTEGER PRIMARY KEY AUTOINCREMENT,
+	delivery_id  INTEGER NOT NULL,
+	attempted_at INTEGER NOT NULL,
+	status_code  INTEGER NOT NULL,
+	error        TEXT    NOT NULL,
+	duration_ms  REAL    NOT NULL
+);
+CREATE INDEX IF NOT EXISTS webhook_attempts_delivery_idx ON webhook_attempts (delivery_id);`
+
+// Subscription подписка на изменения товаров логической базы данных
+type Subscription struct {
+	ID       int64  `json:"id"`
+	Database string `json:"database"`
+	URL      string `json:"url"`
+	// Secret ключ подписи HMAC; возвращается клиенту только при создании подписки
+	Secret string `json:"secret,omitempty"`
+	// Events операции, о которых отправляются уведомления (created, updated, deleted); пустой список - все
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// wants сообщает, подписана ли подписка на операцию
+func (s Subscription) wants(operation string) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, event := range s.Events {
+		if event == operation {
+			return true
+		}
+	}
+	return false
+}
+
+// Attempt попытка доставки
+type Attempt struct {
+	AttemptedAt time.Time `json:"attempted_at"`
+	StatusCode  int       `json:"status_code,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	DurationMS  float64   `json:"duration_ms"`
+}
+
+// Delivery уведомление об одном изменении товара для одной подписки
+type Delivery struct {
+	ID             int64      `json:"id"`
+	SubscriptionID int64      `json:"subscription_id"`
+	Database       string     `json:"database"`
+	Event          string     `json:"event"`
+	CreatedAt      time.Time  `json:"created_at"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	Status         string     `json:"status"`
+	LastStatusCode int        `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	// Log журнал попыток доставки
+	Log []Attempt `json:"log,omitempty"`
+
+	// product товар в JSON, как он будет передан в теле уведомления
+	product string
+}
+
+// store подписки и доставки в файле SQLite
+type store struct {
+	db *sql.DB
+}
+
+// openStore открывает (и при необходимости создает) файл подписок
+func openStore(path string) (*store, error) {
+	if path != ":memory:" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, err
+		}
+		path = "file:" + path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(storeSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &store{db: db}, nil
+}
+
+// close закрывает файл подписок
+func (s *store) close() error {
+	return s.db.Close()
+}
+
+// addSubscription сохраняет подписку
+func (s *store) addSubscription(subscription *Subscription) error {
+	result, err := s.db.Exec(`INSERT INTO webhook_subscriptions (database, url, secret, events, created_at) VALUES (?, ?, ?, ?, ?)`,
+		subscription.Database, subscription.URL, subscription.Secret, strings.Join(subscription.Events, ","), subscription.CreatedAt.UnixNano())
+	if err != nil {
+		return err
+	}
+	subscription.ID, err = result.LastInsertId()
+	return err
+}
+
+// subscriptions возвращает подписки базы данных (вместе с ключами подписи)
+func (s *store) subscriptions(database string) ([]Subscription, error) {
+	rows, err := s.db.Query(`SELECT id, database, url, secret, events, created_at FROM webhook_subscriptions
+		WHERE database = ? ORDER BY id`, database)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subscriptions := []Subscription{}
+	for rows.Next() {
+		var subscription Subscription
+		var events string
+		var createdAt int64
+		if err := rows.Scan(&subscription.ID, &subscription.Database, &subscription.URL, &subscription.Secret, &events, &createdAt); err != nil {
+			return nil, err
+		}
+		subscription.Events = []string{}
+		if events != "" {
+			subscription.Events = strings.Split(events, ",")
+		}
+		subscription.CreatedAt = time.Unix(0, createdAt).UTC()
+		subscriptions = append(subscriptions, subscription)
+	}
+	return subscriptions, rows.Err()
+}
+
+// subscription возвращает подписку по идентификатору
+func (s *store) subscription(id int64) (Subscription, error) {
+	var database string
+	if err := s.db.QueryRow(`SELECT database FROM webhook_subscriptions WHERE id = ?`, id).Scan(&database); err != nil {
+		if err == sql.ErrNoRows {
+			return Subscription{}, ErrNotFound
+		}
+		return Subscription{}, err
+	}
+	subscriptions, err := s.subscriptions(database)
+	if err != nil {
+		return Subscription{}, err
+	}
+	for _, subscription := range subscriptions {
+		if subscription.ID == id {
+			return subscription, nil
+		}
+	}
+	return Subscription{}, ErrNotFound
+}
+
+// deleteSubscription удаляет подписку базы вместе с ее доставками и журналом
+func (s *store) deleteSubscription(database string, id int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM webhook_subscriptions WHERE id = ? AND database = ?`, id, database)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	_, err = tx.Exec(`DELETE FROM webhook_attempts WHERE delivery_id IN (SELECT id FROM webhook_deliveries WHERE subscription_id = ?)`, id)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM webhook_deliveries WHERE subscription_id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// enqueue ставит уведомление в очередь каждой подписки одной транзакцией
+func (s *store) enqueue(subscriptions []Subscription, database, event, product string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UnixNano()
+	for _, subscription := range subscriptions {
+		_, err := tx.Exec(`INSERT INTO webhook_deliveries (subscription_id, database, event, product, created_at, next_attempt_at)
+			VALUES (?, ?, ?, ?, ?, ?)`, subscription.ID, database, event, product, now, now)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+const deliveryColumns = `id, subscription_id, database, event, product, created_at, attempts, next_attempt_at,
+	status, last_status_code, last_error, delivered_at`
+
+// scanDelivery читает доставку из строки результата
+func scanDelivery(row interface{ Scan(dest ...any) error }) (Delivery, error) {
+	var delivery Delivery
+	var createdAt, nextAttemptAt, deliveredAt int64
+	err := row.Scan(&delivery.ID, &delivery.SubscriptionID, &delivery.Database, &delivery.Event, &delivery.product,
+		&createdAt, &delivery.Attempts, &nextAttemptAt, &delivery.Status, &delivery.LastStatusCode, &delivery.LastError, &deliveredAt)
+	if err != nil {
+		return Delivery{}, err
+	}
+	delivery.CreatedAt = time.Unix(0, createdAt).UTC()
+	if delivery.Status == StatusPending {
+		next := time.Unix(0, nextAttemptAt).UTC()
+		delivery.NextAttemptAt = &next
+	}
+	if deliveredAt != 0 {
+		delivered := time.Unix(0, deliveredAt).UTC()
+		delivery.DeliveredAt = &delivered
+	}
+	return delivery, nil
+}
+
+// queryDeliveries выполняет запрос доставок
+func (s *store) queryDeliveries(query string, args ...any) ([]Delivery, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []Delivery{}
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// due возвращает доставки, время очередной попытки которых наступило
+func (s *store) due(limit int) ([]Delivery, error) {
+	return s.queryDeliveries(`SELECT `+deliveryColumns+` FROM webhook_deliveries
+		WHERE status = ? AND next_attempt_at <= ? ORDER BY next_attempt_at, id LIMIT ?`,
+		StatusPending, time.Now().UnixNano(), limit)
+}
+
+// record записывает результат попытки доставки и новое состояние доставки
+func (s *store) record(delivery Delivery, attempt Attempt, status string, nextAttempt time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO webhook_attempts (delivery_id, attempted_at, status_code, error, duration_ms) VALUES (?, ?, ?, ?, ?)`,
+		delivery.ID, attempt.AttemptedAt.UnixNano(), attempt.StatusCode, attempt.Error, attempt.DurationMS)
+	if err != nil {
+		return err
+	}
+
+	var deliveredAt int64
+	if status == StatusDelivered {
+		deliveredAt = attempt.AttemptedAt.UnixNano()
+	}
+	_, err = tx.Exec(`UPDATE webhook_deliveries SET attempts = attempts + 1, status = ?, next_attempt_at = ?,
+		last_status_code = ?, last_error = ?, delivered_at = ? WHERE id = ?`,
+		status, nextAttempt.UnixNano(), attempt.StatusCode, attempt.Error, deliveredAt, delivery.ID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// attempts добавляет к доставкам журнал попыток
+func (s *store) attempts(deliveries []Delivery) error {
+	for i := range deliveries {
+		rows, err := s.db.Query(`SELECT attempted_at, status_code, error, duration_ms FROM webhook_attempts
+			WHERE delivery_id = ? ORDER BY id`, deliveries[i].ID)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var attempt Attempt
+			var attemptedAt int64
+			if err := rows.Scan(&attemptedAt, &attempt.StatusCode, &attempt.Error, &attempt.DurationMS); err != nil {
+				rows.Close()
+				return err
+			}
+			attempt.AttemptedAt = time.Unix(0, attemptedAt).UTC()
+			deliveries[i].Log = append(deliveries[i].Log, attempt)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// requeueDead возвращает недоставленные уведомления базы в очередь (все или одно по идентификатору)
+func (s *store) requeueDead(database string, id int64) (int64, error) {
+	result, err := s.db.Exec(`UPDATE webhook_deliveries SET status = ?, attempts = 0, next_attempt_at = ?
+		WHERE database = ? AND status = ? AND (? = 0 OR id = ?)`,
+		StatusPending, time.Now().UnixNano(), database, StatusDead, id, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// cleanup удаляет доставленные уведомления и журнал попыток старше before
+func (s *store) cleanup(before time.Time) error {
+	_, err := s.db.Exec(`DELETE FROM webhook_attempts WHERE delivery_id IN
+		(SELECT id FROM webhook_deliveries WHERE status = ? AND delivered_at < ?)`, StatusDelivered, before.UnixNano())
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`DELETE FROM webhook_deliveries WHERE status = ? AND delivered_at < ?`, StatusDelivered, before.UnixNano())
+	return err
+}